@@ -31,6 +31,30 @@ const (
 	// MariaDBServerReadyCondition Status=True condition which indicates that the MariaDB and/or
 	// Galera server is ready for database / account create/drop operations to proceed
 	MariaDBServerReadyCondition condition.Type = "MariaDBServerReady"
+
+	// GaleraBackupReadyCondition Status=True condition which indicates that the
+	// backup artifact of a GaleraBackup has been successfully created
+	GaleraBackupReadyCondition condition.Type = "GaleraBackupReady"
+
+	// GaleraRestoreReadyCondition Status=True condition which indicates that a
+	// galera cluster has been rebuilt from the backup artifact of a GaleraRestore
+	GaleraRestoreReadyCondition condition.Type = "GaleraRestoreReady"
+
+	// GaleraBackupScheduleReadyCondition Status=True condition which indicates that
+	// the last scheduled backup of a galera cluster did not fail
+	GaleraBackupScheduleReadyCondition condition.Type = "GaleraBackupScheduleReady"
+
+	// GaleraRootPasswordReadyCondition Status=True condition which indicates that
+	// the galera nodes use the root password from the Secret of the Galera CR
+	GaleraRootPasswordReadyCondition condition.Type = "RootPasswordReady"
+
+	// GaleraUpgradeReadyCondition Status=True condition which indicates that
+	// all the galera nodes run the container image from the Galera CR
+	GaleraUpgradeReadyCondition condition.Type = "UpgradeReady"
+
+	// GaleraStorageReadyCondition Status=True condition which indicates that
+	// the volumes of the galera nodes have the size requested in the Galera CR
+	GaleraStorageReadyCondition condition.Type = "StorageReady"
 )
 
 // MariaDB Reasons used by API objects.
@@ -97,4 +121,95 @@ const (
 	MariaDBAccountFinalizersRemainMessage = "Waiting for finalizers %s to be removed before dropping username"
 
 	MariaDBAccountReadyForDeleteMessage = "MariaDBAccount ready for delete"
+
+	MariaDBAccountPreviousPasswordValidMessage = "MariaDBAccount password rotated, previous password still accepted until %s"
+
+	//
+	// GaleraBackupReady condition messages
+	//
+	GaleraBackupReadyInitMessage = "GaleraBackup not started"
+
+	GaleraBackupReadyMessage = "GaleraBackup completed"
+
+	GaleraBackupReadyRunningMessage = "GaleraBackup in progress on node %s"
+
+	GaleraBackupReadyErrorMessage = "GaleraBackup error occured %s"
+
+	GaleraBackupNoSyncedNodeMessage = "No Synced galera node available to take a backup"
+
+	//
+	// GaleraRestoreReady condition messages
+	//
+	GaleraRestoreReadyInitMessage = "GaleraRestore not started"
+
+	GaleraRestoreReadyMessage = "GaleraRestore completed"
+
+	GaleraRestoreReadyErrorMessage = "GaleraRestore error occured %s"
+
+	GaleraRestoreBackupNotReadyMessage = "GaleraBackup %s is not complete"
+
+	GaleraRestoreConflictMessage = "Galera %s is already being restored by %s"
+
+	GaleraRestoreStoppingMessage = "Stopping galera cluster before restore"
+
+	GaleraRestoreRunningMessage = "Restoring backup %s on node %s"
+
+	GaleraRestoreRestartingMessage = "Restarting galera cluster from restored data"
+
+	GaleraRestoreNoBinlogArchiveMessage = "Galera %s has no binlog archive to replay transactions from"
+
+	//
+	// Galera DeploymentReady condition messages
+	//
+	GaleraStoppedForRestoreMessage = "Galera cluster stopped for GaleraRestore %s"
+
+	//
+	// GaleraBackupScheduleReady condition messages
+	//
+	GaleraBackupScheduleReadyInitMessage = "Backup schedule not started"
+
+	GaleraBackupScheduleReadyMessage = "Last scheduled backup %s completed"
+
+	GaleraBackupScheduleNoBackupMessage = "No scheduled backup completed yet"
+
+	GaleraBackupScheduleFailedMessage = "Scheduled backup %s failed: %s"
+
+	GaleraBackupScheduleErrorMessage = "Backup schedule error occured %s"
+
+	//
+	// RootPasswordReady condition messages
+	//
+	GaleraRootPasswordReadyInitMessage = "Root password not checked"
+
+	GaleraRootPasswordReadyMessage = "Root password up to date"
+
+	GaleraRootPasswordRotationPendingMessage = "Root password changed, rotation pending until a galera pod is ready"
+
+	GaleraRootPasswordRotatingMessage = "Root password rotation in progress on pod %s"
+
+	GaleraRootPasswordVerifyingMessage = "Verifying the new root password from pod %s"
+
+	//
+	// UpgradeReady condition messages
+	//
+	GaleraUpgradeReadyInitMessage = "Server upgrade not checked"
+
+	GaleraUpgradeReadyMessage = "Server up to date"
+
+	GaleraUpgradeInProgressMessage = "Upgrade to %s in progress on pod %s"
+
+	GaleraUpgradeRunningMessage = "Running mariadb-upgrade on pod %s"
+
+	GaleraUpgradeRolledBackMessage = "Upgrade to %s failed: %s. Rolled back to %s"
+
+	//
+	// StorageReady condition messages
+	//
+	GaleraStorageReadyInitMessage = "Storage size not checked"
+
+	GaleraStorageReadyMessage = "Storage size up to date"
+
+	GaleraStorageResizingMessage = "Resizing volumes to %s: %d/%d volumes resized"
+
+	GaleraStorageResizeErrorMessage = "Resizing volumes to %s failed: %s"
 )
@@ -0,0 +1,472 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	tls "github.com/openstack-k8s-operators/lib-common/modules/common/tls"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// serverVersionRegexp matches the release series of a MariaDB version,
+// e.g. "10.11" in "10.11.6-MariaDB-log"
+var serverVersionRegexp = regexp.MustCompile(`^(\d+)\.(\d+)`)
+
+// ParseServerSeries returns the major and minor numbers of a MariaDB version,
+// which identify its release series. ok is false if the version can't be parsed
+func ParseServerSeries(version string) (major int, minor int, ok bool) {
+	m := serverVersionRegexp.FindStringSubmatch(version)
+	if m == nil {
+		return 0, 0, false
+	}
+	major, _ = strconv.Atoi(m[1])
+	minor, _ = strconv.Atoi(m[2])
+	return major, minor, true
+}
+
+// IsServerDowngrade returns true when MariaDB version "to" belongs to an older
+// release series than version "from" (e.g. 10.11.6 -> 10.6.16). MariaDB does not
+// support such downgrades, as the upgraded data files can't be read anymore.
+// Versions that can't be parsed are never considered a downgrade
+func IsServerDowngrade(from string, to string) bool {
+	fromMajor, fromMinor, ok := ParseServerSeries(from)
+	if !ok {
+		return false
+	}
+	toMajor, toMinor, ok := ParseServerSeries(to)
+	if !ok {
+		return false
+	}
+	return toMajor < fromMajor || (toMajor == fromMajor && toMinor < fromMinor)
+}
+
+// ImageTagVersion returns the tag of a container image reference, which
+// usually carries the version of the software in the image (e.g. "10.11.6"
+// in "quay.io/mariadb/server:10.11.6"). Returns "" for untagged images
+func ImageTagVersion(image string) string {
+	// ignore digests, and colons that belong to the registry's port
+	image = strings.SplitN(image, "@", 2)[0]
+	image = image[strings.LastIndex(image, "/")+1:]
+	idx := strings.LastIndex(image, ":")
+	if idx < 0 {
+		return ""
+	}
+	return image[idx+1:]
+}
+
+const (
+	mib = 1024 * 1024
+
+	// memory kept out of the buffer pool and the client connections, for
+	// the other server buffers, the galera replication and the SST
+	minHeadroom = 256 * mib
+
+	minBufferPoolSize = 32 * mib
+
+	// memory budgeted for each client connection, on top of the buffer pool
+	connectionMemory = 2 * mib
+	minConnections   = 151
+	maxConnections   = 16384
+
+	minLogFileSize = 48 * mib
+	maxLogFileSize = 4096 * mib
+
+	minGcacheSize = 128 * mib
+	maxGcacheSize = 4096 * mib
+)
+
+// galeraVolumes are the names of the volumes of the galera pods that the
+// override can't replace, whether or not the pods currently use them. The
+// mysql-db volume is the one of the statefulset's volume claim template
+var galeraVolumes = []string{
+	"mysql-db",
+	"secrets",
+	"kolla-config",
+	"config-data-generated",
+	"config-data-default",
+	"operator-scripts",
+	"binlog-archive",
+	"galera-tls-certs",
+	tls.CABundleLabel,
+}
+
+// GaleraContainers returns the names of the containers of the galera pods
+// that the override can change, which depend on the enabled sidecars
+func (spec *GaleraSpecCore) GaleraContainers() []string {
+	containers := []string{"galera"}
+	if spec.LogToDisk {
+		containers = append(containers, "log")
+	}
+	if spec.BinlogArchive != nil {
+		containers = append(containers, "binlog-archiver")
+	}
+	if spec.Metrics != nil {
+		containers = append(containers, "mysqld-exporter")
+	}
+	return containers
+}
+
+// GaleraVolumes returns the names of the volumes of the galera pods that
+// the override can't replace
+func GaleraVolumes() []string {
+	return slices.Clone(galeraVolumes)
+}
+
+// GaleraTuning holds the mysqld and galera options derived from the
+// resources of a galera CR. Empty values are left to the static config
+type GaleraTuning struct {
+	InnodbBufferPoolSize string
+	InnodbLogFileSize    string
+	MaxConnections       string
+	GcacheSize           string
+}
+
+// Tuning sizes the InnoDB buffer pool and redo log, the maximum number of
+// connections and the galera cache after the memory limit (or request) of the
+// galera container and its storage request. Nothing is tuned when no memory is
+// set, and options that are set in the CustomServiceConfig are kept as-is
+func (instance Galera) Tuning() GaleraTuning {
+	tuning := GaleraTuning{}
+	memory := galeraMemory(instance.Spec.Resources)
+	if memory == 0 {
+		return tuning
+	}
+	options, providerOptions := customServiceConfigOptions(instance.Spec.CustomServiceConfig)
+	storage := int64(0)
+	if q, err := resource.ParseQuantity(instance.Spec.StorageRequest); err == nil {
+		storage = q.Value()
+	}
+
+	// a quarter of the memory is kept as headroom, which leaves less to
+	// the buffer pool on small nodes. Two thirds of the rest go to the
+	// buffer pool, the last third to the client connections
+	usable := max(memory-max(memory/4, minHeadroom), 0)
+	bufferPool := max(usable*2/3/mib*mib, minBufferPoolSize)
+	connections := clamp((usable-bufferPool)/connectionMemory, minConnections, maxConnections)
+	// the redo log is a quarter of the buffer pool, but never more
+	// than a tenth of the volume, which also hosts the galera cache
+	logFile := clamp(bufferPool/4/mib*mib, minLogFileSize, maxLogFileSize)
+	gcache := int64(minGcacheSize)
+	if storage > 0 {
+		logFile = clamp(logFile, minLogFileSize, storage/10/mib*mib)
+		gcache = clamp(storage/20/mib*mib, minGcacheSize, maxGcacheSize)
+	}
+
+	if _, set := options["innodb_buffer_pool_size"]; !set {
+		tuning.InnodbBufferPoolSize = fmt.Sprintf("%dM", bufferPool/mib)
+	}
+	if _, set := options["innodb_log_file_size"]; !set {
+		tuning.InnodbLogFileSize = fmt.Sprintf("%dM", logFile/mib)
+	}
+	if _, set := options["max_connections"]; !set {
+		tuning.MaxConnections = strconv.FormatInt(connections, 10)
+	}
+	if _, set := providerOptions["gcache.size"]; !set {
+		tuning.GcacheSize = fmt.Sprintf("%dM", gcache/mib)
+	}
+	return tuning
+}
+
+// galeraMemory returns the memory limit of the galera container in bytes,
+// or its memory request if there is no limit, or 0 when neither is set
+func galeraMemory(resources corev1.ResourceRequirements) int64 {
+	if q, found := resources.Limits[corev1.ResourceMemory]; found {
+		return q.Value()
+	}
+	if q, found := resources.Requests[corev1.ResourceMemory]; found {
+		return q.Value()
+	}
+	return 0
+}
+
+func clamp(value int64, low int64, high int64) int64 {
+	return min(max(value, low), high)
+}
+
+// clusterServerOptions are the server options that all the galera nodes must
+// agree on. A node restarted with a new value can't join the nodes that still
+// run with the previous one, so changing them requires a full cluster stop
+var clusterServerOptions = []string{
+	"wsrep_cluster_name",
+	"wsrep_provider",
+	"wsrep_on",
+	"wsrep_gtid_mode",
+	"wsrep_gtid_domain_id",
+	"binlog_format",
+}
+
+// clusterProviderOptions are the galera provider options that all the galera
+// nodes must agree on, for the same reason as clusterServerOptions
+var clusterProviderOptions = []string{
+	"base_port",
+	"evs.version",
+	"gmcast.group",
+	"gmcast.segment",
+	"socket.ssl",
+	"socket.ssl_cipher",
+}
+
+// ClusterConfigOptions returns the options set in the CustomServiceConfig that
+// require a full cluster stop when they change, with their value. All other
+// options are safe to roll out one galera pod at a time
+func (instance Galera) ClusterConfigOptions() map[string]string {
+	ret := map[string]string{}
+	options, providerOptions := customServiceConfigOptions(instance.Spec.CustomServiceConfig)
+	for _, name := range clusterServerOptions {
+		if value, found := options[name]; found {
+			ret[name] = value
+		}
+	}
+	for _, name := range clusterProviderOptions {
+		if value, found := providerOptions[name]; found {
+			ret[name] = value
+		}
+	}
+	return ret
+}
+
+// customServiceConfigOptions returns the server options and the galera provider
+// options set in a mariadb config file, with their value. The sections that are
+// not read by the server and malformed lines are ignored
+func customServiceConfigOptions(config string) (options map[string]string, providerOptions map[string]string) {
+	options = map[string]string{}
+	providerOptions = map[string]string{}
+	parsed, _ := parseServiceConfig(config)
+	for _, opt := range parsed {
+		if !isServerSection(opt.section) {
+			continue
+		}
+		options[opt.name] = opt.value
+		if opt.name == "wsrep_provider_options" {
+			// the last occurrence of the option replaces the previous ones
+			providerOptions = parseProviderOptions(opt.value)
+		}
+	}
+	return options, providerOptions
+}
+
+// serviceConfigOption is an option set in a mariadb config file. The name is
+// normalized to use underscores, without its loose prefix, and the value is unquoted
+type serviceConfigOption struct {
+	section string
+	name    string
+	value   string
+	loose   bool
+	line    int
+}
+
+// serviceConfigError reports a malformed line of a mariadb config file
+type serviceConfigError struct {
+	line    int
+	content string
+	reason  string
+}
+
+func (e serviceConfigError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.reason)
+}
+
+// serviceConfigOptionRegexp matches the normalized name of an option
+var serviceConfigOptionRegexp = regexp.MustCompile(`^[a-z0-9_.]+$`)
+
+// parseServiceConfig parses a mariadb config file, and returns its options in
+// order of appearance. Malformed lines are reported as errors and skipped
+func parseServiceConfig(config string) (options []serviceConfigOption, errs []serviceConfigError) {
+	section := ""
+	lineNumber := 0
+	scanner := bufio.NewScanner(strings.NewReader(config))
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(stripInlineComment(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			name := strings.TrimSpace(strings.TrimSuffix(line[1:], "]"))
+			if !strings.HasSuffix(line, "]") || name == "" || strings.ContainsAny(name, "[]") {
+				errs = append(errs, serviceConfigError{lineNumber, line, "malformed section header"})
+				continue
+			}
+			section = strings.ToLower(name)
+			continue
+		}
+		if section == "" {
+			errs = append(errs, serviceConfigError{lineNumber, line, "option without a preceding section"})
+			continue
+		}
+		name, value, _ := strings.Cut(line, "=")
+		name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "_")
+		if !serviceConfigOptionRegexp.MatchString(name) {
+			errs = append(errs, serviceConfigError{lineNumber, line, "malformed option"})
+			continue
+		}
+		opt := serviceConfigOption{section: section, value: unquoteOptionValue(value), line: lineNumber}
+		opt.name, opt.loose = strings.CutPrefix(name, "loose_")
+		options = append(options, opt)
+	}
+	return options, errs
+}
+
+// serverSectionRegexp matches the sections of a mariadb config file read by
+// the server, optionally restricted to a release series, e.g. [mariadb-10.11]
+var serverSectionRegexp = regexp.MustCompile(`^(mysqld|server|mariadb|mariadbd|galera|client-server)(-\d+\.\d+)?$`)
+
+// isServerSection returns whether a section of a mariadb config file is read
+// by the server. The other sections are read by the client and the tools,
+// e.g. mysqld_safe or mariabackup
+func isServerSection(section string) bool {
+	return serverSectionRegexp.MatchString(section)
+}
+
+// parseProviderOptions returns the galera provider options set in
+// the value of wsrep_provider_options
+func parseProviderOptions(value string) map[string]string {
+	ret := map[string]string{}
+	for _, opt := range strings.Split(value, ";") {
+		key, val, _ := strings.Cut(opt, "=")
+		if key = strings.TrimSpace(key); key != "" {
+			ret[key] = strings.TrimSpace(val)
+		}
+	}
+	return ret
+}
+
+// stripInlineComment removes the comment that ends a line of a mariadb config
+// file, i.e. the text after a '#' which is not inside a quoted value
+func stripInlineComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquoteOptionValue strips the blanks and the quotes around an option value
+func unquoteOptionValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// operatorManagedOptions are the server options set by the operator in the
+// galera config, which can't be overridden in the CustomServiceConfig
+var operatorManagedOptions = []string{
+	"bind_address",
+	"datadir",
+	"socket",
+	"wsrep_cluster_address",
+	"wsrep_notify_cmd",
+}
+
+// operatorManagedProviderOptions are the galera provider options set by the
+// operator, which can't be overridden in the CustomServiceConfig
+var operatorManagedProviderOptions = []string{
+	"gmcast.listen_addr",
+}
+
+// knownServerOptionPrefixes are the prefixes of the families of server options,
+// e.g. the options of a storage engine or a plugin
+var knownServerOptionPrefixes = []string{
+	"aria_", "auto_increment_", "binlog_", "character_set_", "collation_",
+	"connect_", "default_", "encrypt_", "file_key_management_", "ft_",
+	"general_log", "gtid_", "histogram_", "innodb", "join_", "key_",
+	"log_", "lock_", "long_query_", "master_", "myisam_",
+	"net_", "optimizer_", "performance_schema", "plugin_", "query_",
+	"read_", "relay_log", "replicate_", "rocksdb_", "rpl_", "server_audit",
+	"session_track_", "slave_", "slow_", "sort_", "sql_", "ssl", "sync_",
+	"table_", "thread_", "tls_", "tmp_", "wait_", "wsrep_",
+}
+
+// knownServerOptions are the server options that don't belong to a family
+// of knownServerOptionPrefixes
+var knownServerOptions = []string{
+	"alter_algorithm", "autocommit", "back_log", "basedir", "big_tables",
+	"bind_address", "bulk_insert_buffer_size", "character_sets_dir",
+	"concurrent_insert", "console", "core_file", "datadir",
+	"deadlock_search_depth_long", "deadlock_search_depth_short",
+	"deadlock_timeout_long", "deadlock_timeout_short", "delay_key_write",
+	"delayed_insert_limit", "delayed_insert_timeout", "delayed_queue_size",
+	"div_precision_increment", "enforce_storage_engine", "eq_range_index_dive_limit",
+	"event_scheduler", "expensive_subquery_limit", "expire_logs_days",
+	"explicit_defaults_for_timestamp", "external_locking", "extra_max_connections",
+	"extra_port", "flush", "flush_time", "group_concat_max_len", "host_cache_size",
+	"ignore_db_dirs", "in_predicate_conversion_threshold", "init_connect",
+	"init_file", "init_slave", "interactive_timeout", "large_pages", "lc_messages",
+	"lc_messages_dir", "local_infile", "lower_case_table_names", "max_allowed_packet",
+	"max_binlog_cache_size", "max_binlog_size", "max_binlog_stmt_cache_size",
+	"max_connect_errors", "max_connections", "max_delayed_threads", "max_digest_length",
+	"max_error_count", "max_heap_table_size", "max_insert_delayed_threads",
+	"max_join_size", "max_length_for_sort_data", "max_password_errors",
+	"max_prepared_stmt_count", "max_recursive_iterations", "max_relay_log_size",
+	"max_rowid_filter_size", "max_seeks_for_key", "max_session_mem_used",
+	"max_sort_length", "max_sp_recursion_depth", "max_statement_time",
+	"max_tmp_tables", "max_user_connections", "max_write_lock_count", "memlock",
+	"metadata_locks_cache_size", "metadata_locks_hash_instances", "min_examined_row_limit",
+	"mrr_buffer_size",
+	"mysql56_temporal_format", "name_resolve", "old", "old_mode", "old_passwords",
+	"open_files_limit", "password_reuse_check_interval", "pid_file", "port",
+	"preload_buffer_size", "profiling", "profiling_history_size", "proxy_protocol_networks",
+	"range_alloc_block_size", "report_host", "report_password", "report_port",
+	"report_user", "require_secure_transport", "secure_auth", "secure_file_priv",
+	"secure_timestamp", "server_id", "simple_password_check_digits",
+	"simple_password_check_letters_same_case", "simple_password_check_minimal_length",
+	"simple_password_check_other_characters", "skip_grant_tables", "skip_host_cache",
+	"skip_name_resolve", "skip_networking", "skip_show_database", "skip_slave_start",
+	"socket", "stack_trace", "strict_password_validation", "symbolic_links",
+	"system_versioning_alter_history", "time_zone", "tmpdir", "transaction_alloc_block_size",
+	"transaction_isolation", "transaction_prealloc_size", "transaction_read_only",
+	"tx_isolation", "tx_read_only", "unix_socket", "use_stat_tables", "user",
+	"userstat",
+}
+
+// isKnownServerOption returns whether a normalized option name is a known
+// server option, possibly with a skip, enable or disable prefix for booleans
+func isKnownServerOption(name string) bool {
+	for _, prefix := range []string{"", "skip_", "enable_", "disable_"} {
+		option, found := strings.CutPrefix(name, prefix)
+		if !found {
+			continue
+		}
+		if slices.Contains(knownServerOptions, option) {
+			return true
+		}
+		for _, p := range knownServerOptionPrefixes {
+			if strings.HasPrefix(option, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,604 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestIsServerDowngrade(t *testing.T) {
+	tests := []struct {
+		name      string
+		from      string
+		image     string
+		downgrade bool
+	}{
+		{
+			name:      "Same release series",
+			from:      "10.11.6-MariaDB-log",
+			image:     "quay.io/mariadb/server:10.11.8",
+			downgrade: false,
+		},
+		{
+			name:      "Minor downgrade within a series",
+			from:      "10.11.6-MariaDB-log",
+			image:     "quay.io/mariadb/server:10.11.2",
+			downgrade: false,
+		},
+		{
+			name:      "Upgrade to a newer series",
+			from:      "10.6.16-MariaDB",
+			image:     "quay.io/mariadb/server:10.11.6",
+			downgrade: false,
+		},
+		{
+			name:      "Downgrade to an older series",
+			from:      "10.11.6-MariaDB",
+			image:     "quay.io/mariadb/server:10.6.16",
+			downgrade: true,
+		},
+		{
+			name:      "Downgrade to an older major version",
+			from:      "11.4.2-MariaDB",
+			image:     "registry.local:5000/mariadb:10.11-ubi9",
+			downgrade: true,
+		},
+		{
+			name:      "Image tag without version",
+			from:      "10.5.22-MariaDB",
+			image:     "quay.io/podified-antelope-centos9/openstack-mariadb:current-podified",
+			downgrade: false,
+		},
+		{
+			name:      "Untagged image with registry port",
+			from:      "10.5.22-MariaDB",
+			image:     "registry.local:5000/mariadb",
+			downgrade: false,
+		},
+		{
+			name:      "Unknown running version",
+			from:      "",
+			image:     "quay.io/mariadb/server:10.3.39",
+			downgrade: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(IsServerDowngrade(tt.from, ImageTagVersion(tt.image))).To(Equal(tt.downgrade))
+		})
+	}
+}
+
+func TestBootstrapQuorum(t *testing.T) {
+	start := metav1.NewTime(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	majority := &GaleraBootstrapPolicy{
+		Mode:    GaleraBootstrapModeMajority,
+		Timeout: metav1.Duration{Duration: 10 * time.Minute},
+	}
+	tests := []struct {
+		name     string
+		replicas int32
+		policy   *GaleraBootstrapPolicy
+		start    *metav1.Time
+		now      time.Time
+		quorum   int
+	}{
+		{
+			name:     "No bootstrap policy",
+			replicas: 3,
+			policy:   nil,
+			start:    &start,
+			now:      start.Add(time.Hour),
+			quorum:   3,
+		},
+		{
+			name:     "AllNodes bootstrap policy",
+			replicas: 3,
+			policy:   &GaleraBootstrapPolicy{Mode: GaleraBootstrapModeAllNodes},
+			start:    &start,
+			now:      start.Add(time.Hour),
+			quorum:   3,
+		},
+		{
+			name:     "Majority before the timeout",
+			replicas: 3,
+			policy:   majority,
+			start:    &start,
+			now:      start.Add(5 * time.Minute),
+			quorum:   3,
+		},
+		{
+			name:     "Majority of an odd cluster after the timeout",
+			replicas: 5,
+			policy:   majority,
+			start:    &start,
+			now:      start.Add(10 * time.Minute),
+			quorum:   3,
+		},
+		{
+			name:     "Majority of an even cluster after the timeout",
+			replicas: 4,
+			policy:   majority,
+			start:    &start,
+			now:      start.Add(time.Hour),
+			quorum:   3,
+		},
+		{
+			name:     "Majority without any inspected node",
+			replicas: 3,
+			policy:   majority,
+			start:    nil,
+			now:      start.Add(time.Hour),
+			quorum:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			instance := Galera{
+				Spec: GaleraSpec{
+					GaleraSpecCore: GaleraSpecCore{
+						Replicas:        &tt.replicas,
+						BootstrapPolicy: tt.policy,
+					},
+				},
+				Status: GaleraStatus{
+					InspectionStartTime: tt.start,
+				},
+			}
+			g.Expect(instance.BootstrapQuorum(tt.now)).To(Equal(tt.quorum))
+		})
+	}
+}
+
+func TestTuning(t *testing.T) {
+	memory := func(q string) corev1.ResourceList {
+		return corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(q)}
+	}
+	tests := []struct {
+		name      string
+		resources corev1.ResourceRequirements
+		storage   string
+		custom    string
+		tuning    GaleraTuning
+	}{
+		{
+			name:    "No memory set",
+			storage: "10G",
+			tuning:  GaleraTuning{},
+		},
+		{
+			name:      "Memory limit",
+			resources: corev1.ResourceRequirements{Limits: memory("4Gi"), Requests: memory("1Gi")},
+			storage:   "10G",
+			tuning: GaleraTuning{
+				InnodbBufferPoolSize: "2048M",
+				InnodbLogFileSize:    "512M",
+				MaxConnections:       "512",
+				GcacheSize:           "476M",
+			},
+		},
+		{
+			name:      "Small memory request",
+			resources: corev1.ResourceRequirements{Requests: memory("1Gi")},
+			storage:   "5G",
+			tuning: GaleraTuning{
+				InnodbBufferPoolSize: "512M",
+				InnodbLogFileSize:    "128M",
+				MaxConnections:       "151",
+				GcacheSize:           "238M",
+			},
+		},
+		{
+			name:      "512Mi memory limit",
+			resources: corev1.ResourceRequirements{Limits: memory("512Mi")},
+			storage:   "5G",
+			tuning: GaleraTuning{
+				InnodbBufferPoolSize: "170M",
+				InnodbLogFileSize:    "48M",
+				MaxConnections:       "151",
+				GcacheSize:           "238M",
+			},
+		},
+		{
+			name:      "Memory limit below the headroom",
+			resources: corev1.ResourceRequirements{Limits: memory("256Mi")},
+			storage:   "5G",
+			tuning: GaleraTuning{
+				InnodbBufferPoolSize: "32M",
+				InnodbLogFileSize:    "48M",
+				MaxConnections:       "151",
+				GcacheSize:           "238M",
+			},
+		},
+		{
+			name:      "Large memory limit",
+			resources: corev1.ResourceRequirements{Limits: memory("64Gi")},
+			storage:   "500G",
+			tuning: GaleraTuning{
+				InnodbBufferPoolSize: "32768M",
+				InnodbLogFileSize:    "4096M",
+				MaxConnections:       "8192",
+				GcacheSize:           "4096M",
+			},
+		},
+		{
+			name:      "Redo log bounded by the storage",
+			resources: corev1.ResourceRequirements{Limits: memory("16Gi")},
+			storage:   "5G",
+			tuning: GaleraTuning{
+				InnodbBufferPoolSize: "8192M",
+				InnodbLogFileSize:    "476M",
+				MaxConnections:       "2048",
+				GcacheSize:           "238M",
+			},
+		},
+		{
+			name:      "Options set in the custom config",
+			resources: corev1.ResourceRequirements{Limits: memory("4Gi")},
+			storage:   "10G",
+			custom:    "[mysqld]\ninnodb-buffer-pool-size = 1G\nwsrep_provider_options = gcache.size=2G;gcache.recover=yes\n",
+			tuning: GaleraTuning{
+				InnodbLogFileSize: "512M",
+				MaxConnections:    "512",
+			},
+		},
+		{
+			name:      "Client options in the custom config",
+			resources: corev1.ResourceRequirements{Limits: memory("4Gi")},
+			storage:   "10G",
+			custom:    "[client]\nmax_connections = 10\n",
+			tuning: GaleraTuning{
+				InnodbBufferPoolSize: "2048M",
+				InnodbLogFileSize:    "512M",
+				MaxConnections:       "512",
+				GcacheSize:           "476M",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			instance := Galera{}
+			instance.Spec.Resources = tt.resources
+			instance.Spec.StorageRequest = tt.storage
+			instance.Spec.CustomServiceConfig = tt.custom
+			g.Expect(instance.Tuning()).To(Equal(tt.tuning))
+		})
+	}
+}
+
+func TestClusterConfigOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		custom  string
+		options map[string]string
+	}{
+		{
+			name:    "No custom config",
+			custom:  "",
+			options: map[string]string{},
+		},
+		{
+			name:    "Rolling options only",
+			custom:  "[mysqld]\nmax_connections = 100\nwsrep_provider_options = gcache.size=2G\n",
+			options: map[string]string{},
+		},
+		{
+			name:   "Cluster options",
+			custom: "[galera]\nwsrep-cluster-name = \"openstack\"\nloose-wsrep_gtid_mode = ON\nwsrep_provider_options = 'gmcast.segment=1; gcache.size=2G'\n",
+			options: map[string]string{
+				"wsrep_cluster_name": "openstack",
+				"wsrep_gtid_mode":    "ON",
+				"gmcast.segment":     "1",
+			},
+		},
+		{
+			name:   "Last provider options win",
+			custom: "[mysqld]\nwsrep_provider_options = gmcast.segment=1\n[galera]\nwsrep_provider_options = socket.ssl=yes\n",
+			options: map[string]string{
+				"socket.ssl": "yes",
+			},
+		},
+		{
+			name:    "Client options",
+			custom:  "[client]\nwsrep_cluster_name = other\n",
+			options: map[string]string{},
+		},
+		{
+			name:   "Client and server options",
+			custom: "[client-server]\nwsrep_cluster_name = other\n",
+			options: map[string]string{
+				"wsrep_cluster_name": "other",
+			},
+		},
+		{
+			name:   "Inline comments",
+			custom: "[galera] # cluster options\nwsrep_cluster_name = openstack # the name\nwsrep_provider_options = 'gmcast.segment=1 # not a comment' # a comment\n",
+			options: map[string]string{
+				"wsrep_cluster_name": "openstack",
+				"gmcast.segment":     "1 # not a comment",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			instance := Galera{}
+			instance.Spec.CustomServiceConfig = tt.custom
+			g.Expect(instance.ClusterConfigOptions()).To(Equal(tt.options))
+		})
+	}
+}
+
+func TestValidateCustomServiceConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		custom   string
+		errors   int
+		warnings int
+	}{
+		{
+			name:   "Valid config",
+			custom: "[mysqld]\nmax_connections = 100\nskip-name-resolve\n\n[galera]\nwsrep_provider_options = gcache.size=2G\n",
+		},
+		{
+			name:   "Malformed section",
+			custom: "[mysqld\nmax_connections = 100\n",
+			errors: 2,
+		},
+		{
+			name:   "Option without a section",
+			custom: "max_connections = 100\n[mysqld]\n",
+			errors: 1,
+		},
+		{
+			name:   "Malformed option",
+			custom: "[mysqld]\nmax connections = 100\n",
+			errors: 1,
+		},
+		{
+			name:   "Operator-managed options",
+			custom: "[mysqld]\nbind-address = 0.0.0.0\nwsrep_cluster_address = gcomm://\nwsrep_provider_options = \"gmcast.listen_addr=tcp://0.0.0.0:4567;gcache.size=2G\"\n",
+			errors: 3,
+		},
+		{
+			name:   "Operator-managed options in a client section",
+			custom: "[client]\nsocket = /tmp/mysql.sock\n",
+		},
+		{
+			name:     "Unknown options",
+			custom:   "[mysqld]\nmax_conections = 100\nloose-some_plugin_option = 1\n",
+			warnings: 1,
+		},
+		{
+			name:   "Options of the tools",
+			custom: "[mysqld_safe]\nnice = 0\nsocket = /tmp/mysql.sock\n\n[mariabackup]\nparallel = 4\n\n[isamchk]\nkey_buffer_size = 16M\n",
+		},
+		{
+			name:     "Versioned server sections",
+			custom:   "[mariadb-10.11]\nmax_conections = 100\n\n[mysqld-8.0]\nbind-address = 0.0.0.0\n\n[mariadbd]\ntmpdir = /tmp\n",
+			errors:   1,
+			warnings: 1,
+		},
+		{
+			name:   "Operator-managed options in a client and server section",
+			custom: "[client-server]\nsocket = /tmp/mysql.sock\n",
+			errors: 1,
+		},
+		{
+			name:   "Inline comments",
+			custom: "[mysqld] # server\nmax_connections = 100 # more connections\n# bind-address = 0.0.0.0\nskip-name-resolve # no DNS\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			spec := GaleraSpecCore{CustomServiceConfig: tt.custom}
+			warn, errs := spec.ValidateCustomServiceConfig(field.NewPath("spec"))
+			g.Expect(errs).To(HaveLen(tt.errors))
+			g.Expect(warn).To(HaveLen(tt.warnings))
+		})
+	}
+}
+
+func TestValidateOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		override *GaleraPodSpecOverride
+		metrics  bool
+		errors   int
+	}{
+		{
+			name: "No override",
+		},
+		{
+			name: "Galera container and new volume",
+			override: &GaleraPodSpecOverride{
+				Containers: []corev1.Container{{Name: "galera", Env: []corev1.EnvVar{{Name: "TZ", Value: "UTC"}}}},
+				Volumes:    []corev1.Volume{{Name: "extra"}},
+			},
+		},
+		{
+			name: "Sidecar container",
+			override: &GaleraPodSpecOverride{
+				Containers: []corev1.Container{{Name: "mysqld-exporter"}},
+			},
+			metrics: true,
+		},
+		{
+			name: "Disabled sidecar container",
+			override: &GaleraPodSpecOverride{
+				Containers: []corev1.Container{{Name: "mysqld-exporter"}},
+			},
+			errors: 1,
+		},
+		{
+			name: "Unknown container",
+			override: &GaleraPodSpecOverride{
+				Containers: []corev1.Container{{Name: "sidecar"}},
+			},
+			errors: 1,
+		},
+		{
+			name: "Galera volumes",
+			override: &GaleraPodSpecOverride{
+				Volumes: []corev1.Volume{{Name: "mysql-db"}, {Name: "secrets"}, {Name: "extra"}},
+			},
+			errors: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			spec := GaleraSpecCore{Override: GaleraOverrideSpec{Spec: tt.override}}
+			if tt.metrics {
+				spec.Metrics = &GaleraMetrics{}
+			}
+			errs := spec.ValidateOverride(field.NewPath("spec"))
+			g.Expect(errs).To(HaveLen(tt.errors))
+		})
+	}
+}
+
+func TestValidateGaleraReplicas(t *testing.T) {
+	tests := []struct {
+		name       string
+		replicas   int32
+		allowEven  bool
+		arbitrator bool
+		errors     int
+		warnings   []string
+	}{
+		{
+			name:     "Odd replicas",
+			replicas: 3,
+		},
+		{
+			name:     "Even replicas",
+			replicas: 4,
+			errors:   1,
+		},
+		{
+			name:      "Even replicas allowed",
+			replicas:  4,
+			allowEven: true,
+			warnings:  []string{"spec.replicas: 4 is not appropriate for quorum! Use an odd value!"},
+		},
+		{
+			name:      "Two replicas allowed",
+			replicas:  2,
+			allowEven: true,
+			warnings: []string{
+				"spec.replicas: 2 is not appropriate for quorum! Use an odd value!",
+				"spec.replicas: a node drain can evict one of the 2 galera pods, add an arbitrator to keep a quorum",
+			},
+		},
+		{
+			name:       "Two replicas and an arbitrator",
+			replicas:   2,
+			arbitrator: true,
+		},
+		{
+			name:       "Odd replicas and an arbitrator",
+			replicas:   3,
+			arbitrator: true,
+			warnings:   []string{"spec.arbitrator: an arbitrator with 3 replicas is not appropriate for quorum! Use an even value!"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			spec := GaleraSpecCore{Replicas: &tt.replicas, AllowEvenReplicas: tt.allowEven}
+			if tt.arbitrator {
+				spec.Arbitrator = &GaleraArbitrator{}
+			}
+			warn, errs := spec.ValidateGaleraReplicas(field.NewPath("spec"))
+			g.Expect(errs).To(HaveLen(tt.errors))
+			if tt.warnings == nil {
+				g.Expect(warn).To(BeEmpty())
+			} else {
+				g.Expect(warn).To(Equal(admission.Warnings(tt.warnings)))
+			}
+		})
+	}
+}
+
+func TestValidateGaleraConfigTemplate(t *testing.T) {
+	g := NewWithT(t)
+
+	// the options of the default galera config, minus the ones managed
+	// by the operator, are valid options for the CustomServiceConfig
+	content, err := os.ReadFile("../../templates/galera/config/galera.cnf.in")
+	g.Expect(err).ToNot(HaveOccurred())
+	tmpl, err := template.New("galera.cnf.in").Parse(string(content))
+	g.Expect(err).ToNot(HaveOccurred())
+	config := &strings.Builder{}
+	err = tmpl.Execute(config, map[string]interface{}{
+		"logToDisk": true,
+		"logBin":    true,
+		"sstMethod": GaleraSSTMethodRsync,
+		"tuning": GaleraTuning{
+			InnodbBufferPoolSize: "2048M",
+			InnodbLogFileSize:    "512M",
+			MaxConnections:       "1024",
+			GcacheSize:           "476M",
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	spec := GaleraSpecCore{CustomServiceConfig: config.String()}
+	warn, errs := spec.ValidateCustomServiceConfig(field.NewPath("spec"))
+	g.Expect(warn).To(BeEmpty())
+	managed := []string{}
+	for _, err := range errs {
+		managed = append(managed, err.Detail)
+	}
+	g.Expect(managed).To(ConsistOf(
+		ContainSubstring("option bind_address is managed"),
+		ContainSubstring("option datadir is managed"),
+		ContainSubstring("option socket is managed"),
+		ContainSubstring("option wsrep_notify_cmd is managed"),
+		ContainSubstring("provider option gmcast.listen_addr is managed"),
+	))
+}
@@ -17,31 +17,103 @@ limitations under the License.
 package v1beta1
 
 import (
+	"time"
+
 	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	"github.com/openstack-k8s-operators/lib-common/modules/common/service"
 	"github.com/openstack-k8s-operators/lib-common/modules/common/tls"
 	"github.com/openstack-k8s-operators/lib-common/modules/common/util"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
+	// DbRootPasswordHash - hash of the root password currently in use by the galera nodes
+	DbRootPasswordHash = "DbRootPassword"
+
+	// DbRootPasswordInitialHash - hash of the root password in use when the galera CR was first reconciled
+	DbRootPasswordInitialHash = "DbRootPasswordInitial"
+
+	// ClusterConfigOptionsHash - hash of the options of the CustomServiceConfig that
+	// are part of the cluster properties
+	ClusterConfigOptionsHash = "ClusterConfigOptions"
+
 	// CustomServiceConfigFile name of the additional mariadb config file
 	CustomServiceConfigFile = "galera_custom.cnf.in"
 
 	// GaleraContainerImage is the fall-back container image for Galera
 	GaleraContainerImage = "quay.io/podified-antelope-centos9/openstack-mariadb:current-podified"
 
+	// GaleraExporterContainerImage is the fall-back container image for the mysqld exporter
+	GaleraExporterContainerImage = "quay.io/prometheus/mysqld-exporter:v0.15.1"
+
+	// MetricsUserPasswordHash - hash of the password of the monitoring user currently set in the database
+	MetricsUserPasswordHash = "MetricsUserPassword"
+
+	// SSTUserPasswordHash - hash of the password of the SST user currently set in the database
+	SSTUserPasswordHash = "SSTUserPassword"
+
 	storageRequestProdMin = "5G"
 
+	// GaleraForceBootstrapAnnotation names the pod from which the galera cluster
+	// must be bootstrapped, when the controller can't pick a node by itself.
+	// The controller removes the annotation once it has been honoured
+	GaleraForceBootstrapAnnotation = "mariadb.openstack.org/force-bootstrap"
+
+	// GaleraMaintenanceAnnotation puts galera nodes in maintenance. It is either set
+	// on a galera pod, or on the galera CR with a comma-separated list of pod names.
+	// A node in maintenance no longer receives traffic and is desynced from the
+	// cluster, until the annotation is removed
+	GaleraMaintenanceAnnotation = "mariadb.openstack.org/maintenance"
+
+	// GaleraBootstrapModeAllNodes - bootstrap once all the galera nodes have been inspected
+	GaleraBootstrapModeAllNodes GaleraBootstrapMode = "AllNodes"
+
+	// GaleraBootstrapModeMajority - bootstrap from a strict majority of inspected galera nodes after a timeout
+	GaleraBootstrapModeMajority GaleraBootstrapMode = "Majority"
+
+	// GaleraSSTMethodRsync - state snapshot transfers copy the data files with rsync,
+	// the donor node is blocked for the whole transfer
+	GaleraSSTMethodRsync GaleraSSTMethod = "rsync"
+
+	// GaleraSSTMethodMariabackup - state snapshot transfers stream a backup taken
+	// with mariabackup, the donor node keeps serving queries during the transfer
+	GaleraSSTMethodMariabackup GaleraSSTMethod = "mariabackup"
+
+	// GaleraNodeStateRunning - the galera node runs and takes part in the cluster
+	GaleraNodeStateRunning GaleraNodeState = "Running"
+
+	// GaleraNodeStateMaintenance - the galera node is desynced and receives no traffic
+	GaleraNodeStateMaintenance GaleraNodeState = "Maintenance"
+
+	// GaleraServiceModeActivePassive - the database service sends all the traffic to a single galera node
+	GaleraServiceModeActivePassive GaleraServiceMode = "ActivePassive"
+
+	// GaleraServiceModeActiveActive - the database service balances the traffic across all the synced galera nodes
+	GaleraServiceModeActiveActive GaleraServiceMode = "ActiveActive"
+
 	// CrMaxLengthCorrection - DNS1123LabelMaxLength (63) - CrMaxLengthCorrection used in validation to
 	// omit issue with statefulset pod label "controller-revision-hash": "<statefulset_name>-<hash>"
 	// Int32 is a 10 character + hyphen = 11 + len(-galera) = 17
 	CrMaxLengthCorrection = 17
 )
 
+// GaleraSSTMethod defines how a joining galera node receives a full copy of the data
+type GaleraSSTMethod string
+
+// GaleraNodeState is the state of a running galera node, as managed by the operator
+type GaleraNodeState string
+
+// GaleraServiceMode defines how the galera nodes are exposed by the database service
+type GaleraServiceMode string
+
 // GaleraSpec defines the desired state of Galera
 type GaleraSpec struct {
 	GaleraSpecCore `json:",inline"`
-	// Name of the galera container image to run (will be set to environmental default if empty)
+	// Name of the galera container image to run (will be set to environmental default if empty).
+	// A change of image that downgrades the MariaDB server is refused when the image tag is a
+	// version (e.g. "10.11.6"). For other tags, such as "current-podified", the downgrade is only
+	// detected during the rollout, which is then rolled back
 	// +kubebuilder:validation:Required
 	ContainerImage string `json:"containerImage"`
 }
@@ -58,17 +130,29 @@ type GaleraSpecCore struct {
 	// +kubebuilder:validation:Required
 	StorageRequest string `json:"storageRequest"`
 	// +kubebuilder:validation:Minimum=0
-	// +kubebuilder:validation:Maximum=3
+	// +kubebuilder:validation:Maximum=9
 	// +kubebuilder:default=1
 	// Size of the galera cluster deployment
 	Replicas *int32 `json:"replicas"`
 	// +kubebuilder:validation:Optional
+	// Allow an even number of replicas. An even-sized galera cluster loses
+	// quorum when it gets split in two halves, e.g. by a network partition
+	AllowEvenReplicas bool `json:"allowEvenReplicas,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Spread the galera pods evenly across the zones of the worker nodes, so that
+	// a cluster of more than three nodes keeps its quorum when a zone is lost.
+	// Changing it restarts the galera pods one at a time
+	ZoneSpread bool `json:"zoneSpread,omitempty"`
+	// +kubebuilder:validation:Optional
 	// NodeSelector to target subset of worker nodes running this service
 	NodeSelector *map[string]string `json:"nodeSelector,omitempty"`
 	// +kubebuilder:validation:Optional
 	// Customize config using this parameter to change service defaults,
 	// or overwrite rendered information using raw MariaDB config format.
 	// The content gets added to /etc/my.cnf.d/galera_custom.cnf
+	// Options that all the galera nodes must agree on (e.g. wsrep_cluster_name
+	// or gmcast.segment) are applied with a full cluster restart, the other
+	// options are rolled out one galera pod at a time
 	CustomServiceConfig string `json:"customServiceConfig,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
@@ -80,6 +164,159 @@ type GaleraSpecCore struct {
 	// +kubebuilder:validation:Optional
 	// Log Galera pod's output to disk
 	LogToDisk bool `json:"logToDisk"`
+	// +kubebuilder:validation:Optional
+	// Take recurring backups of the galera cluster
+	Backup *GaleraBackupSchedule `json:"backup,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Enable the binary log on the galera nodes and continuously archive it, to allow
+	// a GaleraRestore to replay transactions up to a point in time after a backup
+	BinlogArchive *GaleraBinlogArchive `json:"binlogArchive,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=ActivePassive;ActiveActive
+	// +kubebuilder:default=ActivePassive
+	// How the database service exposes the galera nodes. ActivePassive sends all
+	// the traffic to a single node. ActiveActive balances the traffic across all
+	// the synced nodes, for workloads that tolerate certification conflicts
+	ServiceMode GaleraServiceMode `json:"serviceMode,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Deploy a galera arbitrator (garbd) joined to the galera cluster. The arbitrator
+	// takes part in the quorum without storing any data, so that a cluster with an
+	// even number of replicas keeps its quorum when half of the nodes are lost
+	Arbitrator *GaleraArbitrator `json:"arbitrator,omitempty"`
+	// +kubebuilder:validation:Optional
+	// How the controller picks the node to bootstrap a stopped galera cluster from
+	BootstrapPolicy *GaleraBootstrapPolicy `json:"bootstrapPolicy,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Expose Prometheus metrics of the galera nodes with a mysqld exporter sidecar
+	Metrics *GaleraMetrics `json:"metrics,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=rsync;mariabackup
+	// +kubebuilder:default=rsync
+	// Method used by a galera node to get a full copy of the data (state snapshot
+	// transfer) from a donor when it joins the cluster. With mariabackup, the donor
+	// keeps serving queries during the transfer
+	SSTMethod GaleraSSTMethod `json:"sstMethod,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Compute resources of the galera container. When a memory limit (or request)
+	// is set, the InnoDB buffer pool, the InnoDB redo log, the maximum number of
+	// connections and the galera cache are sized after it and after the storage
+	// request, unless they are set explicitly in customServiceConfig
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Override the pod template of the galera StatefulSet
+	Override GaleraOverrideSpec `json:"override,omitempty"`
+}
+
+// GaleraOverrideSpec defines the overrides of the galera pod template. They are
+// applied as a strategic merge patch on the pod template generated by the operator
+type GaleraOverrideSpec struct {
+	// +kubebuilder:validation:Optional
+	// Labels and annotations added to the galera pods. They can't
+	// replace the labels set by the operator
+	*service.EmbeddedLabelsAnnotations `json:"metadata,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Settings of the galera pods
+	Spec *GaleraPodSpecOverride `json:"spec,omitempty"`
+}
+
+// GaleraPodSpecOverride is the subset of the fields of a PodSpec that can be
+// overridden in the galera pods. Lists are merged with the ones generated by
+// the operator according to the PodSpec patch strategy, e.g. containers with the
+// same name are merged and topology spread constraints with the same topology key
+// are replaced. The volumes generated by the operator can't be replaced
+type GaleraPodSpecOverride struct {
+	// +kubebuilder:validation:Optional
+	// Settings of the containers of the galera pods, e.g. the resources of a
+	// sidecar. They are merged by name with the containers generated by the operator
+	Containers []corev1.Container `json:"containers,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Tolerations of the galera pods
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Affinity of the galera pods. By default, the pods are preferably
+	// scheduled on different worker nodes
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Topology spread constraints of the galera pods. By default, the pods are
+	// preferably spread across zones
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Priority class of the galera pods
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Security context of the galera pods
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Extra volumes of the galera pods, their name must differ from the
+	// volumes generated by the operator
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+}
+
+// GaleraMetrics defines the Prometheus exporter of the galera nodes
+type GaleraMetrics struct {
+	// +kubebuilder:validation:Optional
+	// Name of the mysqld exporter container image (will be set to environmental default if empty)
+	ContainerImage string `json:"containerImage,omitempty"`
+}
+
+// GaleraBootstrapMode selects which galera nodes must be inspected before bootstrapping
+type GaleraBootstrapMode string
+
+// GaleraBootstrapPolicy defines how a stopped galera cluster gets bootstrapped
+type GaleraBootstrapPolicy struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=AllNodes;Majority
+	// +kubebuilder:default=AllNodes
+	// AllNodes waits until the seqno of every galera node is known. Majority bootstraps
+	// from the node with the highest seqno among a strict majority of inspected nodes,
+	// once the timeout expires. Transactions only committed on the nodes that could not
+	// be inspected are lost
+	Mode GaleraBootstrapMode `json:"mode,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default="10m"
+	// How long to wait for all the galera nodes to be inspected before
+	// bootstrapping from a majority of them
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// GaleraArbitrator defines the deployment of a galera arbitrator
+type GaleraArbitrator struct {
+	// +kubebuilder:validation:Optional
+	// NodeSelector to target the worker node running the arbitrator. It should
+	// select a different failure domain than the one of the galera nodes
+	NodeSelector *map[string]string `json:"nodeSelector,omitempty"`
+}
+
+// GaleraBinlogArchive defines where the binary logs of the galera nodes are archived
+type GaleraBinlogArchive struct {
+	// Name of an existing PersistentVolumeClaim that stores the archived binary logs.
+	// It is shared by all the galera pods, so it must support the ReadWriteMany access mode
+	// +kubebuilder:validation:Required
+	ClaimName string `json:"claimName"`
+}
+
+// GaleraBackupSchedule defines recurring backups of a galera cluster
+type GaleraBackupSchedule struct {
+	// Schedule of the backups, in cron format (e.g. "0 2 * * *")
+	// +kubebuilder:validation:Required
+	Schedule string `json:"schedule"`
+	// +kubebuilder:validation:Optional
+	// Which successful backups to keep. Older backups and their artifacts are pruned
+	Retention GaleraBackupRetention `json:"retention,omitempty"`
+	// Storage where the backup artifacts are written
+	// +kubebuilder:validation:Required
+	Storage GaleraBackupStorage `json:"storage"`
+}
+
+// GaleraBackupRetention defines how long scheduled backups are kept
+type GaleraBackupRetention struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// Number of successful backups to keep. 0 means no limit
+	Count int32 `json:"count,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Maximum age of the backups to keep (e.g. "168h"). No limit if unset
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
 }
 
 // GaleraAttributes holds startup information for a Galera host
@@ -98,10 +335,69 @@ type GaleraAttributes struct {
 	ContainerID string `json:"containerID,omitempty"`
 }
 
+// GaleraNodeStatus reports the wsrep state of a running galera node
+type GaleraNodeStatus struct {
+	// Number of members of the galera cluster, as seen by the node (wsrep_cluster_size)
+	ClusterSize int32 `json:"clusterSize"`
+	// UUID of the state of the cluster (wsrep_cluster_state_uuid)
+	StateUUID string `json:"stateUUID,omitempty"`
+	// State of the node, e.g. Synced or Donor/Desynced (wsrep_local_state_comment)
+	LocalState string `json:"localState,omitempty"`
+	// Fraction of time the replication was paused by flow control
+	// since the last collection (wsrep_flow_control_paused)
+	FlowControlPaused string `json:"flowControlPaused,omitempty"`
+	// Seqno of the last transaction committed by the node (wsrep_last_committed)
+	LastCommitted string `json:"lastCommitted,omitempty"`
+	// Time at which the state of the node was collected
+	LastUpdateTime metav1.Time `json:"lastUpdateTime"`
+	// State of the node, Running or Maintenance
+	State GaleraNodeState `json:"state,omitempty"`
+}
+
+// GaleraUpgradeStatus tracks the rollout of a new container image, which
+// is done one galera pod at a time
+type GaleraUpgradeStatus struct {
+	// Container image the galera pods ran before the upgrade
+	FromImage string `json:"fromImage"`
+	// Container image the galera pods are upgraded to
+	ToImage string `json:"toImage"`
+	// Version of the MariaDB server before the upgrade
+	FromVersion string `json:"fromVersion,omitempty"`
+	// Only the galera pods whose ordinal is greater than or equal to
+	// the partition are restarted with the new container image
+	Partition int32 `json:"partition"`
+	// Time at which the upgrade of the current galera pod started
+	NodeStartTime *metav1.Time `json:"nodeStartTime,omitempty"`
+	// Galera pods whose system tables were upgraded, once all the pods
+	// run the new container image
+	SystemTablesUpgraded []string `json:"systemTablesUpgraded,omitempty"`
+	// Why the upgrade failed. When set, the galera pods are rolled back
+	// to the previous container image until containerImage changes again
+	RollbackReason string `json:"rollbackReason,omitempty"`
+}
+
+// GaleraForcedBootstrap records a bootstrap forced with the force-bootstrap annotation
+type GaleraForcedBootstrap struct {
+	// Pod from which the cluster was bootstrapped
+	Pod string `json:"pod"`
+	// Client that set the force-bootstrap annotation, as recorded in the managed fields
+	RequestedBy string `json:"requestedBy,omitempty"`
+	// Time at which the bootstrap was forced
+	Time metav1.Time `json:"time"`
+}
+
 // GaleraStatus defines the observed state of Galera
 type GaleraStatus struct {
 	// A map of database node attributes for each pod
 	Attributes map[string]GaleraAttributes `json:"attributes,omitempty"`
+	// The wsrep state of each running galera node, collected periodically
+	Nodes map[string]GaleraNodeStatus `json:"nodes,omitempty"`
+	// Number of members of the galera cluster
+	ClusterSize int32 `json:"clusterSize,omitempty"`
+	// Number of galera nodes in the Synced state
+	SyncedNodes int32 `json:"syncedNodes,omitempty"`
+	// Galera nodes put in maintenance with the maintenance annotation
+	Maintenance []string `json:"maintenance,omitempty"`
 	// Name of the node that can safely bootstrap a cluster
 	SafeToBootstrap string `json:"safeToBootstrap,omitempty"`
 	// Is the galera cluster currently running
@@ -114,6 +410,23 @@ type GaleraStatus struct {
 	ClusterProperties map[string]string `json:"clusterProperties,omitempty"`
 	// Map of hashes to track input changes
 	Hash map[string]string `json:"hash,omitempty"`
+	// Name of the last successful scheduled backup
+	LastBackup string `json:"lastBackup,omitempty"`
+	// Completion time of the last successful scheduled backup
+	LastBackupTime *metav1.Time `json:"lastBackupTime,omitempty"`
+	// Time at which the last scheduled backup was started
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// Version of the MariaDB server running in the galera cluster
+	ServerVersion string `json:"serverVersion,omitempty"`
+	// Container image that all the galera pods run
+	ContainerImage string `json:"containerImage,omitempty"`
+	// Progress of the rollout of a new container image
+	Upgrade *GaleraUpgradeStatus `json:"upgrade,omitempty"`
+	// Time at which the controller started to inspect the galera nodes to
+	// find a bootstrap candidate
+	InspectionStartTime *metav1.Time `json:"inspectionStartTime,omitempty"`
+	// Last bootstrap of the cluster forced with the force-bootstrap annotation
+	ForcedBootstrap *GaleraForcedBootstrap `json:"forcedBootstrap,omitempty"`
 	// Deployment Conditions
 	Conditions condition.Conditions `json:"conditions,omitempty" optional:"true"`
 	// ObservedGeneration - the most recent generation observed for this
@@ -127,6 +440,9 @@ type GaleraStatus struct {
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[0].status",description="Ready"
 // +kubebuilder:printcolumn:name="Message",type="string",JSONPath=".status.conditions[0].message",description="Message"
+// +kubebuilder:printcolumn:name="Version",type="string",JSONPath=".status.serverVersion",description="Version"
+// +kubebuilder:printcolumn:name="Size",type="integer",JSONPath=".status.clusterSize",description="Cluster size"
+// +kubebuilder:printcolumn:name="Synced",type="integer",JSONPath=".status.syncedNodes",description="Synced nodes"
 
 // Galera is the Schema for the galeras API
 type Galera struct {
@@ -155,6 +471,27 @@ func (instance Galera) IsReady() bool {
 	return instance.Status.Conditions.IsTrue(condition.DeploymentReadyCondition)
 }
 
+// IsActiveActive - returns true if the database service balances the traffic
+// across all the synced galera nodes
+func (instance Galera) IsActiveActive() bool {
+	return instance.Spec.ServiceMode == GaleraServiceModeActiveActive
+}
+
+// BootstrapQuorum - returns the number of galera nodes that must be inspected
+// before the cluster can be bootstrapped, at a given time. With the Majority bootstrap
+// policy, a strict majority of the nodes is enough once the timeout expired
+func (instance Galera) BootstrapQuorum(now time.Time) int {
+	replicas := int(*instance.Spec.Replicas)
+	policy := instance.Spec.BootstrapPolicy
+	if policy == nil || policy.Mode != GaleraBootstrapModeMajority || instance.Status.InspectionStartTime == nil {
+		return replicas
+	}
+	if now.Before(instance.Status.InspectionStartTime.Add(policy.Timeout.Duration)) {
+		return replicas
+	}
+	return replicas/2 + 1
+}
+
 // RbacConditionsSet - sets the conditions for the rbac object
 func (instance Galera) RbacConditionsSet(c *condition.Condition) {
 	instance.Status.Conditions.Set(c)
@@ -174,7 +511,8 @@ func (instance Galera) RbacResourceName() string {
 func SetupDefaults() {
 	// Acquire environmental defaults and initialize Keystone defaults with them
 	galeraDefaults := GaleraDefaults{
-		ContainerImageURL: util.GetEnvVar("RELATED_IMAGE_MARIADB_IMAGE_URL_DEFAULT", GaleraContainerImage),
+		ContainerImageURL:         util.GetEnvVar("RELATED_IMAGE_MARIADB_IMAGE_URL_DEFAULT", GaleraContainerImage),
+		ExporterContainerImageURL: util.GetEnvVar("RELATED_IMAGE_MYSQLD_EXPORTER_IMAGE_URL_DEFAULT", GaleraExporterContainerImage),
 	}
 
 	SetupGaleraDefaults(galeraDefaults)
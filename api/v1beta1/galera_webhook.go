@@ -17,10 +17,16 @@ limitations under the License.
 package v1beta1
 
 import (
+	"encoding/json"
 	"fmt"
+	"slices"
 
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -35,7 +41,8 @@ var galeralog = logf.Log.WithName("galera-resource")
 
 // GaleraDefaults -
 type GaleraDefaults struct {
-	ContainerImageURL string
+	ContainerImageURL         string
+	ExporterContainerImageURL string
 }
 
 var galeraDefaults GaleraDefaults
@@ -64,6 +71,9 @@ func (spec *GaleraSpec) Default() {
 	if spec.ContainerImage == "" {
 		spec.ContainerImage = galeraDefaults.ContainerImageURL
 	}
+	if spec.Metrics != nil && spec.Metrics.ContainerImage == "" {
+		spec.Metrics.ContainerImage = galeraDefaults.ExporterContainerImageURL
+	}
 	spec.GaleraSpecCore.Default()
 }
 
@@ -120,8 +130,16 @@ func (spec *GaleraSpecCore) ValidateCreate(basePath *field.Path) (admission.Warn
 	warn, _ := common_webhook.ValidateStorageRequest(basePath, spec.StorageRequest, storageRequestProdMin, false)
 	allWarn = append(allWarn, warn...)
 
-	warn = spec.ValidateGaleraReplicas(basePath)
+	warn, errs := spec.ValidateGaleraReplicas(basePath)
+	allWarn = append(allWarn, warn...)
+	allErrs = append(allErrs, errs...)
+
+	allErrs = append(allErrs, spec.ValidateBackupSchedule(basePath)...)
+	allErrs = append(allErrs, spec.ValidateOverride(basePath)...)
+
+	warn, errs = spec.ValidateCustomServiceConfig(basePath)
 	allWarn = append(allWarn, warn...)
+	allErrs = append(allErrs, errs...)
 
 	return allWarn, allErrs
 }
@@ -137,10 +155,36 @@ func (r *Galera) ValidateUpdate(old runtime.Object) (admission.Warnings, error)
 	}
 
 	basePath := field.NewPath("spec")
-	warn := r.Spec.ValidateGaleraReplicas(basePath)
+	allErrs := r.Spec.ValidateBackupSchedule(basePath)
+
+	warn, errs := r.Spec.ValidateGaleraReplicas(basePath)
+	allWarn = append(allWarn, warn...)
+	if *r.Spec.Replicas != *oldGalera.Spec.Replicas {
+		allErrs = append(allErrs, errs...)
+	} else {
+		// do not block updates of existing clusters with an even size
+		for _, err := range errs {
+			allWarn = append(allWarn, err.Error())
+		}
+	}
+	allErrs = append(allErrs, r.ValidateServerDowngrade(basePath, oldGalera)...)
+	allErrs = append(allErrs, r.Spec.ValidateOverride(basePath)...)
+	allErrs = append(allErrs, r.ValidateStorageShrink(basePath, oldGalera)...)
+
+	warn, errs = r.Spec.ValidateCustomServiceConfig(basePath)
 	allWarn = append(allWarn, warn...)
+	if r.Spec.CustomServiceConfig != oldGalera.Spec.CustomServiceConfig {
+		allErrs = append(allErrs, errs...)
+	} else {
+		// do not block updates of existing clusters with an invalid config
+		for _, err := range errs {
+			allWarn = append(allWarn, err.Error())
+		}
+	}
+	if len(allErrs) != 0 {
+		return allWarn, apierrors.NewInvalid(GroupVersion.WithKind("Galera").GroupKind(), r.Name, allErrs)
+	}
 
-	// TODO(user): fill in your validation logic upon object update.
 	return allWarn, nil
 }
 
@@ -158,14 +202,181 @@ func SetupGaleraDefaults(defaults GaleraDefaults) {
 	galeralog.Info("Galera defaults initialized", "defaults", defaults)
 }
 
-// SetupGaleraDefaults - Check whether replica count is valid for quorum
-func (spec *GaleraSpecCore) ValidateGaleraReplicas(basePath *field.Path) admission.Warnings {
+// ValidateGaleraReplicas - Check whether replica count is valid for quorum.
+// An even count is an error, unless explicitly allowed in the spec or
+// completed by an arbitrator
+func (spec *GaleraSpecCore) ValidateGaleraReplicas(basePath *field.Path) (admission.Warnings, field.ErrorList) {
+	var allErrs field.ErrorList
 	replicas := int(*spec.Replicas)
+	if spec.Arbitrator != nil {
+		// the arbitrator counts as a member for quorum
+		if replicas > 0 && (replicas%2 != 0) {
+			path := basePath.Child("arbitrator")
+			res := fmt.Sprintf("%s: an arbitrator with %d replicas is not appropriate for quorum! Use an even value!",
+				path.String(), replicas)
+			return []string{res}, allErrs
+		}
+		return nil, allErrs
+	}
 	if replicas > 0 && (replicas%2 == 0) {
-		res := fmt.Sprintf("%s: %d is not appropriate for quorum! Use an odd value!",
-			basePath.Child("replicas").String(), replicas)
-		return []string{res}
-	} else {
-		return nil
+		path := basePath.Child("replicas")
+		if spec.AllowEvenReplicas {
+			res := []string{fmt.Sprintf("%s: %d is not appropriate for quorum! Use an odd value!",
+				path.String(), replicas)}
+			// the PodDisruptionBudget can't protect the quorum of two nodes
+			// without blocking node drains
+			if replicas == 2 {
+				res = append(res, fmt.Sprintf("%s: a node drain can evict one of the 2 galera pods, add an arbitrator to keep a quorum",
+					path.String()))
+			}
+			return res, allErrs
+		}
+		allErrs = append(allErrs, field.Invalid(path, replicas,
+			fmt.Sprintf("%d is not appropriate for quorum, use an odd value or set %s",
+				replicas, basePath.Child("allowEvenReplicas").String())))
+	}
+	return nil, allErrs
+}
+
+// ValidateServerDowngrade - Check that a new container image does not downgrade
+// the MariaDB server to an older release series. The version of the new image
+// is guessed from its tag, so nothing is checked for tags that are not a version,
+// like the default "current-podified". The controller checks the version of the
+// server again during the rollout
+func (r *Galera) ValidateServerDowngrade(basePath *field.Path, old *Galera) field.ErrorList {
+	var allErrs field.ErrorList
+	if r.Spec.ContainerImage == old.Spec.ContainerImage {
+		return allErrs
+	}
+	running := old.Status.ServerVersion
+	if running == "" {
+		running = ImageTagVersion(old.Spec.ContainerImage)
+	}
+	target := ImageTagVersion(r.Spec.ContainerImage)
+	if IsServerDowngrade(running, target) {
+		allErrs = append(allErrs, field.Forbidden(basePath.Child("containerImage"),
+			fmt.Sprintf("downgrading the MariaDB server from %s to %s is not supported", running, target)))
+	}
+	return allErrs
+}
+
+// ValidateStorageShrink - Check that the storage request of the galera nodes
+// does not shrink. Volumes can only be expanded
+func (r *Galera) ValidateStorageShrink(basePath *field.Path, old *Galera) field.ErrorList {
+	var allErrs field.ErrorList
+	if r.Spec.StorageRequest == old.Spec.StorageRequest {
+		return allErrs
+	}
+	path := basePath.Child("storageRequest")
+	request, err := resource.ParseQuantity(r.Spec.StorageRequest)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(path, r.Spec.StorageRequest, err.Error()))
+		return allErrs
+	}
+	previous, err := resource.ParseQuantity(old.Spec.StorageRequest)
+	if err != nil {
+		return allErrs
 	}
+	if request.Cmp(previous) < 0 {
+		allErrs = append(allErrs, field.Forbidden(path,
+			fmt.Sprintf("shrinking the storage from %s to %s is not supported", old.Spec.StorageRequest, r.Spec.StorageRequest)))
+	}
+	return allErrs
+}
+
+// ValidateBackupSchedule - Check whether the schedule of recurring backups can be parsed
+func (spec *GaleraSpecCore) ValidateBackupSchedule(basePath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.Backup == nil {
+		return allErrs
+	}
+	path := basePath.Child("backup").Child("schedule")
+	if _, err := cron.ParseStandard(spec.Backup.Schedule); err != nil {
+		allErrs = append(allErrs, field.Invalid(path, spec.Backup.Schedule, err.Error()))
+	}
+	return allErrs
+}
+
+// ValidateOverride - Check that the override of the pod template only changes
+// the containers of the galera pods, does not replace their volumes, and can be
+// applied as a strategic merge patch. The controller runs the same checks on the
+// pod template it generates
+func (spec *GaleraSpecCore) ValidateOverride(basePath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.Override.Spec == nil {
+		return allErrs
+	}
+	path := basePath.Child("override").Child("spec")
+
+	containers := spec.GaleraContainers()
+	for i, c := range spec.Override.Spec.Containers {
+		if !slices.Contains(containers, c.Name) {
+			allErrs = append(allErrs, field.NotSupported(path.Child("containers").Index(i).Child("name"), c.Name, containers))
+		}
+	}
+	for i, v := range spec.Override.Spec.Volumes {
+		if slices.Contains(galeraVolumes, v.Name) {
+			allErrs = append(allErrs, field.Forbidden(path.Child("volumes").Index(i).Child("name"),
+				fmt.Sprintf("volume %s replaces a galera volume", v.Name)))
+		}
+	}
+	if len(allErrs) != 0 {
+		return allErrs
+	}
+
+	template := corev1.PodSpec{}
+	for _, name := range containers {
+		template.Containers = append(template.Containers, corev1.Container{Name: name})
+	}
+	original, err := json.Marshal(template)
+	if err != nil {
+		return append(allErrs, field.InternalError(path, err))
+	}
+	patch, err := json.Marshal(spec.Override.Spec)
+	if err != nil {
+		return append(allErrs, field.InternalError(path, err))
+	}
+	if _, err = strategicpatch.StrategicMergePatch(original, patch, corev1.PodSpec{}); err != nil {
+		allErrs = append(allErrs, field.Invalid(path, string(patch), err.Error()))
+	}
+	return allErrs
+}
+
+// ValidateCustomServiceConfig - Check that the CustomServiceConfig can be parsed
+// as a MariaDB option file, and that it does not override the options managed
+// by the operator. Unknown option names only raise a warning, as the list of
+// known options can't cover every plugin and server version
+func (spec *GaleraSpecCore) ValidateCustomServiceConfig(basePath *field.Path) (admission.Warnings, field.ErrorList) {
+	var allErrs field.ErrorList
+	allWarn := []string{}
+	path := basePath.Child("customServiceConfig")
+
+	options, errs := parseServiceConfig(spec.CustomServiceConfig)
+	for _, err := range errs {
+		allErrs = append(allErrs, field.Invalid(path, err.content, err.Error()))
+	}
+	for _, opt := range options {
+		// the options of the client and the tools are passed through
+		if !isServerSection(opt.section) {
+			continue
+		}
+		if slices.Contains(operatorManagedOptions, opt.name) {
+			allErrs = append(allErrs, field.Forbidden(path,
+				fmt.Sprintf("line %d: option %s is managed by the operator", opt.line, opt.name)))
+			continue
+		}
+		if opt.name == "wsrep_provider_options" {
+			for name := range parseProviderOptions(opt.value) {
+				if slices.Contains(operatorManagedProviderOptions, name) {
+					allErrs = append(allErrs, field.Forbidden(path,
+						fmt.Sprintf("line %d: provider option %s is managed by the operator", opt.line, name)))
+				}
+			}
+		}
+		if !opt.loose && !isKnownServerOption(opt.name) {
+			allWarn = append(allWarn, fmt.Sprintf("%s: line %d: unknown option %s",
+				path.String(), opt.line, opt.name))
+		}
+	}
+	return allWarn, allErrs
 }
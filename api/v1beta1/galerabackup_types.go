@@ -0,0 +1,104 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// GaleraBackupHash hash
+	GaleraBackupHash = "backup"
+
+	// GaleraBackupCleanupHash hash
+	GaleraBackupCleanupHash = "cleanup"
+)
+
+// GaleraBackupStorage defines where backup artifacts are stored
+type GaleraBackupStorage struct {
+	// Name of an existing PersistentVolumeClaim that stores the backup artifacts
+	// +kubebuilder:validation:Required
+	ClaimName string `json:"claimName"`
+}
+
+// GaleraBackupSpec defines the desired state of GaleraBackup
+type GaleraBackupSpec struct {
+	// Name of the Galera CR to back up
+	// +kubebuilder:validation:Required
+	DatabaseInstance string `json:"databaseInstance"`
+	// Storage where the backup artifact is written
+	// +kubebuilder:validation:Required
+	Storage GaleraBackupStorage `json:"storage"`
+}
+
+// GaleraBackupStatus defines the observed state of GaleraBackup
+type GaleraBackupStatus struct {
+	// Name of the galera pod the backup is taken from
+	Node string `json:"node,omitempty"`
+	// Location of the backup artifact, relative to the root of the backup storage
+	Path string `json:"path,omitempty"`
+	// UUID of the galera cluster state at the time of the backup
+	UUID string `json:"uuid,omitempty"`
+	// Last replication sequence number contained in the backup
+	Seqno string `json:"seqno,omitempty"`
+	// Size of the backup artifact
+	Size string `json:"size,omitempty"`
+	// Time at which the backup started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// Time at which the backup completed
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Time it took to take the backup
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// Is the backup artifact complete and ready to be restored
+	// +kubebuilder:default=false
+	Completed bool `json:"completed"`
+	// Map of hashes to track e.g. job status
+	Hash map[string]string `json:"hash,omitempty"`
+	// Deployment Conditions
+	Conditions condition.Conditions `json:"conditions,omitempty" optional:"true"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Node",type="string",JSONPath=".status.node",description="Node"
+// +kubebuilder:printcolumn:name="Seqno",type="string",JSONPath=".status.seqno",description="Seqno"
+// +kubebuilder:printcolumn:name="Size",type="string",JSONPath=".status.size",description="Size"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[0].status",description="Ready"
+// +kubebuilder:printcolumn:name="Message",type="string",JSONPath=".status.conditions[0].message",description="Message"
+
+// GaleraBackup is the Schema for the galerabackups API
+type GaleraBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GaleraBackupSpec   `json:"spec,omitempty"`
+	Status GaleraBackupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GaleraBackupList contains a list of GaleraBackup
+type GaleraBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GaleraBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GaleraBackup{}, &GaleraBackupList{})
+}
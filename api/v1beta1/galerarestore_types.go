@@ -0,0 +1,114 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// GaleraRestoreAnnotation is set on a Galera CR while a GaleraRestore
+	// replaces its data. Its value is the name of the GaleraRestore CR.
+	// As long as it is present, the galera cluster is kept stopped
+	GaleraRestoreAnnotation = "mariadb.openstack.org/restore"
+
+	// GaleraRestoreHash hash
+	GaleraRestoreHash = "restore"
+)
+
+// GaleraRestoreSpec defines the desired state of GaleraRestore
+type GaleraRestoreSpec struct {
+	// Name of the Galera CR to restore
+	// +kubebuilder:validation:Required
+	DatabaseInstance string `json:"databaseInstance"`
+	// Name of the completed GaleraBackup CR to restore from
+	// +kubebuilder:validation:Required
+	BackupName string `json:"backupName"`
+	// +kubebuilder:validation:Optional
+	// Replay the binary logs archived after the backup was taken. Requires
+	// binlogArchive to be enabled on the Galera CR at the time of the backup.
+	// The binary logs replayed are the ones of the galera node the backup was
+	// taken from (the node in the GaleraBackup status), including the one the
+	// node was writing to when the cluster was stopped for the restore
+	PointInTime *GaleraRestorePointInTime `json:"pointInTime,omitempty"`
+}
+
+// GaleraRestorePointInTime defines up to where archived binary logs are replayed after a
+// restore. When no target is set, all the archived binary logs are replayed
+// +kubebuilder:validation:XValidation:rule="!(has(self.time) && has(self.gtid))",message="only one of time or gtid can be set"
+type GaleraRestorePointInTime struct {
+	// +kubebuilder:validation:Optional
+	// Replay the transactions committed before this time
+	Time *metav1.Time `json:"time,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Replay the transactions up to and including this GTID (e.g. "1-1-4242")
+	GTID string `json:"gtid,omitempty"`
+}
+
+// GaleraRestoreStatus defines the observed state of GaleraRestore
+type GaleraRestoreStatus struct {
+	// Name of the galera pod the backup is restored on. The cluster is bootstrapped from that pod
+	Node string `json:"node,omitempty"`
+	// UUID of the galera cluster state after the restore
+	UUID string `json:"uuid,omitempty"`
+	// Replication sequence number of the galera cluster after the restore
+	Seqno string `json:"seqno,omitempty"`
+	// Time at which the restore started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// Time at which the restore completed
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Has the backup been copied onto the galera volumes
+	// +kubebuilder:default=false
+	DataRestored bool `json:"dataRestored"`
+	// Is the galera cluster running again with the restored data
+	// +kubebuilder:default=false
+	Completed bool `json:"completed"`
+	// Map of hashes to track e.g. job status
+	Hash map[string]string `json:"hash,omitempty"`
+	// Deployment Conditions
+	Conditions condition.Conditions `json:"conditions,omitempty" optional:"true"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Backup",type="string",JSONPath=".spec.backupName",description="Backup"
+// +kubebuilder:printcolumn:name="Node",type="string",JSONPath=".status.node",description="Node"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[0].status",description="Ready"
+// +kubebuilder:printcolumn:name="Message",type="string",JSONPath=".status.conditions[0].message",description="Message"
+
+// GaleraRestore is the Schema for the galerarestores API
+type GaleraRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GaleraRestoreSpec   `json:"spec,omitempty"`
+	Status GaleraRestoreStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GaleraRestoreList contains a list of GaleraRestore
+type GaleraRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GaleraRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GaleraRestore{}, &GaleraRestoreList{})
+}
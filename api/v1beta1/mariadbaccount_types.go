@@ -28,6 +28,9 @@ const (
 	// AccountDeleteHash hash
 	AccountDeleteHash = "accountdelete"
 
+	// AccountPasswordHash hash of the DatabasePassword currently set on the account
+	AccountPasswordHash = "accountpassword"
+
 	// DbRootPassword selector for galera root account
 	DbRootPasswordSelector = "DbRootPassword"
 
@@ -48,6 +51,18 @@ type MariaDBAccountSpec struct {
 	// Account must use TLS to connect to the database
 	// +kubebuilder:default=false
 	RequireTLS bool `json:"requireTLS"`
+
+	// +kubebuilder:validation:Optional
+	// Only grant read privileges on the database to the account, e.g. for
+	// clients of the read service of the galera cluster. The read service
+	// accepts writes, this is what keeps its clients read-only
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// When the DatabasePassword in the secret changes, keep accepting the
+	// previous password for this long, so that services using the account
+	// can be restarted with the new password
+	RotationGracePeriod *metav1.Duration `json:"rotationGracePeriod,omitempty"`
 }
 
 // MariaDBAccountStatus defines the observed state of MariaDBAccount
@@ -57,6 +72,10 @@ type MariaDBAccountStatus struct {
 
 	// Map of hashes to track e.g. job status
 	Hash map[string]string `json:"hash,omitempty"`
+
+	// Time until which the previous password of the account is still
+	// accepted, while a password rotation is in its grace period
+	PreviousPasswordExpiry *metav1.Time `json:"previousPasswordExpiry,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -22,7 +22,9 @@ package v1beta1
 
 import (
 	"github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	"github.com/openstack-k8s-operators/lib-common/modules/common/service"
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -90,6 +92,32 @@ func (in *Galera) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraArbitrator) DeepCopyInto(out *GaleraArbitrator) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(map[string]string)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make(map[string]string, len(*in))
+			for key, val := range *in {
+				(*out)[key] = val
+			}
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraArbitrator.
+func (in *GaleraArbitrator) DeepCopy() *GaleraArbitrator {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraArbitrator)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GaleraAttributes) DeepCopyInto(out *GaleraAttributes) {
 	*out = *in
@@ -105,6 +133,206 @@ func (in *GaleraAttributes) DeepCopy() *GaleraAttributes {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraBackup) DeepCopyInto(out *GaleraBackup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraBackup.
+func (in *GaleraBackup) DeepCopy() *GaleraBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GaleraBackup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraBackupList) DeepCopyInto(out *GaleraBackupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GaleraBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraBackupList.
+func (in *GaleraBackupList) DeepCopy() *GaleraBackupList {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraBackupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GaleraBackupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraBackupRetention) DeepCopyInto(out *GaleraBackupRetention) {
+	*out = *in
+	if in.MaxAge != nil {
+		in, out := &in.MaxAge, &out.MaxAge
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraBackupRetention.
+func (in *GaleraBackupRetention) DeepCopy() *GaleraBackupRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraBackupRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraBackupSchedule) DeepCopyInto(out *GaleraBackupSchedule) {
+	*out = *in
+	in.Retention.DeepCopyInto(&out.Retention)
+	out.Storage = in.Storage
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraBackupSchedule.
+func (in *GaleraBackupSchedule) DeepCopy() *GaleraBackupSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraBackupSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraBackupSpec) DeepCopyInto(out *GaleraBackupSpec) {
+	*out = *in
+	out.Storage = in.Storage
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraBackupSpec.
+func (in *GaleraBackupSpec) DeepCopy() *GaleraBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraBackupStatus) DeepCopyInto(out *GaleraBackupStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Hash != nil {
+		in, out := &in.Hash, &out.Hash
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(condition.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraBackupStatus.
+func (in *GaleraBackupStatus) DeepCopy() *GaleraBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraBackupStorage) DeepCopyInto(out *GaleraBackupStorage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraBackupStorage.
+func (in *GaleraBackupStorage) DeepCopy() *GaleraBackupStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraBackupStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraBinlogArchive) DeepCopyInto(out *GaleraBinlogArchive) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraBinlogArchive.
+func (in *GaleraBinlogArchive) DeepCopy() *GaleraBinlogArchive {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraBinlogArchive)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraBootstrapPolicy) DeepCopyInto(out *GaleraBootstrapPolicy) {
+	*out = *in
+	out.Timeout = in.Timeout
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraBootstrapPolicy.
+func (in *GaleraBootstrapPolicy) DeepCopy() *GaleraBootstrapPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraBootstrapPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GaleraDefaults) DeepCopyInto(out *GaleraDefaults) {
 	*out = *in
@@ -120,6 +348,22 @@ func (in *GaleraDefaults) DeepCopy() *GaleraDefaults {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraForcedBootstrap) DeepCopyInto(out *GaleraForcedBootstrap) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraForcedBootstrap.
+func (in *GaleraForcedBootstrap) DeepCopy() *GaleraForcedBootstrap {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraForcedBootstrap)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GaleraList) DeepCopyInto(out *GaleraList) {
 	*out = *in
@@ -152,6 +396,250 @@ func (in *GaleraList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraMetrics) DeepCopyInto(out *GaleraMetrics) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraMetrics.
+func (in *GaleraMetrics) DeepCopy() *GaleraMetrics {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraMetrics)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraNodeStatus) DeepCopyInto(out *GaleraNodeStatus) {
+	*out = *in
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraNodeStatus.
+func (in *GaleraNodeStatus) DeepCopy() *GaleraNodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraNodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraOverrideSpec) DeepCopyInto(out *GaleraOverrideSpec) {
+	*out = *in
+	if in.EmbeddedLabelsAnnotations != nil {
+		in, out := &in.EmbeddedLabelsAnnotations, &out.EmbeddedLabelsAnnotations
+		*out = new(service.EmbeddedLabelsAnnotations)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Spec != nil {
+		in, out := &in.Spec, &out.Spec
+		*out = new(GaleraPodSpecOverride)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraOverrideSpec.
+func (in *GaleraOverrideSpec) DeepCopy() *GaleraOverrideSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraOverrideSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraPodSpecOverride) DeepCopyInto(out *GaleraPodSpecOverride) {
+	*out = *in
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]v1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]v1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraPodSpecOverride.
+func (in *GaleraPodSpecOverride) DeepCopy() *GaleraPodSpecOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraPodSpecOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraRestore) DeepCopyInto(out *GaleraRestore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraRestore.
+func (in *GaleraRestore) DeepCopy() *GaleraRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GaleraRestore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraRestoreList) DeepCopyInto(out *GaleraRestoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GaleraRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraRestoreList.
+func (in *GaleraRestoreList) DeepCopy() *GaleraRestoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraRestoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GaleraRestoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraRestorePointInTime) DeepCopyInto(out *GaleraRestorePointInTime) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraRestorePointInTime.
+func (in *GaleraRestorePointInTime) DeepCopy() *GaleraRestorePointInTime {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraRestorePointInTime)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraRestoreSpec) DeepCopyInto(out *GaleraRestoreSpec) {
+	*out = *in
+	if in.PointInTime != nil {
+		in, out := &in.PointInTime, &out.PointInTime
+		*out = new(GaleraRestorePointInTime)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraRestoreSpec.
+func (in *GaleraRestoreSpec) DeepCopy() *GaleraRestoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraRestoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraRestoreStatus) DeepCopyInto(out *GaleraRestoreStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Hash != nil {
+		in, out := &in.Hash, &out.Hash
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(condition.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraRestoreStatus.
+func (in *GaleraRestoreStatus) DeepCopy() *GaleraRestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GaleraSpec) DeepCopyInto(out *GaleraSpec) {
 	*out = *in
@@ -188,6 +676,33 @@ func (in *GaleraSpecCore) DeepCopyInto(out *GaleraSpecCore) {
 		}
 	}
 	in.TLS.DeepCopyInto(&out.TLS)
+	if in.Backup != nil {
+		in, out := &in.Backup, &out.Backup
+		*out = new(GaleraBackupSchedule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BinlogArchive != nil {
+		in, out := &in.BinlogArchive, &out.BinlogArchive
+		*out = new(GaleraBinlogArchive)
+		**out = **in
+	}
+	if in.Arbitrator != nil {
+		in, out := &in.Arbitrator, &out.Arbitrator
+		*out = new(GaleraArbitrator)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BootstrapPolicy != nil {
+		in, out := &in.BootstrapPolicy, &out.BootstrapPolicy
+		*out = new(GaleraBootstrapPolicy)
+		**out = **in
+	}
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = new(GaleraMetrics)
+		**out = **in
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	in.Override.DeepCopyInto(&out.Override)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraSpecCore.
@@ -210,6 +725,18 @@ func (in *GaleraStatus) DeepCopyInto(out *GaleraStatus) {
 			(*out)[key] = val
 		}
 	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make(map[string]GaleraNodeStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Maintenance != nil {
+		in, out := &in.Maintenance, &out.Maintenance
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.ClusterProperties != nil {
 		in, out := &in.ClusterProperties, &out.ClusterProperties
 		*out = make(map[string]string, len(*in))
@@ -224,6 +751,28 @@ func (in *GaleraStatus) DeepCopyInto(out *GaleraStatus) {
 			(*out)[key] = val
 		}
 	}
+	if in.LastBackupTime != nil {
+		in, out := &in.LastBackupTime, &out.LastBackupTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(GaleraUpgradeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.InspectionStartTime != nil {
+		in, out := &in.InspectionStartTime, &out.InspectionStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ForcedBootstrap != nil {
+		in, out := &in.ForcedBootstrap, &out.ForcedBootstrap
+		*out = new(GaleraForcedBootstrap)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make(condition.Conditions, len(*in))
@@ -243,12 +792,51 @@ func (in *GaleraStatus) DeepCopy() *GaleraStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraTuning) DeepCopyInto(out *GaleraTuning) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraTuning.
+func (in *GaleraTuning) DeepCopy() *GaleraTuning {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraTuning)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GaleraUpgradeStatus) DeepCopyInto(out *GaleraUpgradeStatus) {
+	*out = *in
+	if in.NodeStartTime != nil {
+		in, out := &in.NodeStartTime, &out.NodeStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.SystemTablesUpgraded != nil {
+		in, out := &in.SystemTablesUpgraded, &out.SystemTablesUpgraded
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GaleraUpgradeStatus.
+func (in *GaleraUpgradeStatus) DeepCopy() *GaleraUpgradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GaleraUpgradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MariaDBAccount) DeepCopyInto(out *MariaDBAccount) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -305,6 +893,11 @@ func (in *MariaDBAccountList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MariaDBAccountSpec) DeepCopyInto(out *MariaDBAccountSpec) {
 	*out = *in
+	if in.RotationGracePeriod != nil {
+		in, out := &in.RotationGracePeriod, &out.RotationGracePeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MariaDBAccountSpec.
@@ -334,6 +927,10 @@ func (in *MariaDBAccountStatus) DeepCopyInto(out *MariaDBAccountStatus) {
 			(*out)[key] = val
 		}
 	}
+	if in.PreviousPasswordExpiry != nil {
+		in, out := &in.PreviousPasswordExpiry, &out.PreviousPasswordExpiry
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MariaDBAccountStatus.
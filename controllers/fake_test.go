@@ -0,0 +1,251 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// The unit tests of the controllers run the reconcilers against a fake
+// client. Commands run in the galera pods are answered by a fakeExec
+
+const testNamespace = "openstack"
+
+func newTestScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(s)
+	_ = mariadbv1.AddToScheme(s)
+	return s
+}
+
+func newFakeClient(objs ...client.Object) client.Client {
+	return fake.NewClientBuilder().
+		WithScheme(newTestScheme()).
+		WithObjects(objs...).
+		WithStatusSubresource(
+			&mariadbv1.Galera{}, &mariadbv1.GaleraBackup{}, &mariadbv1.GaleraRestore{},
+			&mariadbv1.MariaDBAccount{}, &mariadbv1.MariaDBDatabase{},
+			&appsv1.StatefulSet{}, &appsv1.Deployment{}, &batchv1.Job{}, &corev1.Pod{},
+		).
+		Build()
+}
+
+func newTestHelper(t *testing.T, c client.Client, obj client.Object) *helper.Helper {
+	h, err := helper.NewHelper(obj, c, nil, c.Scheme(), ctrl.Log.WithName("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// fakeExec answers the commands run in the containers of the galera pods.
+// The reply callback receives the pod name and the last argument of the
+// command, i.e. the SQL statement for the commands run with execSQLInPod
+type fakeExec struct {
+	mu       sync.Mutex
+	commands []string
+	reply    func(pod string, cmd string) (string, error)
+}
+
+// stubExec replaces execInPod for the duration of a test
+func stubExec(t *testing.T, reply func(pod string, cmd string) (string, error)) *fakeExec {
+	f := &fakeExec{reply: reply}
+	previous := execInPod
+	execInPod = f.exec
+	t.Cleanup(func() { execInPod = previous })
+	return f
+}
+
+func (f *fakeExec) exec(_ context.Context, _ *helper.Helper, _ *rest.Config, _ string, pod string, _ string, cmd []string, fun func(*bytes.Buffer, *bytes.Buffer) error) error {
+	last := cmd[len(cmd)-1]
+	f.mu.Lock()
+	f.commands = append(f.commands, pod+": "+last)
+	f.mu.Unlock()
+	out := ""
+	if f.reply != nil {
+		var err error
+		if out, err = f.reply(pod, last); err != nil {
+			return err
+		}
+	}
+	return fun(bytes.NewBufferString(out), &bytes.Buffer{})
+}
+
+// ran returns the commands run in a pod that contain a given string
+func (f *fakeExec) ran(pod string, substr string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ret := []string{}
+	for _, c := range f.commands {
+		if strings.HasPrefix(c, pod+": ") && strings.Contains(c, substr) {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// newTestGalera returns a galera CR with the defaults set by the webhook
+func newTestGalera(name string, replicas int32) *mariadbv1.Galera {
+	g := &mariadbv1.Galera{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec: mariadbv1.GaleraSpec{
+			GaleraSpecCore: mariadbv1.GaleraSpecCore{
+				Secret:         "osp-secret",
+				StorageClass:   "local-storage",
+				StorageRequest: "10G",
+				Replicas:       ptr.To(replicas),
+			},
+			ContainerImage: "quay.io/podified-antelope-centos9/openstack-mariadb:current-podified",
+		},
+	}
+	g.Default()
+	return g
+}
+
+// newTestGaleraPod returns a running galera pod, labelled like the
+// pods of the galera statefulset
+func newTestGaleraPod(g *mariadbv1.Galera, index int, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", mariadb.StatefulSetName(g.Name), index),
+			Namespace: g.Namespace,
+			Labels:    mariadb.StatefulSetLabels(g),
+		},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "galera", ContainerID: fmt.Sprintf("cri-o://%d", index), Ready: ready},
+			},
+		},
+	}
+}
+
+func newTestGaleraReconciler(c client.Client) *GaleraReconciler {
+	return &GaleraReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(100),
+	}
+}
+
+// reconcileN runs a reconciler on an object a number of times, the way the
+// controller would after each status update, and stops at the first error
+func reconcileN(t *testing.T, r reconcile.Reconciler, obj client.Object, times int) (ctrl.Result, error) {
+	t.Setenv("OPERATOR_TEMPLATES", "../templates")
+	var result ctrl.Result
+	var err error
+	for i := 0; i < times; i++ {
+		result, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, err
+}
+
+// get refreshes an object from the fake client
+func get[T client.Object](t *testing.T, c client.Client, obj T) T {
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(obj), obj); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+// getJob returns a job created by a reconciler
+func getJob(t *testing.T, c client.Client, name string) *batchv1.Job {
+	return get(t, c, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace}})
+}
+
+// simulateJobSuccess marks a job as succeeded, like the job controller would
+func simulateJobSuccess(t *testing.T, c client.Client, name string) {
+	j := getJob(t, c, name)
+	j.Status.Succeeded = 1
+	if err := c.Status().Update(context.Background(), j); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// simulateStatefulSetAvailable marks all the pods of the galera statefulset
+// as up to date and available, like the statefulset controller would
+func simulateStatefulSetAvailable(t *testing.T, c client.Client, g *mariadbv1.Galera) {
+	sts := get(t, c, &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: mariadb.StatefulSetName(g.Name), Namespace: g.Namespace}})
+	sts.Status.Replicas = *sts.Spec.Replicas
+	sts.Status.ReadyReplicas = *sts.Spec.Replicas
+	sts.Status.AvailableReplicas = *sts.Spec.Replicas
+	sts.Status.UpdatedReplicas = *sts.Spec.Replicas
+	if err := c.Status().Update(context.Background(), sts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "osp-secret", Namespace: testNamespace},
+		Data:       map[string][]byte{"DbRootPassword": []byte("12345678")},
+	}
+}
+
+// galeraStatusReply answers the SHOW GLOBAL STATUS queries of the
+// controllers with a node in a given wsrep state
+func galeraStatusReply(state string) func(string, string) (string, error) {
+	return func(_ string, cmd string) (string, error) {
+		if strings.Contains(cmd, "LIKE 'wsrep_local_state_comment'") {
+			return "wsrep_local_state_comment\t" + state + "\n", nil
+		}
+		return "", nil
+	}
+}
+
+// recordedEvents drains the events emitted so far by the fake recorder of a reconciler
+func recordedEvents(recorder record.EventRecorder) []string {
+	events := []string{}
+	for {
+		select {
+		case e := <-recorder.(*record.FakeRecorder).Events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
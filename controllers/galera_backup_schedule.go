@@ -0,0 +1,154 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	"github.com/robfig/cron/v3"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+)
+
+// reconcileBackupSchedule creates the GaleraBackup CRs of the backup schedule as they
+// become due, reports the outcome of the last backup, and prunes the backups that
+// are no longer covered by the retention policy
+func (r *GaleraReconciler) reconcileBackupSchedule(ctx context.Context, h *helper.Helper, instance *mariadbv1.Galera) (ctrl.Result, error) {
+	log := h.GetLogger()
+	spec := instance.Spec.Backup
+
+	schedule, err := cron.ParseStandard(spec.Schedule)
+	if err != nil {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.GaleraBackupScheduleReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityWarning,
+			mariadbv1.GaleraBackupScheduleErrorMessage,
+			err.Error()))
+		return ctrl.Result{}, nil
+	}
+
+	backupList := &mariadbv1.GaleraBackupList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(instance.Namespace),
+		client.MatchingLabels(mariadb.ScheduledBackupLabels(instance)),
+	}
+	if err = r.List(ctx, backupList, listOpts...); err != nil {
+		return ctrl.Result{}, err
+	}
+	// newest backups first
+	backups := backupList.Items
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[j].CreationTimestamp.Before(&backups[i].CreationTimestamp)
+	})
+
+	// Report the outcome of the most recent backup that finished
+	running := false
+	instance.Status.Conditions.MarkTrue(mariadbv1.GaleraBackupScheduleReadyCondition, mariadbv1.GaleraBackupScheduleNoBackupMessage)
+	for _, backup := range backups {
+		if backup.Status.Completed {
+			instance.Status.Conditions.MarkTrue(mariadbv1.GaleraBackupScheduleReadyCondition, mariadbv1.GaleraBackupScheduleReadyMessage, backup.Name)
+			break
+		}
+		backupCondition := backup.Status.Conditions.Get(mariadbv1.GaleraBackupReadyCondition)
+		if condition.IsError(backupCondition) {
+			instance.Status.Conditions.Set(condition.FalseCondition(
+				mariadbv1.GaleraBackupScheduleReadyCondition,
+				condition.ErrorReason,
+				condition.SeverityWarning,
+				mariadbv1.GaleraBackupScheduleFailedMessage,
+				backup.Name, backupCondition.Message))
+			break
+		}
+		running = running || backup.DeletionTimestamp.IsZero()
+	}
+	for _, backup := range backups {
+		if backup.Status.Completed {
+			instance.Status.LastBackup = backup.Name
+			instance.Status.LastBackupTime = backup.Status.CompletionTime
+			break
+		}
+	}
+
+	// Prune the backups that are no longer retained. The most recent successful
+	// backup is always kept, whatever its age. Backups that failed are pruned
+	// as soon as a more recent backup completed
+	now := time.Now()
+	kept := int32(0)
+	for i := range backups {
+		backup := &backups[i]
+		if !backup.DeletionTimestamp.IsZero() {
+			continue
+		}
+		prune := false
+		if backup.Status.Completed {
+			kept++
+			tooMany := spec.Retention.Count > 0 && kept > spec.Retention.Count
+			tooOld := spec.Retention.MaxAge != nil && now.Sub(backup.CreationTimestamp.Time) > spec.Retention.MaxAge.Duration
+			prune = kept > 1 && (tooMany || tooOld)
+		} else {
+			prune = condition.IsError(backup.Status.Conditions.Get(mariadbv1.GaleraBackupReadyCondition)) && kept > 0
+		}
+		if prune {
+			log.Info("Pruning scheduled backup", "backup", backup.Name)
+			if err = r.Delete(ctx, backup); err != nil && !k8s_errors.IsNotFound(err) {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	// Start a new backup when the next run is due. Runs missed
+	// while the operator was not running are merged into one.
+	// The last run is recorded in the status, so that pruning
+	// the backups does not make the schedule start over
+	last := instance.CreationTimestamp.Time
+	if instance.Status.LastScheduleTime != nil {
+		last = instance.Status.LastScheduleTime.Time
+	} else if len(backups) > 0 {
+		last = backups[0].CreationTimestamp.Time
+	}
+	next := schedule.Next(last)
+	if !next.After(now) {
+		if running {
+			log.Info("Previous scheduled backup still running, postponing the next one")
+			return ctrl.Result{RequeueAfter: time.Duration(30) * time.Second}, nil
+		}
+		backup := mariadb.ScheduledBackup(instance, next)
+		err = controllerutil.SetControllerReference(instance, backup, r.Scheme)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		log.Info("Starting scheduled backup", "backup", backup.Name)
+		if err = r.Create(ctx, backup); err != nil && !k8s_errors.IsAlreadyExists(err) {
+			return ctrl.Result{}, err
+		}
+		instance.Status.LastScheduleTime = &metav1.Time{Time: now}
+		next = schedule.Next(now)
+	}
+
+	return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+}
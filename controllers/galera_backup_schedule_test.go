@@ -0,0 +1,319 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type scheduledBackupState int
+
+const (
+	backupRunning scheduledBackupState = iota
+	backupCompleted
+	backupFailed
+)
+
+// newTestGaleraWithSchedule returns a galera CR created two days ago, which
+// takes a backup every hour
+func newTestGaleraWithSchedule(retention mariadbv1.GaleraBackupRetention) *mariadbv1.Galera {
+	g := newTestGalera("openstack", 3)
+	g.CreationTimestamp = metav1.NewTime(time.Now().Add(-48 * time.Hour))
+	g.Spec.Backup = &mariadbv1.GaleraBackupSchedule{
+		Schedule:  "@every 1h",
+		Retention: retention,
+		Storage:   mariadbv1.GaleraBackupStorage{ClaimName: "backup-storage"},
+	}
+	g.Status.Conditions = condition.Conditions{}
+	return g
+}
+
+// newTestScheduledBackup returns a GaleraBackup CR of the backup schedule,
+// started some time ago
+func newTestScheduledBackup(g *mariadbv1.Galera, age time.Duration, state scheduledBackupState) *mariadbv1.GaleraBackup {
+	created := time.Now().Add(-age)
+	b := mariadb.ScheduledBackup(g, created)
+	b.CreationTimestamp = metav1.NewTime(created)
+	switch state {
+	case backupCompleted:
+		b.Status.Completed = true
+		b.Status.CompletionTime = &metav1.Time{Time: created.Add(time.Minute)}
+		b.Status.Conditions.MarkTrue(mariadbv1.GaleraBackupReadyCondition, mariadbv1.GaleraBackupReadyMessage)
+	case backupFailed:
+		b.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.GaleraBackupReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityWarning,
+			"job failed"))
+	}
+	return b
+}
+
+// listScheduledBackups returns the names of the GaleraBackup CRs of the backup schedule
+func listScheduledBackups(t *testing.T, c client.Client, g *mariadbv1.Galera) []string {
+	backups := &mariadbv1.GaleraBackupList{}
+	err := c.List(context.Background(), backups,
+		client.InNamespace(g.Namespace), client.MatchingLabels(mariadb.ScheduledBackupLabels(g)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, b := range backups.Items {
+		names = append(names, b.Name)
+	}
+	return names
+}
+
+func TestBackupScheduleNextRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		lastSchedule *time.Duration
+		backups      []scheduledBackupState
+		started      bool
+		requeueAfter time.Duration
+	}{
+		{
+			name:         "First run is due",
+			started:      true,
+			requeueAfter: time.Hour,
+		},
+		{
+			name:         "Next run is not due",
+			lastSchedule: ptr.To(10 * time.Minute),
+			backups:      []scheduledBackupState{backupCompleted},
+			requeueAfter: 50 * time.Minute,
+		},
+		{
+			name:         "Next run is due",
+			lastSchedule: ptr.To(70 * time.Minute),
+			backups:      []scheduledBackupState{backupCompleted},
+			started:      true,
+			requeueAfter: time.Hour,
+		},
+		{
+			name:         "All backups pruned",
+			lastSchedule: ptr.To(10 * time.Minute),
+			requeueAfter: 50 * time.Minute,
+		},
+		{
+			name:         "No last run recorded",
+			backups:      []scheduledBackupState{backupCompleted},
+			requeueAfter: 50 * time.Minute,
+		},
+		{
+			name:         "Previous run still running",
+			lastSchedule: ptr.To(70 * time.Minute),
+			backups:      []scheduledBackupState{backupRunning},
+			requeueAfter: 30 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			galera := newTestGaleraWithSchedule(mariadbv1.GaleraBackupRetention{})
+			if tt.lastSchedule != nil {
+				galera.Status.LastScheduleTime = &metav1.Time{Time: time.Now().Add(-*tt.lastSchedule)}
+			}
+			// the backups were started by the last run
+			age := 10 * time.Minute
+			if tt.lastSchedule != nil {
+				age = *tt.lastSchedule
+			}
+			objs := []client.Object{galera}
+			for _, state := range tt.backups {
+				objs = append(objs, newTestScheduledBackup(galera, age, state))
+			}
+			c := newFakeClient(objs...)
+			r := newTestGaleraReconciler(c)
+			previous := galera.Status.LastScheduleTime
+
+			result, err := r.reconcileBackupSchedule(context.Background(), newTestHelper(t, c, galera), galera)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.RequeueAfter).To(BeNumerically("~", tt.requeueAfter, 5*time.Second))
+			if tt.started {
+				g.Expect(listScheduledBackups(t, c, galera)).To(HaveLen(len(tt.backups) + 1))
+				g.Expect(galera.Status.LastScheduleTime.Time).To(BeTemporally("~", time.Now(), 5*time.Second))
+			} else {
+				g.Expect(listScheduledBackups(t, c, galera)).To(HaveLen(len(tt.backups)))
+				g.Expect(galera.Status.LastScheduleTime).To(Equal(previous))
+			}
+		})
+	}
+}
+
+func TestBackupScheduleRetention(t *testing.T) {
+	hours := func(h int) time.Duration { return time.Duration(h)*time.Hour + 10*time.Minute }
+	tests := []struct {
+		name      string
+		retention mariadbv1.GaleraBackupRetention
+		backups   []scheduledBackupState
+		kept      []int
+	}{
+		{
+			name:    "No retention",
+			backups: []scheduledBackupState{backupCompleted, backupCompleted, backupCompleted},
+			kept:    []int{0, 1, 2},
+		},
+		{
+			name:      "Count",
+			retention: mariadbv1.GaleraBackupRetention{Count: 2},
+			backups:   []scheduledBackupState{backupCompleted, backupCompleted, backupCompleted},
+			kept:      []int{0, 1},
+		},
+		{
+			name:      "MaxAge",
+			retention: mariadbv1.GaleraBackupRetention{MaxAge: &metav1.Duration{Duration: 90 * time.Minute}},
+			backups:   []scheduledBackupState{backupCompleted, backupCompleted, backupCompleted},
+			kept:      []int{0, 1},
+		},
+		{
+			name:      "MaxAge keeps the most recent successful backup",
+			retention: mariadbv1.GaleraBackupRetention{MaxAge: &metav1.Duration{Duration: 10 * time.Minute}},
+			backups:   []scheduledBackupState{backupCompleted, backupCompleted, backupCompleted},
+			kept:      []int{0},
+		},
+		{
+			name:      "Failed and running backups are not counted",
+			retention: mariadbv1.GaleraBackupRetention{Count: 1},
+			backups:   []scheduledBackupState{backupRunning, backupFailed, backupCompleted, backupCompleted},
+			kept:      []int{0, 1, 2},
+		},
+		{
+			name:      "Failed backups are pruned once a more recent one completed",
+			retention: mariadbv1.GaleraBackupRetention{Count: 2},
+			backups:   []scheduledBackupState{backupCompleted, backupFailed, backupRunning, backupCompleted},
+			kept:      []int{0, 2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			galera := newTestGaleraWithSchedule(tt.retention)
+			galera.Status.LastScheduleTime = &metav1.Time{Time: time.Now().Add(-10 * time.Minute)}
+			objs := []client.Object{galera}
+			names := []string{}
+			for i, state := range tt.backups {
+				b := newTestScheduledBackup(galera, hours(i), state)
+				objs = append(objs, b)
+				names = append(names, b.Name)
+			}
+			c := newFakeClient(objs...)
+			r := newTestGaleraReconciler(c)
+
+			_, err := r.reconcileBackupSchedule(context.Background(), newTestHelper(t, c, galera), galera)
+			g.Expect(err).ToNot(HaveOccurred())
+			kept := []string{}
+			for _, i := range tt.kept {
+				kept = append(kept, names[i])
+			}
+			g.Expect(listScheduledBackups(t, c, galera)).To(ConsistOf(kept))
+		})
+	}
+}
+
+func TestBackupScheduleLastBackup(t *testing.T) {
+	tests := []struct {
+		name       string
+		backups    []scheduledBackupState
+		status     corev1.ConditionStatus
+		message    string
+		lastBackup int
+	}{
+		{
+			name:       "No backup",
+			status:     corev1.ConditionTrue,
+			message:    mariadbv1.GaleraBackupScheduleNoBackupMessage,
+			lastBackup: -1,
+		},
+		{
+			name:       "Last backup completed",
+			backups:    []scheduledBackupState{backupCompleted, backupCompleted},
+			status:     corev1.ConditionTrue,
+			message:    "Last scheduled backup %s completed",
+			lastBackup: 0,
+		},
+		{
+			name:       "Running backups are ignored",
+			backups:    []scheduledBackupState{backupRunning, backupCompleted},
+			status:     corev1.ConditionTrue,
+			message:    "Last scheduled backup %s completed",
+			lastBackup: 1,
+		},
+		{
+			name:       "Last backup failed",
+			backups:    []scheduledBackupState{backupRunning, backupFailed, backupCompleted},
+			status:     corev1.ConditionFalse,
+			message:    "Scheduled backup %s failed: job failed",
+			lastBackup: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			galera := newTestGaleraWithSchedule(mariadbv1.GaleraBackupRetention{})
+			galera.Status.LastScheduleTime = &metav1.Time{Time: time.Now().Add(-10 * time.Minute)}
+			objs := []client.Object{galera}
+			backups := []*mariadbv1.GaleraBackup{}
+			for i, state := range tt.backups {
+				b := newTestScheduledBackup(galera, time.Duration(i+1)*time.Hour, state)
+				objs = append(objs, b)
+				backups = append(backups, b)
+			}
+			c := newFakeClient(objs...)
+			r := newTestGaleraReconciler(c)
+
+			_, err := r.reconcileBackupSchedule(context.Background(), newTestHelper(t, c, galera), galera)
+			g.Expect(err).ToNot(HaveOccurred())
+			cond := galera.Status.Conditions.Get(mariadbv1.GaleraBackupScheduleReadyCondition)
+			g.Expect(cond.Status).To(Equal(tt.status))
+			if tt.lastBackup < 0 {
+				g.Expect(cond.Message).To(Equal(tt.message))
+				g.Expect(galera.Status.LastBackup).To(BeEmpty())
+				g.Expect(galera.Status.LastBackupTime).To(BeNil())
+				return
+			}
+			// the condition reports the most recent backup that finished, while
+			// the status only records the most recent successful one
+			finished := 0
+			for finished < len(tt.backups) && tt.backups[finished] == backupRunning {
+				finished++
+			}
+			g.Expect(cond.Message).To(Equal(fmt.Sprintf(tt.message, backups[finished].Name)))
+			g.Expect(galera.Status.LastBackup).To(Equal(backups[tt.lastBackup].Name))
+			g.Expect(galera.Status.LastBackupTime.Time).To(BeTemporally("~", backups[tt.lastBackup].Status.CompletionTime.Time, time.Second))
+		})
+	}
+}
@@ -19,8 +19,11 @@ package controllers
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -31,6 +34,7 @@ import (
 	configmap "github.com/openstack-k8s-operators/lib-common/modules/common/configmap"
 	env "github.com/openstack-k8s-operators/lib-common/modules/common/env"
 	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	"github.com/openstack-k8s-operators/lib-common/modules/common/labels"
 	common_rbac "github.com/openstack-k8s-operators/lib-common/modules/common/rbac"
 	secret "github.com/openstack-k8s-operators/lib-common/modules/common/secret"
 	"github.com/openstack-k8s-operators/lib-common/modules/common/service"
@@ -39,6 +43,7 @@ import (
 	util "github.com/openstack-k8s-operators/lib-common/modules/common/util"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -46,6 +51,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/kubectl/pkg/util/podutils"
 
 	"golang.org/x/exp/maps"
@@ -56,6 +62,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
@@ -70,19 +77,28 @@ import (
 const (
 	serviceSecretNameField = ".spec.tls.genericService.SecretName"
 	caSecretNameField      = ".spec.tls.ca.caBundleSecretName"
+	rootSecretNameField    = ".spec.secret"
+	sstSecretNameField     = ".spec.sstMethod"
 )
 
+// galeraNodeStatusInterval is how often the wsrep state of the running
+// galera nodes is collected into the status of the galera CR
+const galeraNodeStatusInterval = time.Duration(60) * time.Second
+
 var allWatchFields = []string{
 	serviceSecretNameField,
 	caSecretNameField,
+	rootSecretNameField,
+	sstSecretNameField,
 }
 
 // GaleraReconciler reconciles a Galera object
 type GaleraReconciler struct {
 	client.Client
-	Kclient kubernetes.Interface
-	config  *rest.Config
-	Scheme  *runtime.Scheme
+	Kclient  kubernetes.Interface
+	config   *rest.Config
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
 // GetLog returns a logger object with a prefix of "controller.name" and additional controller context fields
@@ -94,8 +110,9 @@ func GetLog(ctx context.Context, controller string) logr.Logger {
 // General Galera helper functions
 //
 
-// findBestCandidate returns the node with the lowest seqno
-func findBestCandidate(g *mariadbv1.Galera) (node string, found bool) {
+// findBestCandidate returns the node with the lowest seqno, provided
+// that at least quorum nodes have been inspected
+func findBestCandidate(g *mariadbv1.Galera, quorum int) (node string, found bool) {
 	sortednodes := maps.Keys(g.Status.Attributes)
 	sort.Strings(sortednodes)
 	bestnode := ""
@@ -114,9 +131,10 @@ func findBestCandidate(g *mariadbv1.Galera) (node string, found bool) {
 			bestseqno = intseqno
 		}
 	}
-	// if we pass here, a candidate is only valid if we
-	// inspected all the expected replicas (e.g. typically 3)
-	if len(g.Status.Attributes) != int(*g.Spec.Replicas) {
+	// if we pass here, a candidate is only valid if we inspected all
+	// the expected replicas (e.g. typically 3), or the quorum allowed
+	// by the bootstrap policy
+	if len(g.Status.Attributes) < quorum {
 		return "", false
 	}
 	return bestnode, true //"galera-0"
@@ -124,7 +142,18 @@ func findBestCandidate(g *mariadbv1.Galera) (node string, found bool) {
 
 // buildGcommURI builds a gcomm URI for a galera instance
 // e.g. "gcomm://galera-0.galera,galera-1.galera,galera-2.galera"
+// The arbitrator, if any, is a member of the cluster as well
 func buildGcommURI(instance *mariadbv1.Galera) string {
+	res := galeraNodeHostnames(instance)
+	if instance.Spec.Arbitrator != nil {
+		res = append(res, mariadb.ArbitratorHostname(instance))
+	}
+	uri := "gcomm://" + strings.Join(res, ",")
+	return uri
+}
+
+// galeraNodeHostnames returns the DNS names of the galera pods of an instance
+func galeraNodeHostnames(instance *mariadbv1.Galera) []string {
 	replicas := int(*instance.Spec.Replicas)
 	basename := instance.Name + "-galera"
 	res := []string{}
@@ -133,8 +162,7 @@ func buildGcommURI(instance *mariadbv1.Galera) string {
 		// Generate Gcomm with subdomains for TLS validation
 		res = append(res, basename+"-"+strconv.Itoa(i)+"."+basename+"."+instance.Namespace+".svc")
 	}
-	uri := "gcomm://" + strings.Join(res, ",")
-	return uri
+	return res
 }
 
 // isBootstrapInProgress checks whether a node is currently starting a galera cluster
@@ -151,6 +179,10 @@ func isBootstrapInProgress(instance *mariadbv1.Galera) bool {
 // Reconcile logics helper functions
 //
 
+// execInPod runs a command in a container of a pod. Unit tests replace
+// it to simulate the processes running in the galera containers
+var execInPod = mariadb.ExecInPod
+
 // getPodFromName returns the pod object from a pod name
 func getPodFromName(pods []corev1.Pod, name string) *corev1.Pod {
 	for _, pod := range pods {
@@ -222,7 +254,7 @@ func getGaleraContainerID(pod *corev1.Pod) (found bool, CID string) {
 // isGaleraContainerStartedAndWaiting checks whether the galera container is waiting for a gcomm_uri file
 func isGaleraContainerStartedAndWaiting(ctx context.Context, pod *corev1.Pod, instance *mariadbv1.Galera, h *helper.Helper, config *rest.Config) bool {
 	waiting := false
-	err := mariadb.ExecInPod(ctx, h, config, instance.Namespace, pod.Name, "galera",
+	err := execInPod(ctx, h, config, instance.Namespace, pod.Name, "galera",
 		[]string{"/bin/bash", "-c", "test ! -f /var/lib/mysql/gcomm_uri && pgrep -aP1 | grep -o detect_gcomm_and_start.sh"},
 		func(stdout *bytes.Buffer, _ *bytes.Buffer) error {
 			predicate := strings.TrimSuffix(stdout.String(), "\n")
@@ -232,6 +264,100 @@ func isGaleraContainerStartedAndWaiting(ctx context.Context, pod *corev1.Pod, in
 	return err == nil && waiting
 }
 
+// execSQLInPod runs a SQL statement as root in the galera container of a pod
+// and passes the query output to a callback function
+func execSQLInPod(ctx context.Context, h *helper.Helper, config *rest.Config, instance *mariadbv1.Galera, podName string, sql string, fun func(*bytes.Buffer) error) error {
+	// the root password is read from the mounted secret, which is always up to date
+	return execInPod(ctx, h, config, instance.Namespace, podName, "galera",
+		[]string{"/bin/bash", "-c", "read -s -u 3 3< /var/lib/secrets/dbpassword MYSQL_PWD; export MYSQL_PWD; mysql -uroot -sN -e \"$0\"", sql},
+		func(stdout *bytes.Buffer, _ *bytes.Buffer) error {
+			return fun(stdout)
+		})
+}
+
+// sqlQuote returns a value as a SQL string literal, with the characters that
+// would end the string escaped
+func sqlQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// getGaleraStatusVariable retrieves the value of a status variable from a running galera node
+func getGaleraStatusVariable(ctx context.Context, h *helper.Helper, config *rest.Config, instance *mariadbv1.Galera, podName string, name string) (value string, err error) {
+	err = execSQLInPod(ctx, h, config, instance, podName, "SHOW GLOBAL STATUS LIKE '"+name+"';",
+		func(stdout *bytes.Buffer) error {
+			fields := strings.SplitN(strings.TrimSuffix(stdout.String(), "\n"), "\t", 2)
+			if len(fields) != 2 {
+				return fmt.Errorf("unexpected output for status variable %s: %q", name, stdout.String())
+			}
+			value = fields[1]
+			return nil
+		})
+	return
+}
+
+// getGaleraNodeStatus retrieves the wsrep state of a running galera node
+func getGaleraNodeStatus(ctx context.Context, h *helper.Helper, config *rest.Config, instance *mariadbv1.Galera, podName string) (node mariadbv1.GaleraNodeStatus, err error) {
+	sql := "SHOW GLOBAL STATUS WHERE Variable_name IN ('wsrep_cluster_size', 'wsrep_cluster_state_uuid', " +
+		"'wsrep_local_state_comment', 'wsrep_flow_control_paused', 'wsrep_last_committed');"
+	err = execSQLInPod(ctx, h, config, instance, podName, sql,
+		func(stdout *bytes.Buffer) error {
+			for _, line := range strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n") {
+				fields := strings.SplitN(line, "\t", 2)
+				if len(fields) != 2 {
+					return fmt.Errorf("unexpected output for wsrep status: %q", stdout.String())
+				}
+				switch strings.ToLower(fields[0]) {
+				case "wsrep_cluster_size":
+					size, err := strconv.ParseInt(fields[1], 10, 32)
+					if err != nil {
+						return err
+					}
+					node.ClusterSize = int32(size)
+				case "wsrep_cluster_state_uuid":
+					node.StateUUID = fields[1]
+				case "wsrep_local_state_comment":
+					node.LocalState = fields[1]
+				case "wsrep_flow_control_paused":
+					node.FlowControlPaused = fields[1]
+				case "wsrep_last_committed":
+					node.LastCommitted = fields[1]
+				}
+			}
+			node.LastUpdateTime = metav1.Now()
+			return nil
+		})
+	return
+}
+
+// getServerVersion retrieves the version of the MariaDB server running on a galera node
+func getServerVersion(ctx context.Context, h *helper.Helper, config *rest.Config, instance *mariadbv1.Galera, podName string) (version string, err error) {
+	err = execSQLInPod(ctx, h, config, instance, podName, "SELECT VERSION();",
+		func(stdout *bytes.Buffer) error {
+			version = strings.TrimSpace(stdout.String())
+			if version == "" {
+				return fmt.Errorf("no server version returned by pod %s", podName)
+			}
+			return nil
+		})
+	return
+}
+
+// setGaleraNodeDesync enables or disables wsrep_desync on a running galera node.
+// A desynced node does not participate in flow control, so long running
+// operations on that node do not slow down the rest of the cluster
+func setGaleraNodeDesync(ctx context.Context, h *helper.Helper, config *rest.Config, instance *mariadbv1.Galera, podName string, desync bool) error {
+	value := "OFF"
+	if desync {
+		value = "ON"
+	}
+	return execSQLInPod(ctx, h, config, instance, podName, "SET GLOBAL wsrep_desync="+value+";",
+		func(_ *bytes.Buffer) error {
+			return nil
+		})
+}
+
 ///
 // Status management helper functions
 // These functions have side effect and modify the galera CR's status
@@ -239,22 +365,29 @@ func isGaleraContainerStartedAndWaiting(ctx context.Context, pod *corev1.Pod, in
 
 // injectGcommURI configures a pod to start galera with a given URI
 func injectGcommURI(ctx context.Context, h *helper.Helper, config *rest.Config, instance *mariadbv1.Galera, pod *corev1.Pod, uri string) error {
-	err := mariadb.ExecInPod(ctx, h, config, instance.Namespace, pod.Name, "galera",
+	err := execInPod(ctx, h, config, instance.Namespace, pod.Name, "galera",
 		[]string{"/bin/bash", "-c", "echo '" + uri + "' > /var/lib/mysql/gcomm_uri"},
 		func(_ *bytes.Buffer, _ *bytes.Buffer) error {
 			attr := instance.Status.Attributes[pod.Name]
 			attr.Gcomm = uri
-			attr.ContainerID = pod.Status.ContainerStatuses[0].ContainerID
+			// container statuses are sorted by name, sidecars
+			// may come before the galera container
+			_, attr.ContainerID = getGaleraContainerID(pod)
 			instance.Status.Attributes[pod.Name] = attr
 			return nil
 		})
+	if err != nil {
+		galeraGcommInjectionFailures.WithLabelValues(instance.Namespace, instance.Name).Inc()
+	} else if uri == "gcomm://" {
+		galeraBootstrapAttempts.WithLabelValues(instance.Namespace, instance.Name).Inc()
+	}
 	return err
 }
 
 // retrieveSequenceNumber probes a pod's galera instance for sequence number
 func retrieveSequenceNumber(ctx context.Context, helper *helper.Helper, config *rest.Config, instance *mariadbv1.Galera, pod *corev1.Pod) (errStr []string, err error) {
 	errStr = nil
-	err = mariadb.ExecInPod(ctx, helper, config, instance.Namespace, pod.Name, "galera",
+	err = execInPod(ctx, helper, config, instance.Namespace, pod.Name, "galera",
 		[]string{"/bin/bash", "/var/lib/operator-scripts/detect_last_commit.sh"},
 		func(stdout *bytes.Buffer, stderr *bytes.Buffer) error {
 			var attr mariadbv1.GaleraAttributes
@@ -267,6 +400,9 @@ func retrieveSequenceNumber(ctx context.Context, helper *helper.Helper, config *
 			instance.Status.Attributes[pod.Name] = attr
 			return nil
 		})
+	if err != nil {
+		galeraSeqnoErrors.WithLabelValues(instance.Namespace, instance.Name).Inc()
+	}
 	return
 }
 
@@ -299,7 +435,7 @@ func clearOldPodsAttributesOnScaleDown(ctx context.Context, instance *mariadbv1.
 // assertPodsAttributesValidity compares the current state of the pods that are starting galera
 // against their known state in the CR's attributes. If a pod's attributes don't match its actual
 // state (i.e. it failed to start galera), the attributes are cleared from the CR's status
-func assertPodsAttributesValidity(helper *helper.Helper, instance *mariadbv1.Galera, pods []corev1.Pod) {
+func assertPodsAttributesValidity(helper *helper.Helper, recorder record.EventRecorder, instance *mariadbv1.Galera, pods []corev1.Pod) {
 	for _, pod := range pods {
 		_, found := instance.Status.Attributes[pod.Name]
 		if !found {
@@ -316,6 +452,8 @@ func assertPodsAttributesValidity(helper *helper.Helper, instance *mariadbv1.Gal
 			// reprobe the pod's state in the next reconcile loop
 			clearPodAttributes(instance, pod.Name)
 			util.LogForObject(helper, "Pod restarted while galera was starting", instance, "pod", pod.Name, "recorded ID", attrCID)
+			recorder.Eventf(instance, corev1.EventTypeWarning, "PodRestarted",
+				"Pod %s restarted while galera was starting, its state will be probed again", pod.Name)
 		}
 	}
 }
@@ -324,11 +462,21 @@ func assertPodsAttributesValidity(helper *helper.Helper, instance *mariadbv1.Gal
 // +kubebuilder:rbac:groups=mariadb.openstack.org,resources=galeras,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=mariadb.openstack.org,resources=galeras/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=mariadb.openstack.org,resources=galeras/finalizers,verbs=update;patch
+// +kubebuilder:rbac:groups=mariadb.openstack.org,resources=galerabackups,verbs=get;list;watch;create;delete
 
 // RBAC for statefulsets
 // +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps,resources=statefulsets/status,verbs=get;list;watch
 
+// RBAC for the pod disruption budget
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+
+// RBAC for the arbitrator deployment
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+
+// RBAC for the metrics service monitor
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
+
 // RBAC for pods
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;update;patch;delete;
 // +kubebuilder:rbac:groups=core,resources=pods/exec,verbs=create
@@ -353,6 +501,8 @@ func assertPodsAttributesValidity(helper *helper.Helper, instance *mariadbv1.Gal
 // +kubebuilder:rbac:groups="",resources=pods,verbs=create;delete;get;list;patch;update;watch
 
 // +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;update;delete;patch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
 
 // Reconcile - Galera
 func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, _err error) {
@@ -429,11 +579,23 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 		condition.UnknownCondition(condition.ServiceConfigReadyCondition, condition.InitReason, condition.ServiceConfigReadyInitMessage),
 		// cluster bootstrap
 		condition.UnknownCondition(condition.DeploymentReadyCondition, condition.InitReason, condition.DeploymentReadyInitMessage),
+		// root password rotation
+		condition.UnknownCondition(mariadbv1.GaleraRootPasswordReadyCondition, condition.InitReason, mariadbv1.GaleraRootPasswordReadyInitMessage),
+		// container image upgrade
+		condition.UnknownCondition(mariadbv1.GaleraUpgradeReadyCondition, condition.InitReason, mariadbv1.GaleraUpgradeReadyInitMessage),
+		// StorageReady
+		condition.UnknownCondition(mariadbv1.GaleraStorageReadyCondition, condition.InitReason, mariadbv1.GaleraStorageReadyInitMessage),
 		// service account, role, rolebinding
 		condition.UnknownCondition(condition.ServiceAccountReadyCondition, condition.InitReason, condition.ServiceAccountReadyInitMessage),
 		condition.UnknownCondition(condition.RoleReadyCondition, condition.InitReason, condition.RoleReadyInitMessage),
 		condition.UnknownCondition(condition.RoleBindingReadyCondition, condition.InitReason, condition.RoleBindingReadyInitMessage),
 	)
+	// scheduled backups
+	if instance.Spec.Backup != nil {
+		cl.Set(condition.UnknownCondition(mariadbv1.GaleraBackupScheduleReadyCondition, condition.InitReason, mariadbv1.GaleraBackupScheduleReadyInitMessage))
+	} else {
+		instance.Status.Conditions.Remove(mariadbv1.GaleraBackupScheduleReadyCondition)
+	}
 
 	instance.Status.Conditions.Init(&cl)
 	instance.Status.ObservedGeneration = instance.Generation
@@ -507,14 +669,15 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 		// service get stuck.
 		controllerutil.AddFinalizer(service, helper.GetFinalizer())
 
-		// NOTE(dciabrin) We deploy Galera as an A/P service (i.e. no multi-master writes)
+		// NOTE(dciabrin) By default we deploy Galera as an A/P service (i.e. no multi-master writes)
 		// by setting labels in the service's label selectors.
 		// This label is dynamically set based on the status of the Galera cluster,
 		// so in this CreateOrPatch block we must reuse whatever is present in
 		// the existing service CR in case we're patching it.
+		// In A/A mode, the active pod label is dropped from the selector.
 		activePod, present := service.Spec.Selector[mariadb.ActivePodSelectorKey]
 		service.Spec = pkgsvc.Spec
-		if present {
+		if present && !instance.IsActiveActive() {
 			service.Spec.Selector[mariadb.ActivePodSelectorKey] = activePod
 		}
 		err := controllerutil.SetControllerReference(instance, service, r.Client.Scheme())
@@ -530,6 +693,32 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 		log.Info("", "Kind", instance.Kind, "Name", instance.Name, "database service", service.Name, "operation", string(op))
 	}
 
+	// the read service exposes the passive nodes, its endpoints
+	// are updated once the state of the pods is known
+	pkgreadsvc := mariadb.ReadService(instance)
+	readService := &corev1.Service{ObjectMeta: pkgreadsvc.ObjectMeta}
+	op, err = controllerutil.CreateOrPatch(ctx, r.Client, readService, func() error {
+		readService.Labels = pkgreadsvc.Labels
+		readService.Spec.Ports = pkgreadsvc.Spec.Ports
+		err := controllerutil.SetControllerReference(instance, readService, r.Client.Scheme())
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if op != controllerutil.OperationResultNone {
+		log.Info("", "Kind", instance.Kind, "Name", instance.Name, "database read service", readService.Name, "operation", string(op))
+	}
+
+	// the metrics service exposes the mysqld exporter of the galera pods
+	err = r.reconcileMetrics(ctx, helper, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	instance.Status.Conditions.MarkTrue(condition.CreateServiceReadyCondition, condition.CreateServiceReadyMessage)
 
 	// Map of all resources that may cause a rolling service restart
@@ -539,9 +728,9 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 	clusterPropertiesEnv := make(map[string]env.Setter)
 
 	// Check and hash inputs
-	// NOTE do not hash the db root password, as its change requires
-	// more orchestration than a simple rolling restart
-	_, res, err := secret.VerifySecret(
+	// NOTE the db root password is not hashed with the other inputs, as
+	// its change requires more orchestration than a simple rolling restart
+	rootPasswordHash, res, err := secret.VerifySecret(
 		ctx,
 		types.NamespacedName{Namespace: instance.Namespace, Name: instance.Spec.Secret},
 		[]string{
@@ -565,9 +754,40 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 			condition.InputReadyErrorMessage,
 			err.Error()))
 		return ctrl.Result{}, err
+	} else if (res != ctrl.Result{}) {
+		// the secret was not found, wait for it to be created
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			condition.InputReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			condition.InputReadyWaitingMessage))
+		return res, nil
 	}
 	instance.Status.Conditions.MarkTrue(condition.InputReadyCondition, condition.InputReadyMessage)
 
+	// The pods are only restarted with the new root password once it has
+	// been rotated in the database, so they are tied to the password in use.
+	// It is only tracked after a first rotation, to keep the hash of existing
+	// clusters unchanged
+	if _, found := instance.Status.Hash[mariadbv1.DbRootPasswordHash]; !found {
+		instance.Status.Hash, _ = util.SetHash(instance.Status.Hash, mariadbv1.DbRootPasswordHash, rootPasswordHash)
+	}
+	if _, found := instance.Status.Hash[mariadbv1.DbRootPasswordInitialHash]; !found {
+		instance.Status.Hash[mariadbv1.DbRootPasswordInitialHash] = instance.Status.Hash[mariadbv1.DbRootPasswordHash]
+	}
+	if instance.Status.Hash[mariadbv1.DbRootPasswordHash] != instance.Status.Hash[mariadbv1.DbRootPasswordInitialHash] {
+		inputHashEnv[mariadbv1.DbRootPasswordHash] = env.SetValue(instance.Status.Hash[mariadbv1.DbRootPasswordHash])
+	}
+	if instance.Status.Hash[mariadbv1.DbRootPasswordHash] == rootPasswordHash {
+		instance.Status.Conditions.MarkTrue(mariadbv1.GaleraRootPasswordReadyCondition, mariadbv1.GaleraRootPasswordReadyMessage)
+	} else {
+		instance.Status.Conditions.MarkFalse(
+			mariadbv1.GaleraRootPasswordReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			mariadbv1.GaleraRootPasswordRotationPendingMessage)
+	}
+
 	//
 	// TLS input validation
 	//
@@ -629,6 +849,16 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 	// all cert input checks out so report InputReady
 	instance.Status.Conditions.MarkTrue(condition.TLSInputReadyCondition, condition.InputReadyMessage)
 
+	// The SST password is mounted in the pods when mariabackup needs it. It is
+	// not hashed with the other inputs, as a new password is applied without
+	// restarting them
+	if instance.Spec.SSTMethod == mariadbv1.GaleraSSTMethodMariabackup {
+		_, _, err = r.ensureGeneratedPassword(ctx, helper, instance, mariadb.SSTSecretName(instance.Name), mariadb.SSTPasswordKey)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	// Generate and hash config maps
 	err = r.generateConfigMaps(ctx, helper, instance, &inputHashEnv)
 	if err != nil {
@@ -644,11 +874,39 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 
 	// build state of the restart hash. this is used to decide whether the
 	// statefulset must stop all its pods before applying a config update
+	// NOTE the SST method is not a cluster property: the joiner requests
+	// a transfer with its own method, and every donor is able to serve
+	// both methods, so a new method is rolled out one pod at a time
 	clusterPropertiesEnv["GCommTLS"] = env.SetValue(strconv.FormatBool(instance.Spec.TLS.Enabled() && instance.Spec.TLS.Ca.CaBundleSecretName != ""))
+	// all nodes must agree on the GTID mode, so toggling the binary log
+	// requires a full restart. The property is only tracked when enabled,
+	// to keep the hash of existing clusters unchanged
+	if instance.Spec.BinlogArchive != nil {
+		clusterPropertiesEnv["LogBin"] = env.SetValue("true")
+	}
+	// options of the CustomServiceConfig that all nodes must agree on. Like
+	// LogBin, they are only tracked when set in the CustomServiceConfig
+	configOptionsEnv := make(map[string]env.Setter)
+	for name, value := range instance.ClusterConfigOptions() {
+		configOptionsEnv[name] = env.SetValue(value)
+	}
+	// the hash of the cluster properties before the options were tracked,
+	// to recognize the clusters deployed by an older operator
+	untrackedPropertiesHash, err := util.HashOfInputHashes(clusterPropertiesEnv)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for name, s := range configOptionsEnv {
+		clusterPropertiesEnv[name] = s
+	}
 	clusterPropertiesHash, err := util.HashOfInputHashes(clusterPropertiesEnv)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+	configOptionsHash, err := util.HashOfInputHashes(configOptionsEnv)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 	inputHashEnv["ClusterProperties"] = env.SetValue(clusterPropertiesHash)
 
 	//
@@ -669,11 +927,22 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 		instance.Status.ClusterProperties[k] = envVar.Value
 	}
 
-	// check whether we need to stop the cluster after a cluster-wide change
-	if oldPropertiesHash, exists := instance.Status.Hash["ClusterProperties"]; exists {
+	// check whether we need to stop the cluster after a cluster-wide change.
+	// A cluster whose status has no hash for the options of the CustomServiceConfig
+	// yet already runs with them, so they are seeded rather than treated as a change
+	oldPropertiesHash, exists := instance.Status.Hash["ClusterProperties"]
+	if _, tracked := instance.Status.Hash[mariadbv1.ClusterConfigOptionsHash]; exists && !tracked && oldPropertiesHash == untrackedPropertiesHash {
+		util.LogForObject(helper, "Seeding the cluster properties with the options of the CustomServiceConfig", instance)
+		oldPropertiesHash = clusterPropertiesHash
+	}
+	instance.Status.Hash, _ = util.SetHash(instance.Status.Hash, mariadbv1.ClusterConfigOptionsHash, configOptionsHash)
+	if exists {
 		if oldPropertiesHash != clusterPropertiesHash {
 			util.LogForObject(helper, fmt.Sprintf("ClusterProperties changed (%#v -> %#v), cluster restart required", oldPropertiesHash, clusterPropertiesHash), instance)
 			instance.Status.StopRequired = true
+			galeraClusterStops.WithLabelValues(instance.Namespace, instance.Name, "ClusterProperties").Inc()
+			r.Recorder.Event(instance, corev1.EventTypeNormal, "ClusterStopRequired",
+				"Cluster-wide configuration changed, stopping all galera pods")
 			// Do not return here, let the return happen after the other
 			// config hashes get updated due to this hash change
 		}
@@ -692,7 +961,43 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 		return ctrl.Result{}, nil
 	}
 
-	commonstatefulset := commonstatefulset.NewStatefulSet(mariadb.StatefulSet(instance, hashOfHashes), 5)
+	// A GaleraRestore replaces the data of the cluster, which can only
+	// be done while all the galera pods are stopped
+	restoreName, restoreInProgress := instance.Annotations[mariadbv1.GaleraRestoreAnnotation]
+	if restoreInProgress && !instance.Status.StopRequired {
+		util.LogForObject(helper, fmt.Sprintf("GaleraRestore %s requested, cluster stop required", restoreName), instance)
+		instance.Status.StopRequired = true
+		galeraClusterStops.WithLabelValues(instance.Namespace, instance.Name, "Restore").Inc()
+		r.Recorder.Eventf(instance, corev1.EventTypeNormal, "ClusterStopRequired",
+			"GaleraRestore %s requested, stopping all galera pods", restoreName)
+	}
+
+	// A new container image is rolled out one galera pod at a time
+	checkImageUpgrade(helper, instance)
+
+	sts, err := mariadb.StatefulSet(instance, hashOfHashes)
+	if err != nil {
+		// The pod template override can't be applied. This is only fixed
+		// by a change of the spec, which triggers a new reconcile
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			condition.DeploymentReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityWarning,
+			condition.DeploymentReadyErrorMessage,
+			err.Error()))
+		return ctrl.Result{}, nil
+	}
+	if !instance.Status.StopRequired {
+		err = r.scaleDownGradually(ctx, sts)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	err = r.keepVolumeClaimTemplates(ctx, sts)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	commonstatefulset := commonstatefulset.NewStatefulSet(sts, 5)
 	sfres, sferr := commonstatefulset.CreateOrPatch(ctx, helper)
 	if sferr != nil {
 		if k8s_errors.IsNotFound(sferr) {
@@ -703,12 +1008,45 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 
 	// util.LogForObject(helper, fmt.Sprintf("DAM BEFORE %v - AFTER %v", helper.GetBefore(), helper.GetAfter()), instance)
 
+	err = r.reconcileArbitrator(ctx, instance, hashOfHashes)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = r.reconcilePodDisruptionBudget(ctx, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	statefulset := commonstatefulset.GetStatefulSet()
 
+	// While a restore is in progress, the data on disk is being replaced,
+	// so any seqno probed so far is meaningless. Keep the cluster stopped
+	// and do not probe the pods until the restore is finished
+	if restoreInProgress {
+		for node := range instance.Status.Attributes {
+			clearPodAttributes(instance, node)
+		}
+		instance.Status.Bootstrapped = statefulset.Status.AvailableReplicas > 0
+		instance.Status.Conditions.MarkFalse(
+			condition.DeploymentReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			mariadbv1.GaleraStoppedForRestoreMessage,
+			restoreName)
+		if statefulset.Status.Replicas > 0 {
+			log.Info("Requeuing until all replicas are stopped for restore")
+			return ctrl.Result{RequeueAfter: time.Duration(3) * time.Second}, nil
+		}
+		return ctrl.Result{}, nil
+	}
+
 	// If a full cluster restart was requested,
 	// check whether it is still in progress
 	if instance.Status.StopRequired && statefulset.Status.Replicas == 0 {
 		util.LogForObject(helper, "Full cluster restart finished, config update can now proceed", instance)
+		r.Recorder.Event(instance, corev1.EventTypeNormal, "ClusterStopped",
+			"All galera pods stopped, the cluster will be restarted")
 		instance.Status.StopRequired = false
 		// return now to force the next reconcile to reconfigure
 		// the statefulset to recreate the pods
@@ -736,13 +1074,33 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 	}
 
 	// Ensure that all the ongoing galera start actions are still running
-	assertPodsAttributesValidity(helper, instance, podList.Items)
+	assertPodsAttributesValidity(helper, r.Recorder, instance, podList.Items)
 
 	// Note:
 	//   . A pod is available in the statefulset if the pod's readiness
 	//     probe returns true (i.e. galera is running in the pod and clustered)
 	//   . Cluster is bootstrapped as soon as one pod is available
+	wasBootstrapped := instance.Status.Bootstrapped
 	instance.Status.Bootstrapped = statefulset.Status.AvailableReplicas > 0
+	if instance.Status.Bootstrapped {
+		galeraBootstrapped.WithLabelValues(instance.Namespace, instance.Name).Set(1)
+	} else {
+		galeraBootstrapped.WithLabelValues(instance.Namespace, instance.Name).Set(0)
+	}
+	if instance.Status.Bootstrapped && !wasBootstrapped && instance.Status.InspectionStartTime != nil {
+		galeraBootstrapDuration.WithLabelValues(instance.Namespace, instance.Name).Observe(
+			time.Since(instance.Status.InspectionStartTime.Time).Seconds())
+	}
+
+	// Track how long the controller has been looking for a bootstrap
+	// candidate since the first pod was inspected, for the Majority
+	// bootstrap policy
+	if instance.Status.Bootstrapped || len(instance.Status.Attributes) == 0 {
+		instance.Status.InspectionStartTime = nil
+	} else if instance.Status.InspectionStartTime == nil {
+		now := metav1.Now()
+		instance.Status.InspectionStartTime = &now
+	}
 
 	if instance.Status.Bootstrapped {
 		// Sync Ready condition
@@ -758,6 +1116,20 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 			}
 		}
 
+		// The donor of a mariabackup SST needs the SST user, configure it
+		// as soon as the bootstrap node runs and before any node joins
+		if instance.Spec.SSTMethod == mariadbv1.GaleraSSTMethodMariabackup {
+			ctrlResult, err := r.reconcileSSTUser(ctx, helper, instance, podList.Items)
+			if err != nil || (ctrlResult != ctrl.Result{}) {
+				return ctrlResult, err
+			}
+		} else if _, found := instance.Status.Hash[mariadbv1.SSTUserPasswordHash]; found {
+			ctrlResult, err := r.dropSSTUser(ctx, helper, instance, podList.Items)
+			if err != nil || (ctrlResult != ctrl.Result{}) {
+				return ctrlResult, err
+			}
+		}
+
 		runningPods := getRunningPodsMissingGcomm(ctx, podList.Items, instance, helper, r.config)
 		// Special case for 1-node deployment: if the statefulset reports 1 node is available
 		// but the pod shows up in runningPods (i.e. NotReady), do not consider it a joiner.
@@ -776,11 +1148,43 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 			err := injectGcommURI(ctx, helper, r.config, instance, &pod, joinerURI)
 			if err != nil {
 				log.Error(err, "Failed to push gcomm URI", "pod", name)
+				r.Recorder.Eventf(instance, corev1.EventTypeWarning, "GcommInjectionFailed",
+					"Failed to push gcomm URI to joiner pod %s: %s", name, err.Error())
 				// A failed injection likely means the pod's status has changed.
 				// drop it from status and reprobe it in another reconcile loop
 				clearPodAttributes(instance, name)
 				return ctrl.Result{}, err
 			}
+			r.Recorder.Eventf(instance, corev1.EventTypeNormal, "JoiningCluster",
+				"Pod %s is joining the galera cluster", name)
+		}
+	}
+
+	// Move traffic away from the nodes put in maintenance
+	if instance.Status.Bootstrapped {
+		err = r.reconcileMaintenance(ctx, helper, instance, service, podList.Items)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	} else {
+		// a stopped cluster restarts every node with wsrep_desync disabled
+		instance.Status.Maintenance = nil
+	}
+
+	// Point the read service to the passive nodes
+	err = r.reconcileReadEndpoints(ctx, instance, service.Spec.Selector[mariadb.ActivePodSelectorKey], podList.Items)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Report the state of the running galera nodes
+	reconcileNodeStatus(ctx, helper, r.config, instance, podList.Items)
+
+	// Honour a manual request to bootstrap the cluster from a given pod
+	if podName, forced := instance.Annotations[mariadbv1.GaleraForceBootstrapAnnotation]; forced {
+		err = r.forceBootstrap(ctx, helper, instance, podList.Items, podName)
+		if err != nil {
+			return ctrl.Result{}, err
 		}
 	}
 
@@ -806,6 +1210,11 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 				log.Error(err, fmt.Sprintf("Failed to retrieve seqno for %s", name))
 				return ctrl.Result{}, err
 			}
+			// the bootstrap duration is measured from the first inspected pod
+			if instance.Status.InspectionStartTime == nil {
+				now := metav1.Now()
+				instance.Status.InspectionStartTime = &now
+			}
 			log.Info(fmt.Sprintf("Attributes retrieved for %s", name),
 				"UUID", instance.Status.Attributes[name].UUID,
 				"Seqno", instance.Status.Attributes[name].Seqno,
@@ -820,7 +1229,13 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 
 		// Check if we have enough info to bootstrap the cluster now
 		if !found && int(*instance.Spec.Replicas) > 0 {
-			node, found = findBestCandidate(instance)
+			quorum := instance.BootstrapQuorum(time.Now())
+			node, found = findBestCandidate(instance, quorum)
+			if found && len(instance.Status.Attributes) < int(*instance.Spec.Replicas) {
+				r.Recorder.Eventf(instance, corev1.EventTypeWarning, "MajorityBootstrap",
+					"Bootstrapping galera cluster from pod %s after inspecting %d/%d pods",
+					node, len(instance.Status.Attributes), *instance.Spec.Replicas)
+			}
 		}
 		if found {
 			pod := getPodFromName(podList.Items, node)
@@ -829,11 +1244,35 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 			err := injectGcommURI(ctx, helper, r.config, instance, pod, "gcomm://")
 			if err != nil {
 				log.Error(err, "Failed to push gcomm URI", "pod", node)
+				r.Recorder.Eventf(instance, corev1.EventTypeWarning, "GcommInjectionFailed",
+					"Failed to push gcomm URI to bootstrap pod %s: %s", node, err.Error())
 				// A failed injection likely means the pod's status has changed.
 				// drop it from status and reprobe it in another reconcile loop
 				clearPodAttributes(instance, node)
 				return ctrl.Result{}, err
 			}
+			r.Recorder.Eventf(instance, corev1.EventTypeNormal, "Bootstrapping",
+				"Bootstrapping galera cluster from pod %s (seqno %s)", node, instance.Status.Attributes[node].Seqno)
+		}
+	}
+
+	// Move the rollout of a new container image to the next pod once
+	// the last upgraded pod is synced with the cluster
+	if instance.Status.Bootstrapped && instance.Status.Upgrade != nil {
+		ctrlResult, err := r.reconcileUpgrade(ctx, helper, instance, &statefulset, podList.Items)
+		if err != nil || (ctrlResult != ctrl.Result{}) {
+			return ctrlResult, err
+		}
+	}
+
+	// Rotate the root password as soon as a pod is ready, without waiting
+	// for the cluster to be fully available: the probes of the pods fall
+	// back to the previous password until the rotation is done, and a pod
+	// restarted in the meantime no longer knows the previous password
+	if instance.Status.Bootstrapped && instance.Status.Hash[mariadbv1.DbRootPasswordHash] != rootPasswordHash {
+		ctrlResult, err := r.rotateRootPassword(ctx, helper, instance, podList.Items, rootPasswordHash)
+		if err != nil || (ctrlResult != ctrl.Result{}) {
+			return ctrlResult, err
 		}
 	}
 
@@ -847,13 +1286,59 @@ func (r *GaleraReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 		return ctrl.Result{RequeueAfter: time.Duration(3) * time.Second}, nil
 	}
 
+	// Record the server version once all the pods run the container image
+	if instance.Status.Upgrade == nil && statefulset.Status.UpdatedReplicas == statefulset.Status.Replicas &&
+		(instance.Status.ContainerImage != instance.Spec.ContainerImage || instance.Status.ServerVersion == "") {
+		if readyPods := getReadyPods(podList.Items); len(readyPods) > 0 {
+			version, err := getServerVersion(ctx, helper, r.config, instance, readyPods[0].Name)
+			if err != nil {
+				log.Error(err, "Failed to retrieve the server version", "pod", readyPods[0].Name)
+				return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+			}
+			instance.Status.ServerVersion = version
+			instance.Status.ContainerImage = instance.Spec.ContainerImage
+		}
+	}
+
+	// Configure the monitoring user once the cluster is fully available
+	if instance.Spec.Metrics != nil || instance.Status.Hash[mariadbv1.MetricsUserPasswordHash] != "" {
+		ctrlResult, err := r.reconcileMetricsUser(ctx, helper, instance, podList.Items)
+		if err != nil || (ctrlResult != ctrl.Result{}) {
+			return ctrlResult, err
+		}
+	}
+
+	// Run the scheduled backups once the cluster is fully available
+	if instance.Spec.Backup != nil {
+		result, err = r.reconcileBackupSchedule(ctx, helper, instance)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Grow the volumes of the galera pods once the cluster is fully available
+	storageResult, err := r.reconcileStorage(ctx, helper, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if storageResult.RequeueAfter > 0 && (result.RequeueAfter == 0 || storageResult.RequeueAfter < result.RequeueAfter) {
+		result = storageResult
+	}
+
+	// Requeue to refresh the state of the galera nodes periodically, as the
+	// flow control and the last committed transaction change without any
+	// event. Their status is collected at most once per interval
+	if result.RequeueAfter == 0 || result.RequeueAfter > galeraNodeStatusInterval {
+		result.RequeueAfter = galeraNodeStatusInterval
+	}
+
 	// We reached the end of the Reconcile, update the Ready condition based on
 	// the sub conditions
 	if instance.Status.Conditions.AllSubConditionIsTrue() {
 		instance.Status.Conditions.MarkTrue(
 			condition.ReadyCondition, condition.ReadyMessage)
 	}
-	return ctrl.Result{}, err
+	return result, err
 }
 
 // configMapNameForScripts - name of the configmap that holds the
@@ -876,8 +1361,15 @@ func (r *GaleraReconciler) generateConfigMaps(
 	envVars *map[string]env.Setter,
 ) error {
 	log := GetLog(ctx, "galera")
+	sstMethod := instance.Spec.SSTMethod
+	if sstMethod == "" {
+		sstMethod = mariadbv1.GaleraSSTMethodRsync
+	}
 	templateParameters := map[string]interface{}{
 		"logToDisk": instance.Spec.LogToDisk,
+		"logBin":    instance.Spec.BinlogArchive != nil,
+		"sstMethod": sstMethod,
+		"tuning":    instance.Tuning(),
 	}
 	customData := make(map[string]string)
 	customData[mariadbv1.CustomServiceConfigFile] = instance.Spec.CustomServiceConfig
@@ -912,6 +1404,408 @@ func (r *GaleraReconciler) generateConfigMaps(
 	return nil
 }
 
+// rotateRootPassword changes the password of the root user in the database to the
+// one from the Secret of the galera CR, and verifies that every pod can use it.
+// Once done, the new password hash is recorded so the pods get restarted with it
+func (r *GaleraReconciler) rotateRootPassword(ctx context.Context, h *helper.Helper, instance *mariadbv1.Galera, pods []corev1.Pod, rootPasswordHash string) (ctrl.Result, error) {
+	log := h.GetLogger()
+	readyPods := getReadyPods(pods)
+	if len(readyPods) == 0 {
+		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+	}
+
+	// The change is run from a live node and replicated to the others.
+	// The new password is only known once the secret is refreshed in
+	// the pod, and the old one is only known by the pods that were not
+	// restarted since the secret changed, so try every ready pod in turn
+	var err error
+	for _, pod := range readyPods {
+		instance.Status.Conditions.MarkFalse(
+			mariadbv1.GaleraRootPasswordReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			mariadbv1.GaleraRootPasswordRotatingMessage,
+			pod.Name)
+		err = execInPod(ctx, h, r.config, instance.Namespace, pod.Name, "galera",
+			[]string{"/bin/bash", "/var/lib/operator-scripts/mysql_root_password_rotate.sh"},
+			func(stdout *bytes.Buffer, _ *bytes.Buffer) error {
+				log.Info("Root password rotated", "pod", pod.Name, "output", strings.TrimSpace(stdout.String()))
+				return nil
+			})
+		if err == nil {
+			break
+		}
+		log.Info("Root password rotation not possible from pod", "pod", pod.Name, "error", err.Error())
+	}
+	if err != nil {
+		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+	}
+
+	// Make sure every pod can connect with the new password before
+	// restarting them, as the probes and scripts rely on it
+	for _, pod := range readyPods {
+		err = execSQLInPod(ctx, h, r.config, instance, pod.Name, "SELECT 1;",
+			func(_ *bytes.Buffer) error {
+				return nil
+			})
+		if err != nil {
+			log.Info("New root password not usable yet", "pod", pod.Name, "error", err.Error())
+			instance.Status.Conditions.MarkFalse(
+				mariadbv1.GaleraRootPasswordReadyCondition,
+				condition.RequestedReason,
+				condition.SeverityInfo,
+				mariadbv1.GaleraRootPasswordVerifyingMessage,
+				pod.Name)
+			return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+		}
+	}
+
+	util.LogForObject(h, "Root password rotated, pods will be restarted with the new password", instance)
+	instance.Status.Hash[mariadbv1.DbRootPasswordHash] = rootPasswordHash
+	instance.Status.Conditions.MarkTrue(mariadbv1.GaleraRootPasswordReadyCondition, mariadbv1.GaleraRootPasswordReadyMessage)
+	// return now to force the next reconcile to update the statefulset
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// reconcileSSTUser creates the database user used by mariabackup on the donor
+// of a state snapshot transfer, and updates it whenever the password in the SST
+// secret changes. The running galera nodes are given the new password directly,
+// the restarted ones read it from the secret
+func (r *GaleraReconciler) reconcileSSTUser(ctx context.Context, h *helper.Helper, instance *mariadbv1.Galera, pods []corev1.Pod) (ctrl.Result, error) {
+	log := h.GetLogger()
+	password, hash, err := r.ensureGeneratedPassword(ctx, h, instance, mariadb.SSTSecretName(instance.Name), mariadb.SSTPasswordKey)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if instance.Status.Hash[mariadbv1.SSTUserPasswordHash] == hash {
+		return ctrl.Result{}, nil
+	}
+	readyPods := getReadyPods(pods)
+	if len(readyPods) == 0 {
+		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+	}
+
+	// mariabackup connects to the donor through the local socket
+	user := "'" + mariadb.SSTUserName + "'@'localhost'"
+	sql := "CREATE USER IF NOT EXISTS " + user + " IDENTIFIED BY " + sqlQuote(password) + ";" +
+		"ALTER USER " + user + " IDENTIFIED BY " + sqlQuote(password) + ";" +
+		"GRANT RELOAD, PROCESS, LOCK TABLES, REPLICATION CLIENT ON *.* TO " + user + ";"
+	err = execSQLInPod(ctx, h, r.config, instance, readyPods[0].Name, sql,
+		func(_ *bytes.Buffer) error {
+			return nil
+		})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for _, pod := range readyPods {
+		err = execSQLInPod(ctx, h, r.config, instance, pod.Name,
+			"SET GLOBAL wsrep_sst_auth = "+sqlQuote(mariadb.SSTUserName+":"+password)+";",
+			func(_ *bytes.Buffer) error {
+				return nil
+			})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	instance.Status.Hash[mariadbv1.SSTUserPasswordHash] = hash
+	log.Info("SST user configured", "pods", len(readyPods))
+	return ctrl.Result{}, nil
+}
+
+// dropSSTUser removes the database user used by mariabackup once the galera
+// cluster no longer uses mariabackup for its state snapshot transfers
+func (r *GaleraReconciler) dropSSTUser(ctx context.Context, h *helper.Helper, instance *mariadbv1.Galera, pods []corev1.Pod) (ctrl.Result, error) {
+	log := h.GetLogger()
+	readyPods := getReadyPods(pods)
+	if len(readyPods) == 0 {
+		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+	}
+	err := execSQLInPod(ctx, h, r.config, instance, readyPods[0].Name,
+		"DROP USER IF EXISTS '"+mariadb.SSTUserName+"'@'localhost';",
+		func(_ *bytes.Buffer) error {
+			return nil
+		})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	delete(instance.Status.Hash, mariadbv1.SSTUserPasswordHash)
+	log.Info("SST user removed", "pod", readyPods[0].Name)
+	return ctrl.Result{}, nil
+}
+
+// ensureGeneratedPassword creates a secret holding a random password under the
+// given key, unless the secret already exists. It returns the password and the
+// hash of the secret
+func (r *GaleraReconciler) ensureGeneratedPassword(ctx context.Context, h *helper.Helper, instance *mariadbv1.Galera, name string, key string) (string, string, error) {
+	s := &corev1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: instance.Namespace}, s)
+	if k8s_errors.IsNotFound(err) {
+		buf := make([]byte, 16)
+		if _, err = rand.Read(buf); err != nil {
+			return "", "", err
+		}
+		s = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: instance.Namespace,
+				Labels:    mariadb.StatefulSetLabels(instance),
+			},
+			Data: map[string][]byte{
+				key: []byte(hex.EncodeToString(buf)),
+			},
+		}
+		_, _, err = secret.CreateOrPatchSecret(ctx, h, instance, s)
+		if err != nil {
+			return "", "", err
+		}
+	} else if err != nil {
+		return "", "", err
+	}
+	hash, err := secret.Hash(s)
+	if err != nil {
+		return "", "", err
+	}
+	return string(s.Data[key]), hash, nil
+}
+
+// scaleDownGradually limits a scale down of the statefulset to one pod at a time.
+// With the parallel pod management policy, all the removed pods would otherwise
+// be stopped at once, and the remaining galera nodes could lose quorum (e.g. 7 -> 3).
+// The next pod is only removed once the previous one is gone
+func (r *GaleraReconciler) scaleDownGradually(ctx context.Context, sts *appsv1.StatefulSet) error {
+	current := &appsv1.StatefulSet{}
+	err := r.Get(ctx, types.NamespacedName{Name: sts.Name, Namespace: sts.Namespace}, current)
+	if k8s_errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if current.Spec.Replicas == nil || *sts.Spec.Replicas >= *current.Spec.Replicas-1 {
+		return nil
+	}
+	replicas := *current.Spec.Replicas
+	if current.Status.Replicas <= replicas {
+		replicas--
+	}
+	GetLog(ctx, "galera").Info("Scaling down one pod at a time", "statefulset", sts.Name, "replicas", replicas, "target", *sts.Spec.Replicas)
+	sts.Spec.Replicas = &replicas
+	return nil
+}
+
+// forceBootstrap pushes an empty gcomm URI to the pod named in the force-bootstrap
+// annotation, to bootstrap a new cluster from it regardless of the seqno of the
+// other pods. The annotation is removed once honoured, or if it can't be honoured
+func (r *GaleraReconciler) forceBootstrap(ctx context.Context, h *helper.Helper, instance *mariadbv1.Galera, pods []corev1.Pod, podName string) error {
+	log := h.GetLogger()
+	if instance.Status.Bootstrapped || isBootstrapInProgress(instance) {
+		delete(instance.Annotations, mariadbv1.GaleraForceBootstrapAnnotation)
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, "ForceBootstrapIgnored",
+			"Bootstrap from pod %s not forced, the galera cluster is already running", podName)
+		return nil
+	}
+
+	valid := false
+	for i := 0; i < int(*instance.Spec.Replicas); i++ {
+		if podName == mariadb.StatefulSetName(instance.Name)+"-"+strconv.Itoa(i) {
+			valid = true
+		}
+	}
+	if !valid {
+		delete(instance.Annotations, mariadbv1.GaleraForceBootstrapAnnotation)
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, "ForceBootstrapIgnored",
+			"Bootstrap from pod %s not forced, the pod is not a galera node", podName)
+		return nil
+	}
+
+	// the pod must be waiting for a gcomm URI to start galera
+	pod := getPodFromName(pods, podName)
+	if pod == nil || pod.Status.Phase != corev1.PodRunning || podutils.IsPodReady(pod) ||
+		!isGaleraContainerStartedAndWaiting(ctx, pod, instance, h, r.config) {
+		log.Info("Waiting for the pod to start before forcing the bootstrap", "pod", podName)
+		return nil
+	}
+
+	log.Info("Pushing gcomm URI to force bootstrap", "pod", podName)
+	err := injectGcommURI(ctx, h, r.config, instance, pod, "gcomm://")
+	if err != nil {
+		log.Error(err, "Failed to push gcomm URI", "pod", podName)
+		clearPodAttributes(instance, podName)
+		return err
+	}
+
+	requestedBy := getAnnotationManager(instance, mariadbv1.GaleraForceBootstrapAnnotation)
+	instance.Status.ForcedBootstrap = &mariadbv1.GaleraForcedBootstrap{
+		Pod:         podName,
+		RequestedBy: requestedBy,
+		Time:        metav1.Now(),
+	}
+	delete(instance.Annotations, mariadbv1.GaleraForceBootstrapAnnotation)
+	r.Recorder.Eventf(instance, corev1.EventTypeWarning, "ForcedBootstrap",
+		"Galera cluster bootstrap forced from pod %s by %s, transactions not replicated to this pod are lost", podName, requestedBy)
+	return nil
+}
+
+// getAnnotationManager returns the name of the field manager that last set
+// an annotation on an object, or "unknown" if it isn't recorded
+func getAnnotationManager(obj metav1.Object, annotation string) string {
+	manager := "unknown"
+	for _, entry := range obj.GetManagedFields() {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+		var fields struct {
+			Metadata struct {
+				Annotations map[string]interface{} `json:"f:annotations"`
+			} `json:"f:metadata"`
+		}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		if _, found := fields.Metadata.Annotations["f:"+annotation]; found {
+			manager = entry.Manager
+		}
+	}
+	return manager
+}
+
+// reconcileNodeStatus collects the wsrep state of the galera nodes that run
+// mysqld, at most once per galeraNodeStatusInterval, and summarizes it in
+// the status of the galera CR. Nodes that can't be queried are dropped
+func reconcileNodeStatus(ctx context.Context, h *helper.Helper, config *rest.Config, instance *mariadbv1.Galera, pods []corev1.Pod) {
+	log := h.GetLogger()
+	nodes := map[string]mariadbv1.GaleraNodeStatus{}
+	for _, pod := range pods {
+		// mysqld only runs in pods that are ready, or that
+		// were instructed to start galera
+		maintenance := slices.Contains(instance.Status.Maintenance, pod.Name)
+		if !podutils.IsPodReady(&pod) && instance.Status.Attributes[pod.Name].Gcomm == "" && !maintenance {
+			continue
+		}
+		node, found := instance.Status.Nodes[pod.Name]
+		if !found || time.Since(node.LastUpdateTime.Time) >= galeraNodeStatusInterval {
+			var err error
+			node, err = getGaleraNodeStatus(ctx, h, config, instance, pod.Name)
+			if err != nil {
+				log.Info("Failed to retrieve the wsrep state", "pod", pod.Name, "error", err.Error())
+				continue
+			}
+		}
+		node.State = mariadbv1.GaleraNodeStateRunning
+		if maintenance {
+			node.State = mariadbv1.GaleraNodeStateMaintenance
+		}
+		nodes[pod.Name] = node
+	}
+
+	instance.Status.Nodes = nodes
+	instance.Status.ClusterSize = 0
+	instance.Status.SyncedNodes = 0
+	for _, node := range nodes {
+		instance.Status.ClusterSize = max(instance.Status.ClusterSize, node.ClusterSize)
+		if node.LocalState == "Synced" {
+			instance.Status.SyncedNodes++
+		}
+	}
+}
+
+// reconcileReadEndpoints points the read service to the Synced galera pods that
+// are not the active endpoint of the database service. When no such pod
+// exists, the read service falls back to the active pod
+func (r *GaleraReconciler) reconcileReadEndpoints(ctx context.Context, instance *mariadbv1.Galera, activePod string, pods []corev1.Pod) error {
+	readyPods := getReadyPods(pods)
+	passivePods := []corev1.Pod{}
+	for _, pod := range readyPods {
+		if pod.Name != activePod {
+			passivePods = append(passivePods, pod)
+		}
+	}
+	if len(passivePods) == 0 {
+		passivePods = readyPods
+	}
+	sort.Slice(passivePods, func(i, j int) bool {
+		return passivePods[i].Name < passivePods[j].Name
+	})
+
+	pkgep := mariadb.ReadEndpoints(instance, passivePods)
+	endpoints := &corev1.Endpoints{ObjectMeta: pkgep.ObjectMeta}
+	op, err := controllerutil.CreateOrPatch(ctx, r.Client, endpoints, func() error {
+		endpoints.Labels = pkgep.Labels
+		endpoints.Subsets = pkgep.Subsets
+		return controllerutil.SetControllerReference(instance, endpoints, r.Client.Scheme())
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		GetLog(ctx, "galera").Info("Read service endpoints updated", "endpoints", endpoints.Name, "pods", len(passivePods))
+	}
+	return nil
+}
+
+// reconcileArbitrator creates or updates the deployment of the galera arbitrator,
+// or deletes it when the arbitrator is removed from the spec
+func (r *GaleraReconciler) reconcileArbitrator(ctx context.Context, instance *mariadbv1.Galera, configHash string) error {
+	log := GetLog(ctx, "galera")
+	if instance.Spec.Arbitrator == nil {
+		deployment := &appsv1.Deployment{}
+		err := r.Client.Get(ctx, types.NamespacedName{Name: mariadb.ArbitratorName(instance.Name), Namespace: instance.Namespace}, deployment)
+		if err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		err = r.Client.Delete(ctx, deployment)
+		if err != nil && !k8s_errors.IsNotFound(err) {
+			return err
+		}
+		log.Info("Arbitrator removed", "deployment", deployment.Name)
+		return nil
+	}
+
+	// the arbitrator connects to the galera nodes only
+	gcommURI := "gcomm://" + strings.Join(galeraNodeHostnames(instance), ",")
+	pkgdep := mariadb.ArbitratorDeployment(instance, gcommURI, configHash)
+	deployment := &appsv1.Deployment{ObjectMeta: pkgdep.ObjectMeta}
+	op, err := controllerutil.CreateOrPatch(ctx, r.Client, deployment, func() error {
+		deployment.Labels = pkgdep.Labels
+		deployment.Spec.Replicas = pkgdep.Spec.Replicas
+		deployment.Spec.Strategy = pkgdep.Spec.Strategy
+		deployment.Spec.Template = pkgdep.Spec.Template
+		// the selector is immutable
+		if deployment.CreationTimestamp.IsZero() {
+			deployment.Spec.Selector = pkgdep.Spec.Selector
+		}
+		return controllerutil.SetControllerReference(instance, deployment, r.Client.Scheme())
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		log.Info("", "Kind", instance.Kind, "Name", instance.Name, "arbitrator deployment", deployment.Name, "operation", string(op))
+	}
+	return nil
+}
+
+// reconcilePodDisruptionBudget creates or updates the PodDisruptionBudget
+// that protects the quorum of the galera cluster during voluntary evictions
+func (r *GaleraReconciler) reconcilePodDisruptionBudget(ctx context.Context, instance *mariadbv1.Galera) error {
+	log := GetLog(ctx, "galera")
+	pkgpdb := mariadb.PodDisruptionBudget(instance)
+	pdb := &policyv1.PodDisruptionBudget{ObjectMeta: pkgpdb.ObjectMeta}
+	op, err := controllerutil.CreateOrPatch(ctx, r.Client, pdb, func() error {
+		pdb.Labels = pkgpdb.Labels
+		pdb.Spec.MaxUnavailable = pkgpdb.Spec.MaxUnavailable
+		pdb.Spec.Selector = pkgpdb.Spec.Selector
+		return controllerutil.SetControllerReference(instance, pdb, r.Client.Scheme())
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		log.Info("", "Kind", instance.Kind, "Name", instance.Name, "pod disruption budget", pdb.Name,
+			"maxUnavailable", pdb.Spec.MaxUnavailable.String(), "operation", string(op))
+	}
+	return nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *GaleraReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.config = mgr.GetConfig()
@@ -942,21 +1836,58 @@ func (r *GaleraReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	}); err != nil {
 		return err
 	}
+	// index secret, a change of the root password starts its rotation
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &mariadbv1.Galera{}, rootSecretNameField, func(rawObj client.Object) []string {
+		cr := rawObj.(*mariadbv1.Galera)
+		if cr.Spec.Secret != "" {
+			return []string{cr.Spec.Secret}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	// index sstMethod, a change of the password of the SST user updates it
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &mariadbv1.Galera{}, sstSecretNameField, func(rawObj client.Object) []string {
+		cr := rawObj.(*mariadbv1.Galera)
+		if cr.Spec.SSTMethod == mariadbv1.GaleraSSTMethodMariabackup {
+			return []string{mariadb.SSTSecretName(cr.Name)}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&mariadbv1.Galera{}).
 		Owns(&appsv1.StatefulSet{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
 		Owns(&corev1.Service{}).
 		Owns(&corev1.Endpoints{}).
 		Owns(&corev1.ConfigMap{}).
 		Owns(&corev1.ServiceAccount{}).
 		Owns(&rbacv1.Role{}).
 		Owns(&rbacv1.RoleBinding{}).
+		Owns(&mariadbv1.GaleraBackup{}).
 		Watches(
 			&corev1.Secret{},
 			handler.EnqueueRequestsFromMapFunc(r.findObjectsForSrc),
 			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}),
 		).
+		// the endpoints of the read service follow the readiness of the
+		// pods, and pods are annotated to be put in maintenance
+		Watches(
+			&corev1.Pod{},
+			handler.EnqueueRequestsFromMapFunc(r.findGaleraForPod),
+			builder.WithPredicates(predicate.Funcs{
+				CreateFunc:  func(event.CreateEvent) bool { return false },
+				DeleteFunc:  func(event.DeleteEvent) bool { return true },
+				GenericFunc: func(event.GenericEvent) bool { return false },
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					return galeraPodChanged(e.ObjectOld, e.ObjectNew)
+				},
+			}),
+		).
 		Complete(r)
 }
 
@@ -1016,6 +1947,38 @@ func (r *GaleraReconciler) findObjectsForSrc(ctx context.Context, src client.Obj
 	return requests
 }
 
+// galeraPodChanged - returns true when a pod update requires the Galera CR that
+// owns the pod to be reconciled: a change of readiness or IP address, which
+// moves the endpoints of the read service, or a change of maintenance request
+func galeraPodChanged(before client.Object, after client.Object) bool {
+	_, beforeMaintenance := before.GetAnnotations()[mariadbv1.GaleraMaintenanceAnnotation]
+	_, afterMaintenance := after.GetAnnotations()[mariadbv1.GaleraMaintenanceAnnotation]
+	if beforeMaintenance != afterMaintenance {
+		return true
+	}
+	beforePod, ok := before.(*corev1.Pod)
+	if !ok {
+		return false
+	}
+	afterPod, ok := after.(*corev1.Pod)
+	if !ok {
+		return false
+	}
+	return podutils.IsPodReady(beforePod) != podutils.IsPodReady(afterPod) ||
+		beforePod.Status.PodIP != afterPod.Status.PodIP
+}
+
+// findGaleraForPod - returns a reconcile request for the Galera CR that owns a pod
+func (r *GaleraReconciler) findGaleraForPod(_ context.Context, pod client.Object) []reconcile.Request {
+	name, found := pod.GetLabels()[labels.GetOwnerNameLabelSelector("galera")]
+	if !found {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: name, Namespace: pod.GetNamespace()}},
+	}
+}
+
 func (r *GaleraReconciler) reconcileDelete(ctx context.Context, instance *databasev1beta1.Galera, helper *helper.Helper) (ctrl.Result, error) {
 	helper.GetLogger().Info("Reconciling Service delete")
 
@@ -1034,6 +1997,8 @@ func (r *GaleraReconciler) reconcileDelete(ctx context.Context, instance *databa
 		}
 	}
 
+	deleteGaleraMetrics(instance)
+
 	// Service is deleted so remove the finalizer.
 	controllerutil.RemoveFinalizer(instance, helper.GetFinalizer())
 	helper.GetLogger().Info("Reconciled Service delete successfully")
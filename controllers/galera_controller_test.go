@@ -0,0 +1,1204 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+
+	"github.com/openstack-k8s-operators/lib-common/modules/common"
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	"github.com/openstack-k8s-operators/lib-common/modules/common/env"
+	"github.com/openstack-k8s-operators/lib-common/modules/common/util"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRootPasswordChangeDoesNotRestartPods(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera := newTestGalera("openstack", 3)
+	secret := newTestSecret()
+	c := newFakeClient(galera, secret)
+	r := newTestGaleraReconciler(c)
+	stubExec(t, nil)
+
+	_, err := reconcileN(t, r, galera, 4)
+	g.Expect(err).ToNot(HaveOccurred())
+	galera = get(t, c, galera)
+	initial := galera.Status.Hash[mariadbv1.DbRootPasswordHash]
+	inputHash := galera.Status.Hash[common.InputHashName]
+	g.Expect(initial).ToNot(BeEmpty())
+	g.Expect(galera.Status.Hash).To(HaveKeyWithValue(mariadbv1.DbRootPasswordInitialHash, initial))
+	g.Expect(galera.Status.Conditions.IsTrue(mariadbv1.GaleraRootPasswordReadyCondition)).To(BeTrue())
+
+	// a new password in the secret is not used by the pods until
+	// it has been rotated in the database
+	secret = get(t, c, secret)
+	secret.Data["DbRootPassword"] = []byte("new-password")
+	g.Expect(c.Update(ctx, secret)).To(Succeed())
+	_, err = reconcileN(t, r, galera, 2)
+	g.Expect(err).ToNot(HaveOccurred())
+	galera = get(t, c, galera)
+	g.Expect(galera.Status.Hash).To(HaveKeyWithValue(mariadbv1.DbRootPasswordHash, initial))
+	g.Expect(galera.Status.Hash).To(HaveKeyWithValue(common.InputHashName, inputHash))
+	cond := galera.Status.Conditions.Get(mariadbv1.GaleraRootPasswordReadyCondition)
+	g.Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(cond.Message).To(Equal(mariadbv1.GaleraRootPasswordRotationPendingMessage))
+
+	// once rotated, the pods are restarted with the new password
+	galera.Status.Hash[mariadbv1.DbRootPasswordHash] = "rotated"
+	g.Expect(c.Status().Update(ctx, galera)).To(Succeed())
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	galera = get(t, c, galera)
+	g.Expect(galera.Status.Hash[common.InputHashName]).ToNot(Equal(inputHash))
+	g.Expect(galera.Status.Hash).To(HaveKeyWithValue(mariadbv1.DbRootPasswordInitialHash, initial))
+}
+
+func TestRootPasswordRotatedWhileUnavailable(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera, objs := newBootstrappedGalera(3)
+	objs[len(objs)-1] = newTestGaleraPod(galera, 2, false)
+	c := newFakeClient(objs...)
+	r := newTestGaleraReconciler(c)
+	exec := stubExec(t, galeraStatusReply("Synced"))
+	_, err := reconcileN(t, r, galera, 4)
+	g.Expect(err).ToNot(HaveOccurred())
+	sts := get(t, c, &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "openstack-galera", Namespace: testNamespace}})
+	sts.Status.Replicas = 3
+	sts.Status.ReadyReplicas = 2
+	sts.Status.AvailableReplicas = 2
+	g.Expect(c.Status().Update(ctx, sts)).To(Succeed())
+	initial := get(t, c, galera).Status.Hash[mariadbv1.DbRootPasswordHash]
+
+	// the rotation does not wait for the pod that is not ready, whose
+	// probes keep using the previous password until then
+	secret := get(t, c, newTestSecret())
+	secret.Data["DbRootPassword"] = []byte("new-password")
+	g.Expect(c.Update(ctx, secret)).To(Succeed())
+	_, err = reconcileN(t, r, galera, 2)
+	g.Expect(err).ToNot(HaveOccurred())
+	galera = get(t, c, galera)
+	g.Expect(galera.Status.Hash[mariadbv1.DbRootPasswordHash]).ToNot(Equal(initial))
+	g.Expect(galera.Status.Conditions.IsTrue(mariadbv1.GaleraRootPasswordReadyCondition)).To(BeTrue())
+	g.Expect(exec.ran("openstack-galera-0", "mysql_root_password_rotate.sh")).To(HaveLen(1))
+	g.Expect(exec.ran("openstack-galera-2", "mysql_root_password_rotate.sh")).To(BeEmpty())
+}
+
+func TestRotateRootPassword(t *testing.T) {
+	galera := newTestGalera("openstack", 3)
+	pods := []corev1.Pod{
+		*newTestGaleraPod(galera, 0, true),
+		*newTestGaleraPod(galera, 1, true),
+		*newTestGaleraPod(galera, 2, false),
+	}
+	rotateScript := "mysql_root_password_rotate.sh"
+	failOn := func(pod string, substr string) func(string, string) (string, error) {
+		return func(p string, cmd string) (string, error) {
+			if (pod == "" || p == pod) && strings.HasSuffix(cmd, substr) {
+				return "", errors.New("command terminated with exit code 1")
+			}
+			return "", nil
+		}
+	}
+
+	tests := []struct {
+		name     string
+		pods     []corev1.Pod
+		reply    func(string, string) (string, error)
+		result   time.Duration
+		rotated  bool
+		message  string
+		rotators []string
+		verified []string
+	}{
+		{
+			name:   "No ready pod",
+			pods:   pods[2:],
+			result: 10 * time.Second,
+		},
+		{
+			name:     "Pod restarted with the new password",
+			pods:     pods,
+			reply:    failOn("openstack-galera-0", rotateScript),
+			rotated:  true,
+			message:  mariadbv1.GaleraRootPasswordReadyMessage,
+			rotators: []string{"openstack-galera-0", "openstack-galera-1"},
+			verified: []string{"openstack-galera-0", "openstack-galera-1"},
+		},
+		{
+			name:     "Rotation script fails",
+			pods:     pods,
+			reply:    failOn("", rotateScript),
+			result:   10 * time.Second,
+			message:  "Root password rotation in progress on pod openstack-galera-1",
+			rotators: []string{"openstack-galera-0", "openstack-galera-1"},
+		},
+		{
+			name:     "New password not usable yet",
+			pods:     pods,
+			reply:    failOn("openstack-galera-1", "SELECT 1;"),
+			result:   10 * time.Second,
+			message:  "Verifying the new root password from pod openstack-galera-1",
+			rotators: []string{"openstack-galera-0"},
+			verified: []string{"openstack-galera-0", "openstack-galera-1"},
+		},
+		{
+			name:     "Rotated",
+			pods:     pods,
+			rotated:  true,
+			message:  mariadbv1.GaleraRootPasswordReadyMessage,
+			rotators: []string{"openstack-galera-0"},
+			verified: []string{"openstack-galera-0", "openstack-galera-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			instance := galera.DeepCopy()
+			instance.Status.Hash = map[string]string{mariadbv1.DbRootPasswordHash: "old"}
+			cl := condition.CreateList(condition.UnknownCondition(mariadbv1.GaleraRootPasswordReadyCondition, condition.InitReason, mariadbv1.GaleraRootPasswordReadyInitMessage))
+			instance.Status.Conditions.Init(&cl)
+			c := newFakeClient(instance)
+			r := newTestGaleraReconciler(c)
+			exec := stubExec(t, tt.reply)
+
+			result, err := r.rotateRootPassword(context.Background(), newTestHelper(t, c, instance), instance, tt.pods, "new")
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.RequeueAfter).To(Equal(tt.result))
+			g.Expect(result.Requeue).To(Equal(tt.rotated))
+			if tt.rotated {
+				g.Expect(instance.Status.Hash).To(HaveKeyWithValue(mariadbv1.DbRootPasswordHash, "new"))
+				g.Expect(instance.Status.Conditions.IsTrue(mariadbv1.GaleraRootPasswordReadyCondition)).To(BeTrue())
+			} else {
+				g.Expect(instance.Status.Hash).To(HaveKeyWithValue(mariadbv1.DbRootPasswordHash, "old"))
+				g.Expect(instance.Status.Conditions.IsTrue(mariadbv1.GaleraRootPasswordReadyCondition)).To(BeFalse())
+			}
+			if tt.message != "" {
+				g.Expect(instance.Status.Conditions.Get(mariadbv1.GaleraRootPasswordReadyCondition).Message).To(Equal(tt.message))
+			}
+
+			// the password is only changed from one node, and replicated to the others
+			for _, pod := range []string{"openstack-galera-0", "openstack-galera-1"} {
+				if slices.Contains(tt.rotators, pod) {
+					g.Expect(exec.ran(pod, rotateScript)).To(HaveLen(1))
+				} else {
+					g.Expect(exec.ran(pod, rotateScript)).To(BeEmpty())
+				}
+			}
+			g.Expect(exec.ran("openstack-galera-2", "")).To(BeEmpty())
+			for _, pod := range tt.verified {
+				g.Expect(exec.ran(pod, "SELECT 1;")).To(HaveLen(1))
+			}
+		})
+	}
+}
+
+func TestServiceModeSwitch(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera := newTestGalera("openstack", 3)
+	c := newFakeClient(galera, newTestSecret())
+	r := newTestGaleraReconciler(c)
+	stubExec(t, nil)
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "openstack", Namespace: testNamespace}}
+	// the selector of the service matches the pods of the statefulset
+	matches := func(svc *corev1.Service) []string {
+		ret := []string{}
+		for i := 0; i < 3; i++ {
+			pod := newTestGaleraPod(galera, i, true)
+			pod.Labels[mariadb.ActivePodSelectorKey] = pod.Name
+			if labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+				ret = append(ret, pod.Name)
+			}
+		}
+		return ret
+	}
+
+	_, err := reconcileN(t, r, galera, 4)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(matches(get(t, c, svc))).To(Equal([]string{"openstack-galera-0"}))
+
+	// in ActivePassive mode, the endpoint chosen by the galera
+	// nodes is kept when the service is reconciled
+	svc = get(t, c, svc)
+	svc.Spec.Selector[mariadb.ActivePodSelectorKey] = "openstack-galera-1"
+	g.Expect(c.Update(ctx, svc)).To(Succeed())
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(matches(get(t, c, svc))).To(Equal([]string{"openstack-galera-1"}))
+
+	// in ActiveActive mode, the traffic is balanced across all the pods
+	galera = get(t, c, galera)
+	galera.Spec.ServiceMode = mariadbv1.GaleraServiceModeActiveActive
+	g.Expect(c.Update(ctx, galera)).To(Succeed())
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	svc = get(t, c, svc)
+	g.Expect(svc.Spec.Selector).ToNot(HaveKey(mariadb.ActivePodSelectorKey))
+	g.Expect(matches(svc)).To(Equal([]string{"openstack-galera-0", "openstack-galera-1", "openstack-galera-2"}))
+
+	// and back to a single endpoint
+	galera = get(t, c, galera)
+	galera.Spec.ServiceMode = mariadbv1.GaleraServiceModeActivePassive
+	g.Expect(c.Update(ctx, galera)).To(Succeed())
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(matches(get(t, c, svc))).To(Equal([]string{"openstack-galera-0"}))
+}
+
+func TestGaleraPodChanged(t *testing.T) {
+	galera := newTestGalera("openstack", 3)
+	pod := newTestGaleraPod(galera, 0, true)
+	pod.Status.PodIP = "10.0.0.1"
+
+	tests := []struct {
+		name     string
+		update   func(pod *corev1.Pod)
+		expected bool
+	}{
+		{
+			name:     "Unrelated change",
+			update:   func(pod *corev1.Pod) { pod.Labels["foo"] = "bar" },
+			expected: false,
+		},
+		{
+			name:     "Pod no longer ready",
+			update:   func(pod *corev1.Pod) { pod.Status.Conditions[0].Status = corev1.ConditionFalse },
+			expected: true,
+		},
+		{
+			name:     "Pod IP changed",
+			update:   func(pod *corev1.Pod) { pod.Status.PodIP = "10.0.0.2" },
+			expected: true,
+		},
+		{
+			name: "Maintenance requested",
+			update: func(pod *corev1.Pod) {
+				pod.Annotations = map[string]string{mariadbv1.GaleraMaintenanceAnnotation: ""}
+			},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			after := pod.DeepCopy()
+			tt.update(after)
+			g.Expect(galeraPodChanged(pod, after)).To(Equal(tt.expected))
+			g.Expect(galeraPodChanged(after, pod)).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestReconcileReadEndpoints(t *testing.T) {
+	galera := newTestGalera("openstack", 3)
+
+	tests := []struct {
+		name      string
+		ready     []bool
+		activePod string
+		expected  []string
+	}{
+		{
+			name:      "Passive nodes",
+			ready:     []bool{true, true, true},
+			activePod: "openstack-galera-0",
+			expected:  []string{"openstack-galera-1", "openstack-galera-2"},
+		},
+		{
+			name:      "Passive node not ready",
+			ready:     []bool{true, false, true},
+			activePod: "openstack-galera-0",
+			expected:  []string{"openstack-galera-2"},
+		},
+		{
+			name:      "Only the active node is ready",
+			ready:     []bool{false, true, false},
+			activePod: "openstack-galera-1",
+			expected:  []string{"openstack-galera-1"},
+		},
+		{
+			name:      "No ready node",
+			ready:     []bool{false, false, false},
+			activePod: "openstack-galera-0",
+			expected:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			c := newFakeClient(galera)
+			r := newTestGaleraReconciler(c)
+			pods := []corev1.Pod{}
+			for i, ready := range tt.ready {
+				pod := newTestGaleraPod(galera, i, ready)
+				pod.Status.PodIP = fmt.Sprintf("10.0.0.%d", i+1)
+				pods = append(pods, *pod)
+			}
+
+			g.Expect(r.reconcileReadEndpoints(context.Background(), galera, tt.activePod, pods)).To(Succeed())
+			ep := &corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "openstack-read", Namespace: testNamespace}}
+			ep = get(t, c, ep)
+			endpoints := []string{}
+			for _, subset := range ep.Subsets {
+				for _, address := range subset.Addresses {
+					endpoints = append(endpoints, address.TargetRef.Name)
+				}
+			}
+			g.Expect(endpoints).To(Equal(tt.expected))
+		})
+	}
+
+	// the endpoints follow the pods on the next reconcile
+	g := NewWithT(t)
+	c := newFakeClient(galera)
+	r := newTestGaleraReconciler(c)
+	pods := []corev1.Pod{*newTestGaleraPod(galera, 0, true), *newTestGaleraPod(galera, 1, true)}
+	pods[0].Status.PodIP = "10.0.0.1"
+	pods[1].Status.PodIP = "10.0.0.2"
+	g.Expect(r.reconcileReadEndpoints(context.Background(), galera, "openstack-galera-0", pods)).To(Succeed())
+	pods[1].Status.PodIP = "10.0.0.3"
+	g.Expect(r.reconcileReadEndpoints(context.Background(), galera, "openstack-galera-0", pods)).To(Succeed())
+	ep := get(t, c, &corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "openstack-read", Namespace: testNamespace}})
+	g.Expect(ep.Subsets[0].Addresses).To(HaveLen(1))
+	g.Expect(ep.Subsets[0].Addresses[0].IP).To(Equal("10.0.0.3"))
+}
+
+// galeraAttributes returns the attributes of the pods of a galera CR, indexed by ordinal
+func galeraAttributes(seqnos map[int]string) map[string]mariadbv1.GaleraAttributes {
+	attributes := map[string]mariadbv1.GaleraAttributes{}
+	for i, seqno := range seqnos {
+		attributes[fmt.Sprintf("openstack-galera-%d", i)] = mariadbv1.GaleraAttributes{Seqno: seqno}
+	}
+	return attributes
+}
+
+func TestFindBestCandidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes map[string]mariadbv1.GaleraAttributes
+		safe       int
+		quorum     int
+		expected   string
+	}{
+		{
+			name:       "5 pods, all inspected",
+			attributes: galeraAttributes(map[int]string{0: "10", 1: "12", 2: "11", 3: "12", 4: "9"}),
+			safe:       -1,
+			quorum:     5,
+			expected:   "openstack-galera-3",
+		},
+		{
+			name:       "5 pods, too few inspected",
+			attributes: galeraAttributes(map[int]string{0: "10", 1: "12", 2: "11", 3: "12"}),
+			safe:       -1,
+			quorum:     5,
+			expected:   "",
+		},
+		{
+			name:       "5 pods, safe to bootstrap",
+			attributes: galeraAttributes(map[int]string{0: "10", 1: "12", 2: "11"}),
+			safe:       2,
+			quorum:     5,
+			expected:   "openstack-galera-2",
+		},
+		{
+			name:       "7 pods, quorum of the bootstrap policy inspected",
+			attributes: galeraAttributes(map[int]string{0: "10", 2: "-1", 4: "15", 6: "14"}),
+			safe:       -1,
+			quorum:     4,
+			expected:   "openstack-galera-4",
+		},
+		{
+			name:       "7 pods, quorum of the bootstrap policy not inspected",
+			attributes: galeraAttributes(map[int]string{0: "10", 4: "15", 6: "14"}),
+			safe:       -1,
+			quorum:     4,
+			expected:   "",
+		},
+		{
+			name:       "7 pods, all inspected",
+			attributes: galeraAttributes(map[int]string{0: "10", 1: "16", 2: "-1", 3: "16", 4: "15", 5: "16", 6: "14"}),
+			safe:       -1,
+			quorum:     7,
+			expected:   "openstack-galera-5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			instance := newTestGalera("openstack", 7)
+			instance.Status.Attributes = tt.attributes
+			if tt.safe >= 0 {
+				name := fmt.Sprintf("openstack-galera-%d", tt.safe)
+				attr := instance.Status.Attributes[name]
+				attr.SafeToBootstrap = true
+				instance.Status.Attributes[name] = attr
+			}
+
+			node, found := findBestCandidate(instance, tt.quorum)
+			g.Expect(node).To(Equal(tt.expected))
+			g.Expect(found).To(Equal(tt.expected != ""))
+		})
+	}
+}
+
+func TestBuildGcommURI(t *testing.T) {
+	g := NewWithT(t)
+
+	instance := newTestGalera("openstack", 5)
+	g.Expect(buildGcommURI(instance)).To(Equal("gcomm://" +
+		"openstack-galera-0.openstack-galera.openstack.svc," +
+		"openstack-galera-1.openstack-galera.openstack.svc," +
+		"openstack-galera-2.openstack-galera.openstack.svc," +
+		"openstack-galera-3.openstack-galera.openstack.svc," +
+		"openstack-galera-4.openstack-galera.openstack.svc"))
+
+	instance = newTestGalera("openstack", 7)
+	uri := buildGcommURI(instance)
+	g.Expect(strings.Split(strings.TrimPrefix(uri, "gcomm://"), ",")).To(HaveLen(7))
+	g.Expect(uri).To(HaveSuffix(",openstack-galera-6.openstack-galera.openstack.svc"))
+
+	// the arbitrator is a member of the cluster too
+	instance = newTestGalera("openstack", 2)
+	instance.Spec.Arbitrator = &mariadbv1.GaleraArbitrator{}
+	g.Expect(buildGcommURI(instance)).To(Equal("gcomm://" +
+		"openstack-galera-0.openstack-galera.openstack.svc," +
+		"openstack-galera-1.openstack-galera.openstack.svc," +
+		mariadb.ArbitratorHostname(instance)))
+}
+
+func TestScaleDownGradually(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     int32
+		status   int32
+		target   int32
+		expected int32
+	}{
+		{name: "Scale up", spec: 5, status: 5, target: 7, expected: 7},
+		{name: "Scale down by one", spec: 7, status: 7, target: 6, expected: 6},
+		{name: "7 to 3, first pod", spec: 7, status: 7, target: 3, expected: 6},
+		{name: "7 to 3, first pod not stopped yet", spec: 6, status: 7, target: 3, expected: 6},
+		{name: "7 to 3, second pod", spec: 6, status: 6, target: 3, expected: 5},
+		{name: "7 to 3, last pod", spec: 4, status: 4, target: 3, expected: 3},
+		{name: "5 to 3, first pod", spec: 5, status: 5, target: 3, expected: 4},
+		{name: "5 to 1, third pod", spec: 3, status: 3, target: 1, expected: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			current := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "openstack-galera", Namespace: testNamespace},
+				Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To(tt.spec)},
+				Status:     appsv1.StatefulSetStatus{Replicas: tt.status},
+			}
+			r := newTestGaleraReconciler(newFakeClient(current))
+			sts := &appsv1.StatefulSet{
+				ObjectMeta: current.ObjectMeta,
+				Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To(tt.target)},
+			}
+
+			g.Expect(r.scaleDownGradually(context.Background(), sts)).To(Succeed())
+			g.Expect(*sts.Spec.Replicas).To(Equal(tt.expected))
+		})
+	}
+
+	// a new statefulset is created with all its replicas
+	g := NewWithT(t)
+	r := newTestGaleraReconciler(newFakeClient())
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "openstack-galera", Namespace: testNamespace},
+		Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To[int32](7)},
+	}
+	g.Expect(r.scaleDownGradually(context.Background(), sts)).To(Succeed())
+	g.Expect(*sts.Spec.Replicas).To(BeEquivalentTo(7))
+}
+
+func TestClearOldPodsAttributesOnScaleDown(t *testing.T) {
+	g := NewWithT(t)
+
+	instance := newTestGalera("openstack", 5)
+	instance.Status.Attributes = galeraAttributes(map[int]string{0: "1", 1: "1", 2: "1", 3: "1", 4: "1", 5: "1", 6: "1"})
+	instance.Status.SafeToBootstrap = "openstack-galera-6"
+
+	clearOldPodsAttributesOnScaleDown(context.Background(), instance)
+	g.Expect(instance.Status.Attributes).To(HaveLen(5))
+	g.Expect(instance.Status.Attributes).ToNot(HaveKey("openstack-galera-5"))
+	g.Expect(instance.Status.Attributes).ToNot(HaveKey("openstack-galera-6"))
+	g.Expect(instance.Status.SafeToBootstrap).To(BeEmpty())
+
+	// the pods that remain are left untouched
+	instance.Status.SafeToBootstrap = "openstack-galera-4"
+	clearOldPodsAttributesOnScaleDown(context.Background(), instance)
+	g.Expect(instance.Status.Attributes).To(HaveLen(5))
+	g.Expect(instance.Status.SafeToBootstrap).To(Equal("openstack-galera-4"))
+}
+
+func TestStatefulSetZoneSpread(t *testing.T) {
+	newGalera := func(replicas int32, zoneSpread bool) *mariadbv1.Galera {
+		g := newTestGalera("openstack", replicas)
+		g.Spec.ZoneSpread = zoneSpread
+		return g
+	}
+	for _, zoneSpread := range []bool{false, true} {
+		sts, err := mariadb.StatefulSet(newGalera(3, zoneSpread), "hash")
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := sts.Spec.Template
+		for _, replicas := range []int32{1, 3, 5, 7} {
+			t.Run(fmt.Sprintf("zoneSpread %t, %d replicas", zoneSpread, replicas), func(t *testing.T) {
+				g := NewWithT(t)
+				sts, err := mariadb.StatefulSet(newGalera(replicas, zoneSpread), "hash")
+				g.Expect(err).ToNot(HaveOccurred())
+				constraints := sts.Spec.Template.Spec.TopologySpreadConstraints
+				if !zoneSpread {
+					// the pod template of the existing clusters is unchanged,
+					// so upgrading the operator does not restart their pods
+					g.Expect(constraints).To(BeNil())
+				} else {
+					g.Expect(constraints).To(HaveLen(1))
+					g.Expect(constraints[0].TopologyKey).To(Equal(corev1.LabelTopologyZone))
+					g.Expect(constraints[0].WhenUnsatisfiable).To(Equal(corev1.ScheduleAnyway))
+					g.Expect(constraints[0].LabelSelector.MatchLabels).To(Equal(mariadb.StatefulSetLabels(newGalera(replicas, zoneSpread))))
+				}
+				// scaling the cluster does not restart the existing pods
+				g.Expect(sts.Spec.Template).To(Equal(template))
+			})
+		}
+	}
+}
+
+func TestInvalidOverride(t *testing.T) {
+	g := NewWithT(t)
+
+	galera := newTestGalera("openstack", 3)
+	galera.Spec.Override.Spec = &mariadbv1.GaleraPodSpecOverride{
+		Containers: []corev1.Container{{Name: "sidecar"}},
+	}
+	c := newFakeClient(galera, newTestSecret())
+	r := newTestGaleraReconciler(c)
+	stubExec(t, nil)
+
+	// an override that can't be applied is reported in the conditions,
+	// and is not retried until the spec changes
+	result, err := reconcileN(t, r, galera, 4)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	galera = get(t, c, galera)
+	cond := galera.Status.Conditions.Get(condition.DeploymentReadyCondition)
+	g.Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(cond.Reason).To(Equal(condition.Reason(condition.ErrorReason)))
+	g.Expect(cond.Message).To(ContainSubstring("container sidecar of the pod spec override is not a galera container"))
+	err = c.Get(context.Background(), types.NamespacedName{Name: "openstack-galera", Namespace: testNamespace}, &appsv1.StatefulSet{})
+	g.Expect(k8s_errors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestArbitrator(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera := newTestGalera("openstack", 2)
+	galera.Spec.Arbitrator = &mariadbv1.GaleraArbitrator{
+		NodeSelector: &map[string]string{"topology.kubernetes.io/zone": "zone-c"},
+	}
+	c := newFakeClient(galera, newTestSecret())
+	r := newTestGaleraReconciler(c)
+	stubExec(t, nil)
+
+	_, err := reconcileN(t, r, galera, 4)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// the arbitrator joins the galera nodes, and runs on its own failure domain
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "openstack-arbitrator", Namespace: testNamespace}}
+	deployment = get(t, c, deployment)
+	g.Expect(*deployment.Spec.Replicas).To(BeEquivalentTo(1))
+	g.Expect(deployment.OwnerReferences).To(HaveLen(1))
+	g.Expect(deployment.OwnerReferences[0].Name).To(Equal("openstack"))
+	spec := deployment.Spec.Template.Spec
+	g.Expect(spec.NodeSelector).To(Equal(map[string]string{"topology.kubernetes.io/zone": "zone-c"}))
+	g.Expect(spec.Hostname).To(Equal("openstack-arbitrator"))
+	g.Expect(spec.Subdomain).To(Equal("openstack-galera"))
+	g.Expect(spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+		Name: "GCOMM_URI",
+		Value: "gcomm://openstack-galera-0.openstack-galera.openstack.svc," +
+			"openstack-galera-1.openstack-galera.openstack.svc",
+	}))
+	g.Expect(spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "GCOMM_TLS", Value: "false"}))
+	g.Expect(spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "CLUSTER_NAME", Value: "galera_cluster"}))
+	// it is not selected by the statefulset nor the database service
+	sts := get(t, c, &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "openstack-galera", Namespace: testNamespace}})
+	selector, err := metav1.LabelSelectorAsSelector(sts.Spec.Selector)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(selector.Matches(labels.Set(deployment.Spec.Template.Labels))).To(BeFalse())
+
+	// the arbitrator is stopped along with the galera pods
+	galera = get(t, c, galera)
+	galera.Status.StopRequired = true
+	g.Expect(c.Status().Update(ctx, galera)).To(Succeed())
+	g.Expect(r.reconcileArbitrator(ctx, galera, "hash")).To(Succeed())
+	g.Expect(*get(t, c, deployment).Spec.Replicas).To(BeZero())
+
+	// and it is deleted when removed from the spec
+	galera = get(t, c, galera)
+	galera.Spec.Arbitrator = nil
+	g.Expect(r.reconcileArbitrator(ctx, galera, "hash")).To(Succeed())
+	err = c.Get(ctx, client.ObjectKeyFromObject(deployment), deployment)
+	g.Expect(k8s_errors.IsNotFound(err)).To(BeTrue())
+	g.Expect(r.reconcileArbitrator(ctx, galera, "hash")).To(Succeed())
+}
+
+// waitingPodReply answers the commands run in galera pods that wait for a gcomm
+// URI, with the seqno found on their disk. Pods without a seqno can't be inspected
+func waitingPodReply(seqnos map[string]string) func(string, string) (string, error) {
+	return func(pod string, cmd string) (string, error) {
+		seqno, found := seqnos[pod]
+		if !found {
+			return "", errors.New("container not found")
+		}
+		switch {
+		case strings.Contains(cmd, "detect_gcomm_and_start.sh"):
+			return "detect_gcomm_and_start.sh\n", nil
+		case strings.Contains(cmd, "detect_last_commit.sh"):
+			return fmt.Sprintf(`{"uuid":"3a0a9e5c-0000-11ef-0000-000000000000","seqno":"%s"}`, seqno), nil
+		}
+		return "", nil
+	}
+}
+
+func TestArbitratorClusterName(t *testing.T) {
+	g := NewWithT(t)
+
+	// the arbitrator joins the cluster under the name set in the custom config
+	galera := newTestGalera("openstack", 2)
+	galera.Spec.Arbitrator = &mariadbv1.GaleraArbitrator{}
+	galera.Spec.CustomServiceConfig = "[mysqld]\nwsrep_cluster_name = \"openstack\"\n"
+	deployment := mariadb.ArbitratorDeployment(galera, "gcomm://", "hash")
+	g.Expect(deployment.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "CLUSTER_NAME", Value: "openstack"}))
+
+	// options of the sections not read by the server are ignored
+	galera.Spec.CustomServiceConfig = "[client]\nwsrep_cluster_name = other\n"
+	deployment = mariadb.ArbitratorDeployment(galera, "gcomm://", "hash")
+	g.Expect(deployment.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "CLUSTER_NAME", Value: "galera_cluster"}))
+}
+
+func TestForceBootstrap(t *testing.T) {
+	tests := []struct {
+		name         string
+		pod          string
+		bootstrapped bool
+		waiting      bool
+		forced       bool
+		annotated    bool
+		event        string
+	}{
+		{
+			name:    "Pod waiting for a gcomm URI",
+			pod:     "openstack-galera-1",
+			waiting: true,
+			forced:  true,
+			event:   "Warning ForcedBootstrap Galera cluster bootstrap forced from pod openstack-galera-1 by kubectl-annotate",
+		},
+		{
+			name:      "Pod not started yet",
+			pod:       "openstack-galera-1",
+			annotated: true,
+		},
+		{
+			name:    "Not a galera pod",
+			pod:     "openstack-galera-3",
+			waiting: true,
+			event:   "Warning ForceBootstrapIgnored Bootstrap from pod openstack-galera-3 not forced, the pod is not a galera node",
+		},
+		{
+			name:         "Cluster already running",
+			pod:          "openstack-galera-1",
+			bootstrapped: true,
+			waiting:      true,
+			event:        "Warning ForceBootstrapIgnored Bootstrap from pod openstack-galera-1 not forced, the galera cluster is already running",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			galera := newTestGalera("openstack", 3)
+			galera.Annotations = map[string]string{mariadbv1.GaleraForceBootstrapAnnotation: tt.pod}
+			galera.ManagedFields = []metav1.ManagedFieldsEntry{{
+				Manager: "kubectl-annotate",
+				FieldsV1: &metav1.FieldsV1{
+					Raw: []byte(`{"f:metadata":{"f:annotations":{"f:` + mariadbv1.GaleraForceBootstrapAnnotation + `":{}}}}`),
+				},
+			}}
+			galera.Status.Bootstrapped = tt.bootstrapped
+			galera.Status.Attributes = map[string]mariadbv1.GaleraAttributes{
+				"openstack-galera-0": {Seqno: "-1"},
+			}
+			pods := []corev1.Pod{}
+			for i := 0; i < 3; i++ {
+				pods = append(pods, *newTestGaleraPod(galera, i, false))
+			}
+			c := newFakeClient(galera)
+			r := newTestGaleraReconciler(c)
+			seqnos := map[string]string{}
+			if tt.waiting {
+				seqnos = map[string]string{"openstack-galera-1": "-1", "openstack-galera-3": "-1"}
+			}
+			exec := stubExec(t, waitingPodReply(seqnos))
+
+			err := r.forceBootstrap(context.Background(), newTestHelper(t, c, galera), galera, pods, tt.pod)
+			g.Expect(err).ToNot(HaveOccurred())
+			if tt.annotated {
+				g.Expect(galera.Annotations).To(HaveKey(mariadbv1.GaleraForceBootstrapAnnotation))
+			} else {
+				g.Expect(galera.Annotations).ToNot(HaveKey(mariadbv1.GaleraForceBootstrapAnnotation))
+			}
+			if tt.forced {
+				g.Expect(exec.ran(tt.pod, "echo 'gcomm://' > /var/lib/mysql/gcomm_uri")).To(HaveLen(1))
+				g.Expect(galera.Status.Attributes[tt.pod].Gcomm).To(Equal("gcomm://"))
+				g.Expect(galera.Status.ForcedBootstrap).ToNot(BeNil())
+				g.Expect(galera.Status.ForcedBootstrap.Pod).To(Equal(tt.pod))
+				g.Expect(galera.Status.ForcedBootstrap.RequestedBy).To(Equal("kubectl-annotate"))
+				g.Expect(galera.Status.ForcedBootstrap.Time.Time).To(BeTemporally("~", time.Now(), time.Minute))
+			} else {
+				g.Expect(exec.ran(tt.pod, "gcomm_uri")).ToNot(ContainElement(ContainSubstring("echo")))
+				g.Expect(galera.Status.ForcedBootstrap).To(BeNil())
+			}
+			if tt.event != "" {
+				g.Expect(recordedEvents(r.Recorder)).To(ConsistOf(HavePrefix(tt.event)))
+			} else {
+				g.Expect(recordedEvents(r.Recorder)).To(BeEmpty())
+			}
+		})
+	}
+}
+
+func TestBootstrapPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    *mariadbv1.GaleraBootstrapPolicy
+		bootstrap bool
+	}{
+		{
+			name:   "Default policy",
+			policy: nil,
+		},
+		{
+			name:   "AllNodes",
+			policy: &mariadbv1.GaleraBootstrapPolicy{Mode: mariadbv1.GaleraBootstrapModeAllNodes, Timeout: metav1.Duration{Duration: time.Minute}},
+		},
+		{
+			name:      "Majority",
+			policy:    &mariadbv1.GaleraBootstrapPolicy{Mode: mariadbv1.GaleraBootstrapModeMajority, Timeout: metav1.Duration{Duration: time.Minute}},
+			bootstrap: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+
+			galera := newTestGalera("openstack", 3)
+			galera.Spec.BootstrapPolicy = tt.policy
+			// the last pod can't be inspected, e.g. its worker node is down
+			c := newFakeClient(galera, newTestSecret(),
+				newTestGaleraPod(galera, 0, false), newTestGaleraPod(galera, 1, false))
+			r := newTestGaleraReconciler(c)
+			exec := stubExec(t, waitingPodReply(map[string]string{"openstack-galera-0": "10", "openstack-galera-1": "12"}))
+
+			// the pods are inspected, but there are not enough to bootstrap the cluster
+			_, err := reconcileN(t, r, galera, 4)
+			g.Expect(err).ToNot(HaveOccurred())
+			galera = get(t, c, galera)
+			g.Expect(galera.Status.Attributes).To(HaveLen(2))
+			g.Expect(galera.Status.InspectionStartTime).ToNot(BeNil())
+			g.Expect(exec.ran("openstack-galera-1", "echo 'gcomm://'")).To(BeEmpty())
+
+			// after the timeout, only the Majority policy bootstraps from the best candidate
+			started := metav1.NewTime(time.Now().Add(-2 * time.Minute))
+			galera.Status.InspectionStartTime = &started
+			g.Expect(c.Status().Update(ctx, galera)).To(Succeed())
+			_, err = reconcileN(t, r, galera, 1)
+			g.Expect(err).ToNot(HaveOccurred())
+			galera = get(t, c, galera)
+			if tt.bootstrap {
+				g.Expect(exec.ran("openstack-galera-1", "echo 'gcomm://'")).To(HaveLen(1))
+				g.Expect(galera.Status.Attributes["openstack-galera-1"].Gcomm).To(Equal("gcomm://"))
+				g.Expect(recordedEvents(r.Recorder)).To(ContainElement(
+					"Warning MajorityBootstrap Bootstrapping galera cluster from pod openstack-galera-1 after inspecting 2/3 pods"))
+			} else {
+				g.Expect(exec.ran("openstack-galera-1", "echo 'gcomm://'")).To(BeEmpty())
+				g.Expect(galera.Status.InspectionStartTime.Time).To(BeTemporally("~", started.Time, time.Second))
+				g.Expect(recordedEvents(r.Recorder)).ToNot(ContainElement(ContainSubstring("Bootstrapping")))
+			}
+			g.Expect(exec.ran("openstack-galera-0", "echo 'gcomm://'")).To(BeEmpty())
+		})
+	}
+}
+
+func TestNodeStatusPolling(t *testing.T) {
+	tests := []struct {
+		name   string
+		states []string
+		synced int32
+		result time.Duration
+	}{
+		{
+			name:   "All nodes Synced",
+			states: []string{"Synced", "Synced", "Synced"},
+			synced: 3,
+			result: galeraNodeStatusInterval,
+		},
+		{
+			name:   "Node joining the cluster",
+			states: []string{"Synced", "Synced", "Joined"},
+			synced: 2,
+			result: galeraNodeStatusInterval,
+		},
+		{
+			name:   "Node desynced",
+			states: []string{"Synced", "Donor/Desynced", "Synced"},
+			synced: 2,
+			result: galeraNodeStatusInterval,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			galera, objs := newBootstrappedGalera(3)
+			for i := 0; i < 3; i++ {
+				objs = append(objs, newTestDataVolumeClaim(galera, i, nil, galera.Spec.StorageRequest))
+			}
+			c := newFakeClient(objs...)
+			r := newTestGaleraReconciler(c)
+			stubExec(t, func(pod string, cmd string) (string, error) {
+				switch {
+				case strings.Contains(cmd, "wsrep_cluster_size"):
+					index := pod[len(pod)-1] - '0'
+					return fmt.Sprintf("wsrep_cluster_size\t3\nwsrep_local_state_comment\t%s\n", tt.states[index]), nil
+				case strings.Contains(cmd, "VERSION()"):
+					return "10.11.6-MariaDB\n", nil
+				}
+				return "", nil
+			})
+			_, err := reconcileN(t, r, galera, 4)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			simulateStatefulSetAvailable(t, c, galera)
+
+			result, err := reconcileN(t, r, galera, 1)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.RequeueAfter).To(Equal(tt.result))
+			galera = get(t, c, galera)
+			g.Expect(galera.Status.SyncedNodes).To(Equal(tt.synced))
+			g.Expect(galera.Status.Nodes).To(HaveLen(3))
+			g.Expect(galera.Status.Nodes["openstack-galera-2"].LocalState).To(Equal(tt.states[2]))
+
+			// the requeue refreshes the state of the nodes once it is outdated
+			tt.states[2] = "Donor/Desynced"
+			_, err = reconcileN(t, r, galera, 1)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(get(t, c, galera).Status.Nodes["openstack-galera-2"].LocalState).ToNot(Equal("Donor/Desynced"))
+			node := galera.Status.Nodes["openstack-galera-2"]
+			node.LastUpdateTime = metav1.NewTime(time.Now().Add(-galeraNodeStatusInterval))
+			galera.Status.Nodes["openstack-galera-2"] = node
+			g.Expect(c.Status().Update(context.Background(), galera)).To(Succeed())
+			_, err = reconcileN(t, r, galera, 1)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(get(t, c, galera).Status.Nodes["openstack-galera-2"].LocalState).To(Equal("Donor/Desynced"))
+		})
+	}
+}
+
+func TestGaleraLifecycleEvents(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera, objs := newStoppedGalera("openstack")
+	c := newFakeClient(objs...)
+	r := newTestGaleraReconciler(c)
+	waiting := waitingPodReply(map[string]string{
+		"openstack-galera-0": "10", "openstack-galera-1": "12", "openstack-galera-2": "11",
+	})
+	stubExec(t, waiting)
+
+	// the bootstrap node is picked once the seqno of all the pods is known
+	_, err := reconcileN(t, r, galera, 4)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(recordedEvents(r.Recorder)).To(ConsistOf(
+		"Normal Bootstrapping Bootstrapping galera cluster from pod openstack-galera-1 (seqno 12)"))
+
+	// the other pods join the cluster once the bootstrap node is ready
+	pod := get(t, c, newTestGaleraPod(galera, 1, true))
+	pod.Status = newTestGaleraPod(galera, 1, true).Status
+	g.Expect(c.Status().Update(ctx, pod)).To(Succeed())
+	simulateStatefulSetAvailable(t, c, galera)
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(recordedEvents(r.Recorder)).To(ConsistOf(
+		"Normal JoiningCluster Pod openstack-galera-0 is joining the galera cluster",
+		"Normal JoiningCluster Pod openstack-galera-2 is joining the galera cluster"))
+
+	// a joiner restarted before it could start galera is probed again,
+	// and a failed gcomm injection is reported
+	pod = get(t, c, newTestGaleraPod(galera, 0, false))
+	pod.Status.ContainerStatuses[0].ContainerID = "cri-o://restarted"
+	g.Expect(c.Status().Update(ctx, pod)).To(Succeed())
+	stubExec(t, func(pod string, cmd string) (string, error) {
+		if strings.Contains(cmd, "> /var/lib/mysql/gcomm_uri") {
+			return "", errors.New("container not found")
+		}
+		return waiting(pod, cmd)
+	})
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(recordedEvents(r.Recorder)).To(ConsistOf(
+		"Warning PodRestarted Pod openstack-galera-0 restarted while galera was starting, its state will be probed again",
+		"Warning GcommInjectionFailed Failed to push gcomm URI to joiner pod openstack-galera-0: container not found"))
+
+	// a change of a cluster-wide option stops all the galera pods
+	galera = get(t, c, galera)
+	galera.Spec.CustomServiceConfig = "[mysqld]\nwsrep_cluster_name=new"
+	g.Expect(c.Update(ctx, galera)).To(Succeed())
+	stubExec(t, waiting)
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(recordedEvents(r.Recorder)).To(ContainElement(
+		"Normal ClusterStopRequired Cluster-wide configuration changed, stopping all galera pods"))
+	g.Expect(get(t, c, galera).Status.StopRequired).To(BeTrue())
+}
+
+func TestClusterConfigOptionsSeeded(t *testing.T) {
+	tests := []struct {
+		name    string
+		changed bool
+	}{
+		{name: "Options already in use"},
+		{name: "Options changed during the upgrade", changed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+
+			galera := newTestGalera("openstack", 3)
+			galera.Spec.CustomServiceConfig = "[mysqld]\nwsrep_cluster_name=openstack"
+			c := newFakeClient(galera, newTestSecret())
+			r := newTestGaleraReconciler(c)
+			stubExec(t, nil)
+			_, err := reconcileN(t, r, galera, 4)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			// the status of a cluster deployed by an operator that did not
+			// track the options of the CustomServiceConfig
+			galera = get(t, c, galera)
+			g.Expect(galera.Status.Hash).To(HaveKey(mariadbv1.ClusterConfigOptionsHash))
+			delete(galera.Status.Hash, mariadbv1.ClusterConfigOptionsHash)
+			galera.Status.Hash["ClusterProperties"], err = util.HashOfInputHashes(map[string]env.Setter{
+				"GCommTLS": env.SetValue("false"),
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			if tt.changed {
+				galera.Status.Hash["ClusterProperties"] = "outdated"
+			}
+			g.Expect(c.Status().Update(ctx, galera)).To(Succeed())
+
+			_, err = reconcileN(t, r, galera, 1)
+			g.Expect(err).ToNot(HaveOccurred())
+			stop := ContainElement("Normal ClusterStopRequired Cluster-wide configuration changed, stopping all galera pods")
+			if tt.changed {
+				g.Expect(recordedEvents(r.Recorder)).To(stop)
+			} else {
+				g.Expect(recordedEvents(r.Recorder)).ToNot(stop)
+			}
+			galera = get(t, c, galera)
+			g.Expect(galera.Status.Hash).To(HaveKey(mariadbv1.ClusterConfigOptionsHash))
+			g.Expect(galera.Status.ClusterProperties).To(HaveKeyWithValue("wsrep_cluster_name", "openstack"))
+		})
+	}
+}
+
+func TestSSTSecretVolume(t *testing.T) {
+	tests := []struct {
+		method    mariadbv1.GaleraSSTMethod
+		projected bool
+	}{
+		{method: "", projected: false},
+		{method: mariadbv1.GaleraSSTMethodRsync, projected: false},
+		{method: mariadbv1.GaleraSSTMethodMariabackup, projected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.method), func(t *testing.T) {
+			g := NewWithT(t)
+
+			galera := newTestGalera("openstack", 3)
+			galera.Spec.SSTMethod = tt.method
+			c := newFakeClient(galera, newTestSecret())
+			r := newTestGaleraReconciler(c)
+			stubExec(t, nil)
+
+			_, err := reconcileN(t, r, galera, 4)
+			g.Expect(err).ToNot(HaveOccurred())
+			sts := get(t, c, &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "openstack-galera", Namespace: testNamespace}})
+			// the SST password is only mounted when mariabackup needs it
+			var secrets corev1.Volume
+			for _, v := range sts.Spec.Template.Spec.Volumes {
+				if v.Name == "secrets" {
+					secrets = v
+				}
+			}
+			if tt.projected {
+				g.Expect(secrets.Secret).To(BeNil())
+				g.Expect(secrets.Projected.Sources).To(ConsistOf(
+					HaveField("Secret.Name", "osp-secret"),
+					HaveField("Secret.Name", "openstack-sst")))
+			} else {
+				g.Expect(secrets.Projected).To(BeNil())
+				g.Expect(secrets.Secret.SecretName).To(Equal("osp-secret"))
+			}
+			// the SST secret is only generated for mariabackup
+			err = c.Get(context.Background(), types.NamespacedName{Name: "openstack-sst", Namespace: testNamespace}, &corev1.Secret{})
+			if tt.projected {
+				g.Expect(err).ToNot(HaveOccurred())
+			} else {
+				g.Expect(k8s_errors.IsNotFound(err)).To(BeTrue())
+			}
+		})
+	}
+}
+
+func TestSSTUserCreatedBeforeJoiners(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera, objs := newStoppedGalera("openstack")
+	galera.Spec.SSTMethod = mariadbv1.GaleraSSTMethodMariabackup
+	c := newFakeClient(objs...)
+	r := newTestGaleraReconciler(c)
+	exec := stubExec(t, waitingPodReply(map[string]string{
+		"openstack-galera-0": "10", "openstack-galera-1": "12", "openstack-galera-2": "11",
+	}))
+	_, err := reconcileN(t, r, galera, 4)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// only the bootstrap node is available
+	pod := get(t, c, newTestGaleraPod(galera, 1, true))
+	pod.Status = newTestGaleraPod(galera, 1, true).Status
+	g.Expect(c.Status().Update(ctx, pod)).To(Succeed())
+	sts := get(t, c, &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "openstack-galera", Namespace: testNamespace}})
+	sts.Status.Replicas = 3
+	sts.Status.ReadyReplicas = 1
+	sts.Status.AvailableReplicas = 1
+	g.Expect(c.Status().Update(ctx, sts)).To(Succeed())
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// the donor has the SST user before the joiners request a snapshot
+	created := exec.ran("openstack-galera-1", "CREATE USER IF NOT EXISTS 'galera_sst'@'localhost'")
+	g.Expect(created).To(HaveLen(1))
+	joined := exec.ran("openstack-galera-0", "> /var/lib/mysql/gcomm_uri")
+	g.Expect(joined).To(HaveLen(1))
+	g.Expect(slices.Index(exec.commands, created[0])).To(BeNumerically("<", slices.Index(exec.commands, joined[0])))
+	g.Expect(get(t, c, galera).Status.Hash).To(HaveKey(mariadbv1.SSTUserPasswordHash))
+}
+
+func TestSSTUserPasswordEscaped(t *testing.T) {
+	g := NewWithT(t)
+
+	galera, objs := newBootstrappedGalera(2)
+	galera.Spec.SSTMethod = mariadbv1.GaleraSSTMethodMariabackup
+	galera.Status.Hash = map[string]string{}
+	sstSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "openstack-sst", Namespace: testNamespace},
+		Data:       map[string][]byte{mariadb.SSTPasswordKey: []byte(`pass'; DROP USER root; -- \`)},
+	}
+	c := newFakeClient(append(objs, sstSecret)...)
+	r := newTestGaleraReconciler(c)
+	exec := stubExec(t, nil)
+	pods := []corev1.Pod{*newTestGaleraPod(galera, 0, true), *newTestGaleraPod(galera, 1, true)}
+
+	_, err := r.reconcileSSTUser(context.Background(), newTestHelper(t, c, galera), galera, pods)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// the password can't end the SQL strings it is set in
+	created := exec.ran("openstack-galera-0", "CREATE USER IF NOT EXISTS 'galera_sst'@'localhost'")
+	g.Expect(created).To(HaveLen(1))
+	g.Expect(created[0]).To(ContainSubstring(`IDENTIFIED BY 'pass\'; DROP USER root; -- \\';`))
+	for _, pod := range []string{"openstack-galera-0", "openstack-galera-1"} {
+		g.Expect(exec.ran(pod, `SET GLOBAL wsrep_sst_auth = 'galera_sst:pass\'; DROP USER root; -- \\';`)).To(HaveLen(1))
+	}
+}
+
+func TestSSTUserDroppedWithoutMariabackup(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera, objs := newBootstrappedGalera(2)
+	galera.Spec.SSTMethod = mariadbv1.GaleraSSTMethodMariabackup
+	c := newFakeClient(objs...)
+	r := newTestGaleraReconciler(c)
+	exec := stubExec(t, galeraStatusReply("Synced"))
+	_, err := reconcileN(t, r, galera, 4)
+	g.Expect(err).ToNot(HaveOccurred())
+	simulateStatefulSetAvailable(t, c, galera)
+	_, err = reconcileN(t, r, galera, 2)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(get(t, c, galera).Status.Hash).To(HaveKey(mariadbv1.SSTUserPasswordHash))
+
+	// switching to rsync leaves no unused user with privileges behind
+	galera = get(t, c, galera)
+	galera.Spec.SSTMethod = mariadbv1.GaleraSSTMethodRsync
+	g.Expect(c.Update(ctx, galera)).To(Succeed())
+	_, err = reconcileN(t, r, galera, 2)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(exec.ran("openstack-galera-0", "DROP USER IF EXISTS 'galera_sst'@'localhost';")).To(HaveLen(1))
+	g.Expect(get(t, c, galera).Status.Hash).ToNot(HaveKey(mariadbv1.SSTUserPasswordHash))
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/kubectl/pkg/util/podutils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+)
+
+// maintenancePods returns the name of the galera pods that are requested to be in
+// maintenance, either with an annotation on the pod or on the galera CR
+func maintenancePods(instance *mariadbv1.Galera, pods []corev1.Pod) []string {
+	requested := map[string]bool{}
+	for _, name := range strings.Split(instance.Annotations[mariadbv1.GaleraMaintenanceAnnotation], ",") {
+		requested[strings.TrimSpace(name)] = true
+	}
+	ret := []string{}
+	for _, pod := range pods {
+		if _, annotated := pod.Annotations[mariadbv1.GaleraMaintenanceAnnotation]; annotated || requested[pod.Name] {
+			ret = append(ret, pod.Name)
+		}
+	}
+	return ret
+}
+
+// failoverServiceEndpoint moves the active endpoint of an active/passive service
+// away from a galera node, like the wsrep notify script does when a node leaves
+// the cluster. The new endpoint is the first ready node that is not excluded
+func (r *GaleraReconciler) failoverServiceEndpoint(ctx context.Context, h *helper.Helper, instance *mariadbv1.Galera, svc *corev1.Service, podName string, pods []corev1.Pod, excluded []string) error {
+	if instance.IsActiveActive() || svc.Spec.Selector[mariadb.ActivePodSelectorKey] != podName {
+		return nil
+	}
+	newEndpoint := ""
+	for _, pod := range getReadyPods(pods) {
+		if pod.Name != podName && !slices.Contains(excluded, pod.Name) {
+			newEndpoint = pod.Name
+			break
+		}
+	}
+	if newEndpoint == "" {
+		return fmt.Errorf("no other available node to become the active endpoint of service %s", svc.Name)
+	}
+
+	patch := client.MergeFrom(svc.DeepCopy())
+	svc.Spec.Selector[mariadb.ActivePodSelectorKey] = newEndpoint
+	err := r.Client.Patch(ctx, svc, patch)
+	if err != nil {
+		return err
+	}
+	h.GetLogger().Info("Configured a new active endpoint", "service", svc.Name, "from", podName, "to", newEndpoint)
+	return nil
+}
+
+// reconcileMaintenance puts in maintenance the galera nodes requested with
+// the maintenance annotation, and takes them out once the annotation is removed.
+// A node in maintenance is removed from the active endpoint of the service, and
+// is desynced so that it no longer takes part in flow control. The desync makes
+// the node unready, which removes it from the active/active and read services
+func (r *GaleraReconciler) reconcileMaintenance(ctx context.Context, h *helper.Helper, instance *mariadbv1.Galera, svc *corev1.Service, pods []corev1.Pod) error {
+	log := h.GetLogger()
+	requested := maintenancePods(instance, pods)
+
+	// Resync the nodes whose annotation was removed
+	current := []string{}
+	for _, name := range instance.Status.Maintenance {
+		if slices.Contains(requested, name) {
+			current = append(current, name)
+			continue
+		}
+		pod := getPodFromName(pods, name)
+		// a restarted node is not desynced anymore
+		if pod != nil && pod.Status.Phase == corev1.PodRunning {
+			err := setGaleraNodeDesync(ctx, h, r.config, instance, name, false)
+			if err != nil && !isGaleraContainerStartedAndWaiting(ctx, pod, instance, h, r.config) {
+				return err
+			}
+		}
+		log.Info("Galera node left maintenance", "pod", name)
+		r.Recorder.Eventf(instance, corev1.EventTypeNormal, "MaintenanceFinished",
+			"Pod %s left maintenance", name)
+	}
+	instance.Status.Maintenance = current
+
+	// Desync the nodes requested for maintenance. A node that is not ready is
+	// either already desynced, or not running galera yet. A node in maintenance
+	// that becomes ready again was restarted, and must be desynced again
+	for _, name := range requested {
+		pod := getPodFromName(pods, name)
+		if pod.Status.Phase != corev1.PodRunning || !podutils.IsPodReady(pod) {
+			continue
+		}
+		serving := slices.ContainsFunc(getReadyPods(pods), func(p corev1.Pod) bool {
+			return !slices.Contains(requested, p.Name)
+		})
+		err := fmt.Errorf("no other available node to serve traffic")
+		if serving {
+			err = r.failoverServiceEndpoint(ctx, h, instance, svc, name, pods, requested)
+		}
+		if err != nil {
+			log.Info("Cannot put galera node in maintenance", "pod", name, "error", err.Error())
+			r.Recorder.Eventf(instance, corev1.EventTypeWarning, "MaintenanceRefused",
+				"Pod %s cannot be put in maintenance: %s", name, err.Error())
+			continue
+		}
+		err = setGaleraNodeDesync(ctx, h, r.config, instance, name, true)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(instance.Status.Maintenance, name) {
+			instance.Status.Maintenance = append(instance.Status.Maintenance, name)
+			log.Info("Galera node entered maintenance", "pod", name)
+			r.Recorder.Eventf(instance, corev1.EventTypeNormal, "MaintenanceStarted",
+				"Pod %s entered maintenance", name)
+		}
+	}
+
+	// The notify script of a node that resyncs may have made a node
+	// in maintenance the active endpoint again
+	if active := svc.Spec.Selector[mariadb.ActivePodSelectorKey]; slices.Contains(instance.Status.Maintenance, active) {
+		err := r.failoverServiceEndpoint(ctx, h, instance, svc, active, pods, instance.Status.Maintenance)
+		if err != nil {
+			log.Info("Cannot move the active endpoint away from a node in maintenance", "pod", active, "error", err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// activeEndpoint returns the galera pod selected by the database service
+func activeEndpoint(t *testing.T, c client.Client) string {
+	svc := get(t, c, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "openstack", Namespace: testNamespace}})
+	return svc.Spec.Selector[mariadb.ActivePodSelectorKey]
+}
+
+// setPodReady changes the readiness of a galera pod, as its probe would
+func setPodReady(t *testing.T, c client.Client, g *mariadbv1.Galera, index int, ready bool) {
+	pod := get(t, c, newTestGaleraPod(g, index, ready))
+	pod.Status = newTestGaleraPod(g, index, ready).Status
+	if err := c.Status().Update(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// syncedNodeReply answers the wsrep status queries of the controller with
+// a Synced node of a 3-node cluster
+func syncedNodeReply(_ string, cmd string) (string, error) {
+	switch {
+	case strings.Contains(cmd, "wsrep_cluster_size"):
+		return "wsrep_cluster_size\t3\nwsrep_local_state_comment\tSynced\n", nil
+	case strings.Contains(cmd, "VERSION()"):
+		return "10.11.6-MariaDB\n", nil
+	}
+	return "", nil
+}
+
+func TestMaintenance(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera, objs := newBootstrappedGalera(3)
+	c := newFakeClient(objs...)
+	r := newTestGaleraReconciler(c)
+	exec := stubExec(t, syncedNodeReply)
+
+	_, err := reconcileN(t, r, galera, 4)
+	g.Expect(err).ToNot(HaveOccurred())
+	simulateStatefulSetAvailable(t, c, galera)
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(activeEndpoint(t, c)).To(Equal("openstack-galera-0"))
+	g.Expect(get(t, c, galera).Status.Nodes["openstack-galera-0"].State).To(Equal(mariadbv1.GaleraNodeStateRunning))
+	recordedEvents(r.Recorder)
+
+	// the active endpoint moves away from the annotated pod before it is desynced
+	pod := get(t, c, newTestGaleraPod(galera, 0, true))
+	pod.Annotations = map[string]string{mariadbv1.GaleraMaintenanceAnnotation: ""}
+	g.Expect(c.Update(ctx, pod)).To(Succeed())
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(activeEndpoint(t, c)).To(Equal("openstack-galera-1"))
+	g.Expect(exec.ran("openstack-galera-0", "SET GLOBAL wsrep_desync=ON;")).To(HaveLen(1))
+	galera = get(t, c, galera)
+	g.Expect(galera.Status.Maintenance).To(Equal([]string{"openstack-galera-0"}))
+	g.Expect(galera.Status.Nodes["openstack-galera-0"].State).To(Equal(mariadbv1.GaleraNodeStateMaintenance))
+	g.Expect(galera.Status.Nodes["openstack-galera-1"].State).To(Equal(mariadbv1.GaleraNodeStateRunning))
+	g.Expect(recordedEvents(r.Recorder)).To(ConsistOf("Normal MaintenanceStarted Pod openstack-galera-0 entered maintenance"))
+
+	// the desynced node is not ready anymore, and stays in maintenance
+	setPodReady(t, c, galera, 0, false)
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(exec.ran("openstack-galera-0", "wsrep_desync")).To(HaveLen(1))
+	galera = get(t, c, galera)
+	g.Expect(galera.Status.Maintenance).To(Equal([]string{"openstack-galera-0"}))
+	g.Expect(galera.Status.Nodes["openstack-galera-0"].State).To(Equal(mariadbv1.GaleraNodeStateMaintenance))
+	g.Expect(activeEndpoint(t, c)).To(Equal("openstack-galera-1"))
+
+	// removing the annotation resyncs the node
+	pod = get(t, c, pod)
+	pod.Annotations = nil
+	g.Expect(c.Update(ctx, pod)).To(Succeed())
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(exec.ran("openstack-galera-0", "SET GLOBAL wsrep_desync=OFF;")).To(HaveLen(1))
+	galera = get(t, c, galera)
+	g.Expect(galera.Status.Maintenance).To(BeEmpty())
+	g.Expect(recordedEvents(r.Recorder)).To(ConsistOf("Normal MaintenanceFinished Pod openstack-galera-0 left maintenance"))
+
+	setPodReady(t, c, galera, 0, true)
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	galera = get(t, c, galera)
+	g.Expect(galera.Status.Nodes["openstack-galera-0"].State).To(Equal(mariadbv1.GaleraNodeStateRunning))
+	g.Expect(exec.ran("openstack-galera-0", "wsrep_desync")).To(HaveLen(2))
+}
+
+func TestMaintenanceFromGaleraAnnotation(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera, objs := newBootstrappedGalera(3)
+	c := newFakeClient(objs...)
+	r := newTestGaleraReconciler(c)
+	exec := stubExec(t, syncedNodeReply)
+	_, err := reconcileN(t, r, galera, 4)
+	g.Expect(err).ToNot(HaveOccurred())
+	simulateStatefulSetAvailable(t, c, galera)
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	recordedEvents(r.Recorder)
+
+	// the active endpoint moves to the first node that is not in maintenance
+	galera = get(t, c, galera)
+	galera.Annotations = map[string]string{mariadbv1.GaleraMaintenanceAnnotation: "openstack-galera-0, openstack-galera-1"}
+	g.Expect(c.Update(ctx, galera)).To(Succeed())
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(activeEndpoint(t, c)).To(Equal("openstack-galera-2"))
+	g.Expect(exec.ran("openstack-galera-0", "SET GLOBAL wsrep_desync=ON;")).To(HaveLen(1))
+	g.Expect(exec.ran("openstack-galera-1", "SET GLOBAL wsrep_desync=ON;")).To(HaveLen(1))
+	g.Expect(exec.ran("openstack-galera-2", "wsrep_desync")).To(BeEmpty())
+	g.Expect(get(t, c, galera).Status.Maintenance).To(ConsistOf("openstack-galera-0", "openstack-galera-1"))
+
+	// the last node serving traffic is never put in maintenance
+	galera = get(t, c, galera)
+	galera.Annotations[mariadbv1.GaleraMaintenanceAnnotation] = "openstack-galera-0,openstack-galera-1,openstack-galera-2"
+	g.Expect(c.Update(ctx, galera)).To(Succeed())
+	recordedEvents(r.Recorder)
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(activeEndpoint(t, c)).To(Equal("openstack-galera-2"))
+	g.Expect(exec.ran("openstack-galera-2", "wsrep_desync")).To(BeEmpty())
+	g.Expect(recordedEvents(r.Recorder)).To(ContainElement(
+		"Warning MaintenanceRefused Pod openstack-galera-2 cannot be put in maintenance: no other available node to serve traffic"))
+	galera = get(t, c, galera)
+	g.Expect(galera.Status.Maintenance).ToNot(ContainElement("openstack-galera-2"))
+	g.Expect(galera.Status.Nodes["openstack-galera-2"].State).To(Equal(mariadbv1.GaleraNodeStateRunning))
+}
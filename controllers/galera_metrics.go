@@ -0,0 +1,168 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+)
+
+// reconcileMetrics creates the secret of the monitoring user, the metrics service
+// and, when the prometheus-operator CRD is installed, the ServiceMonitor of a
+// galera CR. They are deleted when the metrics are disabled
+func (r *GaleraReconciler) reconcileMetrics(ctx context.Context, h *helper.Helper, instance *mariadbv1.Galera) error {
+	log := GetLog(ctx, "galera")
+	name := types.NamespacedName{Name: mariadb.MetricsName(instance.Name), Namespace: instance.Namespace}
+
+	// the ServiceMonitor kind is only known if prometheus-operator is installed
+	_, err := r.Client.RESTMapper().RESTMapping(mariadb.ServiceMonitorGVK.GroupKind(), mariadb.ServiceMonitorGVK.Version)
+	serviceMonitorSupported := err == nil
+	if err != nil && !meta.IsNoMatchError(err) {
+		return err
+	}
+
+	if instance.Spec.Metrics == nil {
+		objs := []client.Object{
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace}},
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace}},
+		}
+		if serviceMonitorSupported {
+			objs = append(objs, mariadb.MetricsServiceMonitor(instance))
+		}
+		for _, obj := range objs {
+			err = r.Client.Get(ctx, name, obj)
+			if k8s_errors.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			err = r.Client.Delete(ctx, obj)
+			if err != nil && !k8s_errors.IsNotFound(err) {
+				return err
+			}
+			log.Info("Metrics disabled, resource deleted", "Name", name.Name, "Type", fmt.Sprintf("%T", obj))
+		}
+		return nil
+	}
+
+	_, _, err = r.ensureGeneratedPassword(ctx, h, instance, name.Name, mariadb.MetricsPasswordKey)
+	if err != nil {
+		return err
+	}
+
+	pkgsvc := mariadb.MetricsService(instance)
+	service := &corev1.Service{ObjectMeta: pkgsvc.ObjectMeta}
+	op, err := controllerutil.CreateOrPatch(ctx, r.Client, service, func() error {
+		service.Labels = pkgsvc.Labels
+		service.Spec.Selector = pkgsvc.Spec.Selector
+		service.Spec.Ports = pkgsvc.Spec.Ports
+		service.Spec.PublishNotReadyAddresses = pkgsvc.Spec.PublishNotReadyAddresses
+		return controllerutil.SetControllerReference(instance, service, r.Client.Scheme())
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		log.Info("", "Kind", instance.Kind, "Name", instance.Name, "metrics service", service.Name, "operation", string(op))
+	}
+
+	if !serviceMonitorSupported {
+		return nil
+	}
+	pkgsm := mariadb.MetricsServiceMonitor(instance)
+	sm := &unstructured.Unstructured{}
+	sm.SetGroupVersionKind(mariadb.ServiceMonitorGVK)
+	sm.SetName(pkgsm.GetName())
+	sm.SetNamespace(pkgsm.GetNamespace())
+	op, err = controllerutil.CreateOrPatch(ctx, r.Client, sm, func() error {
+		sm.SetLabels(pkgsm.GetLabels())
+		sm.Object["spec"] = pkgsm.Object["spec"]
+		return controllerutil.SetControllerReference(instance, sm, r.Client.Scheme())
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		log.Info("", "Kind", instance.Kind, "Name", instance.Name, "metrics service monitor", sm.GetName(), "operation", string(op))
+	}
+	return nil
+}
+
+// reconcileMetricsUser creates the database user of the mysqld exporter, with
+// the privileges needed to read the server status and nothing else, or drops it
+// when the metrics are disabled. It is updated whenever its password changes
+func (r *GaleraReconciler) reconcileMetricsUser(ctx context.Context, h *helper.Helper, instance *mariadbv1.Galera, pods []corev1.Pod) (ctrl.Result, error) {
+	log := h.GetLogger()
+	readyPods := getReadyPods(pods)
+	if len(readyPods) == 0 {
+		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+	}
+	user := "'" + mariadb.MetricsUserName + "'@'%'"
+
+	if instance.Spec.Metrics == nil {
+		err := execSQLInPod(ctx, h, r.config, instance, readyPods[0].Name, "DROP USER IF EXISTS "+user+";",
+			func(_ *bytes.Buffer) error {
+				return nil
+			})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		delete(instance.Status.Hash, mariadbv1.MetricsUserPasswordHash)
+		log.Info("Monitoring user dropped")
+		return ctrl.Result{}, nil
+	}
+
+	password, hash, err := r.ensureGeneratedPassword(ctx, h, instance, mariadb.MetricsName(instance.Name), mariadb.MetricsPasswordKey)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if instance.Status.Hash[mariadbv1.MetricsUserPasswordHash] == hash {
+		return ctrl.Result{}, nil
+	}
+
+	// the exporter is limited to a few connections, in case it gets stuck
+	sql := "CREATE USER IF NOT EXISTS " + user + " IDENTIFIED BY " + sqlQuote(password) + " WITH MAX_USER_CONNECTIONS 3;" +
+		"ALTER USER " + user + " IDENTIFIED BY " + sqlQuote(password) + " WITH MAX_USER_CONNECTIONS 3;" +
+		"GRANT PROCESS, REPLICATION CLIENT ON *.* TO " + user + ";" +
+		"GRANT SELECT ON performance_schema.* TO " + user + ";"
+	err = execSQLInPod(ctx, h, r.config, instance, readyPods[0].Name, sql,
+		func(_ *bytes.Buffer) error {
+			return nil
+		})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	instance.Status.Hash[mariadbv1.MetricsUserPasswordHash] = hash
+	log.Info("Monitoring user configured", "pod", readyPods[0].Name)
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// hasMetricsContainer checks whether the mysqld exporter runs in the galera pods
+func hasMetricsContainer(t *testing.T, c client.Client) bool {
+	sts := get(t, c, &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "openstack-galera", Namespace: testNamespace}})
+	for _, container := range sts.Spec.Template.Spec.Containers {
+		if container.Name == "mysqld-exporter" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMetrics(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera, objs := newBootstrappedGalera(3)
+	galera.Spec.Metrics = &mariadbv1.GaleraMetrics{ContainerImage: "quay.io/prometheus/mysqld-exporter:latest"}
+	c := newFakeClient(objs...)
+	r := newTestGaleraReconciler(c)
+	exec := stubExec(t, func(_ string, cmd string) (string, error) {
+		if strings.Contains(cmd, "VERSION()") {
+			return "10.11.6-MariaDB\n", nil
+		}
+		return "", nil
+	})
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "openstack-metrics", Namespace: testNamespace}}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "openstack-metrics", Namespace: testNamespace}}
+
+	_, err := reconcileN(t, r, galera, 4)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// the exporter of every galera pod is exposed, including the pods that are not ready
+	svc = get(t, c, svc)
+	g.Expect(svc.Spec.Ports).To(ConsistOf(HaveField("Port", int32(mariadb.MetricsPort))))
+	g.Expect(svc.Spec.PublishNotReadyAddresses).To(BeTrue())
+	for i := 0; i < 3; i++ {
+		pod := newTestGaleraPod(galera, i, false)
+		g.Expect(labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels))).To(BeTrue())
+	}
+	g.Expect(svc.Spec.Selector).ToNot(HaveKey(mariadb.ActivePodSelectorKey))
+	g.Expect(svc.OwnerReferences).To(HaveLen(1))
+	password := string(get(t, c, secret).Data[mariadb.MetricsPasswordKey])
+	g.Expect(password).To(HaveLen(32))
+	g.Expect(hasMetricsContainer(t, c)).To(BeTrue())
+	// the metrics service must not be mistaken for the database service
+	dbsvc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "openstack", Namespace: testNamespace}}
+	g.Expect(get(t, c, svc).Labels).ToNot(Equal(get(t, c, dbsvc).Labels))
+
+	// the monitoring user is created once the cluster is available,
+	// with the privileges needed to read the server status only
+	simulateStatefulSetAvailable(t, c, galera)
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	created := exec.ran("openstack-galera-0", "CREATE USER IF NOT EXISTS 'mysqld_exporter'@'%'")
+	g.Expect(created).To(HaveLen(1))
+	g.Expect(created[0]).To(ContainSubstring("IDENTIFIED BY '" + password + "'"))
+	g.Expect(created[0]).To(ContainSubstring("GRANT PROCESS, REPLICATION CLIENT ON *.*"))
+	g.Expect(created[0]).To(ContainSubstring("GRANT SELECT ON performance_schema.*"))
+	g.Expect(created[0]).ToNot(ContainSubstring("ALL PRIVILEGES"))
+	g.Expect(get(t, c, galera).Status.Hash).To(HaveKey(mariadbv1.MetricsUserPasswordHash))
+
+	// and only updated when its password changes
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(exec.ran("openstack-galera-0", "'mysqld_exporter'@'%' IDENTIFIED")).To(HaveLen(1))
+	secret = get(t, c, secret)
+	secret.Data[mariadb.MetricsPasswordKey] = []byte(`new'pass\word`)
+	g.Expect(c.Update(ctx, secret)).To(Succeed())
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	// the password is escaped in the SQL statements
+	updated := exec.ran("openstack-galera-0", `ALTER USER 'mysqld_exporter'@'%' IDENTIFIED BY 'new\'pass\\word'`)
+	g.Expect(updated).To(HaveLen(1))
+	g.Expect(updated[0]).To(ContainSubstring(`CREATE USER IF NOT EXISTS 'mysqld_exporter'@'%' IDENTIFIED BY 'new\'pass\\word'`))
+
+	// disabling the metrics removes the exporter, its service, secret and user
+	galera = get(t, c, galera)
+	galera.Spec.Metrics = nil
+	g.Expect(c.Update(ctx, galera)).To(Succeed())
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(hasMetricsContainer(t, c)).To(BeFalse())
+	g.Expect(k8s_errors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(svc), svc))).To(BeTrue())
+	g.Expect(k8s_errors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(secret), secret))).To(BeTrue())
+
+	simulateStatefulSetAvailable(t, c, galera)
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(exec.ran("openstack-galera-0", "DROP USER IF EXISTS 'mysqld_exporter'@'%'")).To(HaveLen(1))
+	g.Expect(get(t, c, galera).Status.Hash).ToNot(HaveKey(mariadbv1.MetricsUserPasswordHash))
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(exec.ran("openstack-galera-0", "DROP USER")).To(HaveLen(1))
+}
+
+func TestMetricsServiceMonitor(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera := newTestGalera("openstack", 3)
+	galera.Spec.Metrics = &mariadbv1.GaleraMetrics{}
+	// the ServiceMonitor kind is known when prometheus-operator is installed
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(mariadb.ServiceMonitorGVK, meta.RESTScopeNamespace)
+	c := fake.NewClientBuilder().WithScheme(newTestScheme()).WithRESTMapper(mapper).WithObjects(galera).Build()
+	r := newTestGaleraReconciler(c)
+	sm := &unstructured.Unstructured{}
+	sm.SetGroupVersionKind(mariadb.ServiceMonitorGVK)
+	sm.SetName("openstack-metrics")
+	sm.SetNamespace(testNamespace)
+
+	g.Expect(r.reconcileMetrics(ctx, newTestHelper(t, c, galera), galera)).To(Succeed())
+	sm = get(t, c, sm)
+	g.Expect(sm.GetOwnerReferences()).To(HaveLen(1))
+	g.Expect(sm.GetOwnerReferences()[0].Name).To(Equal("openstack"))
+	// it scrapes the metrics service, and not the database service
+	selector, _, err := unstructured.NestedStringMap(sm.Object, "spec", "selector", "matchLabels")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(selector).To(Equal(get(t, c, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "openstack-metrics", Namespace: testNamespace}}).Labels))
+	endpoints, _, err := unstructured.NestedSlice(sm.Object, "spec", "endpoints")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(endpoints).To(ConsistOf(HaveKeyWithValue("port", "metrics")))
+
+	galera.Spec.Metrics = nil
+	g.Expect(r.reconcileMetrics(ctx, newTestHelper(t, c, galera), galera)).To(Succeed())
+	err = c.Get(ctx, client.ObjectKeyFromObject(sm), sm)
+	g.Expect(k8s_errors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestMetricsWithoutServiceMonitor(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	// without prometheus-operator, only the metrics service is created
+	galera := newTestGalera("openstack", 3)
+	galera.Spec.Metrics = &mariadbv1.GaleraMetrics{}
+	c := newFakeClient(galera)
+	r := newTestGaleraReconciler(c)
+
+	g.Expect(r.reconcileMetrics(ctx, newTestHelper(t, c, galera), galera)).To(Succeed())
+	get(t, c, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "openstack-metrics", Namespace: testNamespace}})
+
+	galera.Spec.Metrics = nil
+	g.Expect(r.reconcileMetrics(ctx, newTestHelper(t, c, galera), galera)).To(Succeed())
+	services := &corev1.ServiceList{}
+	g.Expect(c.List(ctx, services)).To(Succeed())
+	for _, svc := range services.Items {
+		g.Expect(strings.HasSuffix(svc.Name, "-metrics")).To(BeFalse())
+	}
+}
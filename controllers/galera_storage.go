@@ -0,0 +1,185 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+)
+
+const (
+	// storageClassIsDefaultAnnotation marks the default StorageClass of a cluster
+	storageClassIsDefaultAnnotation = "storageclass.kubernetes.io/is-default-class"
+	// storageClassBetaIsDefaultAnnotation is the legacy form of storageClassIsDefaultAnnotation
+	storageClassBetaIsDefaultAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+// keepVolumeClaimTemplates preserves the volume claim templates of an existing
+// statefulset, as they are immutable. A larger storage request is applied
+// directly on the volumes of the galera pods by reconcileStorage
+func (r *GaleraReconciler) keepVolumeClaimTemplates(ctx context.Context, sts *appsv1.StatefulSet) error {
+	current := &appsv1.StatefulSet{}
+	err := r.Get(ctx, types.NamespacedName{Name: sts.Name, Namespace: sts.Namespace}, current)
+	if k8s_errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	sts.Spec.VolumeClaimTemplates = current.Spec.VolumeClaimTemplates
+	return nil
+}
+
+// reconcileStorage expands the volumes of the galera pods up to the storage
+// request of the galera CR, provided that their StorageClass allows it. The
+// resize progress is reported in the StorageReady condition
+func (r *GaleraReconciler) reconcileStorage(ctx context.Context, h *helper.Helper, instance *mariadbv1.Galera) (ctrl.Result, error) {
+	log := h.GetLogger()
+	request, err := resource.ParseQuantity(instance.Spec.StorageRequest)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	resizeError := func(reason string) {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.GaleraStorageReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityWarning,
+			mariadbv1.GaleraStorageResizeErrorMessage,
+			instance.Spec.StorageRequest, reason))
+	}
+
+	replicas := int(*instance.Spec.Replicas)
+	resized := 0
+	for i := 0; i < replicas; i++ {
+		podName := mariadb.StatefulSetName(instance.Name) + "-" + strconv.Itoa(i)
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = r.Client.Get(ctx, types.NamespacedName{Name: mariadb.DataVolumeClaimName(podName), Namespace: instance.Namespace}, pvc)
+		if k8s_errors.IsNotFound(err) {
+			// the pod is not created yet
+			continue
+		} else if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		current := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if current.Cmp(request) < 0 {
+			allowed, err := r.storageClassAllowsExpansion(ctx, pvc.Spec.StorageClassName)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if !allowed {
+				resizeError(fmt.Sprintf("StorageClass of volume %s does not allow volume expansion", pvc.Name))
+				return ctrl.Result{}, nil
+			}
+			patch := client.MergeFrom(pvc.DeepCopy())
+			pvc.Spec.Resources.Requests[corev1.ResourceStorage] = request
+			err = r.Client.Patch(ctx, pvc, patch)
+			if err != nil {
+				resizeError(fmt.Sprintf("volume %s: %s", pvc.Name, err.Error()))
+				return ctrl.Result{}, err
+			}
+			log.Info("Expanding volume", "pvc", pvc.Name, "from", current.String(), "to", instance.Spec.StorageRequest)
+		}
+
+		// the volume is resized once its filesystem has been grown
+		switch pvc.Status.AllocatedResourceStatuses[corev1.ResourceStorage] {
+		case corev1.PersistentVolumeClaimControllerResizeFailed, corev1.PersistentVolumeClaimNodeResizeFailed:
+			resizeError(fmt.Sprintf("volume %s: %s", pvc.Name, pvc.Status.AllocatedResourceStatuses[corev1.ResourceStorage]))
+			return ctrl.Result{}, nil
+		}
+		capacity := pvc.Status.Capacity[corev1.ResourceStorage]
+		if capacity.Cmp(request) >= 0 {
+			resized++
+		}
+	}
+
+	if resized < replicas {
+		instance.Status.Conditions.MarkFalse(
+			mariadbv1.GaleraStorageReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			mariadbv1.GaleraStorageResizingMessage,
+			instance.Spec.StorageRequest, resized, replicas)
+		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+	}
+
+	instance.Status.Conditions.MarkTrue(mariadbv1.GaleraStorageReadyCondition, mariadbv1.GaleraStorageReadyMessage)
+	return ctrl.Result{}, nil
+}
+
+// storageClassAllowsExpansion checks whether volumes of a StorageClass can be expanded.
+// A volume without a StorageClass name was provisioned from the default StorageClass
+func (r *GaleraReconciler) storageClassAllowsExpansion(ctx context.Context, name *string) (bool, error) {
+	if name == nil {
+		sc, err := r.defaultStorageClass(ctx)
+		if sc == nil || err != nil {
+			return false, err
+		}
+		return sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion, nil
+	}
+	if *name == "" {
+		return false, nil
+	}
+	sc := &storagev1.StorageClass{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: *name}, sc)
+	if k8s_errors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion, nil
+}
+
+// defaultStorageClass returns the StorageClass annotated as the default one of the
+// cluster, or nil if there is none. Like the DefaultStorageClass admission plugin,
+// the most recently created one is used if several are annotated
+func (r *GaleraReconciler) defaultStorageClass(ctx context.Context) (*storagev1.StorageClass, error) {
+	scs := &storagev1.StorageClassList{}
+	err := r.Client.List(ctx, scs)
+	if err != nil {
+		return nil, err
+	}
+	var res *storagev1.StorageClass
+	for i := range scs.Items {
+		sc := &scs.Items[i]
+		if sc.Annotations[storageClassIsDefaultAnnotation] != "true" &&
+			sc.Annotations[storageClassBetaIsDefaultAnnotation] != "true" {
+			continue
+		}
+		if res == nil || sc.CreationTimestamp.After(res.CreationTimestamp.Time) ||
+			(sc.CreationTimestamp.Equal(&res.CreationTimestamp) && sc.Name < res.Name) {
+			res = sc
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newTestStorageClass(name string, expansion bool, isDefault bool, created time.Time) *storagev1.StorageClass {
+	sc := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Provisioner:          "kubernetes.io/no-provisioner",
+		AllowVolumeExpansion: ptr.To(expansion),
+	}
+	if isDefault {
+		sc.Annotations = map[string]string{storageClassIsDefaultAnnotation: "true"}
+	}
+	return sc
+}
+
+// newTestDataVolumeClaim returns the volume of a galera pod, of the requested
+// and provisioned size
+func newTestDataVolumeClaim(g *mariadbv1.Galera, index int, storageClass *string, size string) *corev1.PersistentVolumeClaim {
+	podName := fmt.Sprintf("%s-%d", mariadb.StatefulSetName(g.Name), index)
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: mariadb.DataVolumeClaimName(podName), Namespace: g.Namespace},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: storageClass,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+		},
+	}
+}
+
+func TestReconcileStorage(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name          string
+		storageClass  *string
+		classes       []client.Object
+		size          string
+		expanded      bool
+		status        corev1.ConditionStatus
+		message       string
+		requeueAfter  time.Duration
+		resizeFailure bool
+	}{
+		{
+			name:         "Volumes of the requested size",
+			storageClass: ptr.To("local-storage"),
+			classes:      []client.Object{newTestStorageClass("local-storage", false, false, now)},
+			size:         "10G",
+			status:       corev1.ConditionTrue,
+			message:      mariadbv1.GaleraStorageReadyMessage,
+		},
+		{
+			name:         "StorageClass allows expansion",
+			storageClass: ptr.To("ceph"),
+			classes:      []client.Object{newTestStorageClass("ceph", true, false, now)},
+			size:         "5G",
+			expanded:     true,
+			status:       corev1.ConditionFalse,
+			message:      "Resizing volumes to 10G: 0/3 volumes resized",
+			requeueAfter: 10 * time.Second,
+		},
+		{
+			name:         "StorageClass does not allow expansion",
+			storageClass: ptr.To("local-storage"),
+			classes:      []client.Object{newTestStorageClass("local-storage", false, false, now)},
+			size:         "5G",
+			status:       corev1.ConditionFalse,
+			message:      "Resizing volumes to 10G failed: StorageClass of volume mysql-db-openstack-galera-0 does not allow volume expansion",
+		},
+		{
+			name:         "StorageClass not found",
+			storageClass: ptr.To("ceph"),
+			size:         "5G",
+			status:       corev1.ConditionFalse,
+			message:      "Resizing volumes to 10G failed: StorageClass of volume mysql-db-openstack-galera-0 does not allow volume expansion",
+		},
+		{
+			name:         "Volumes without StorageClass",
+			storageClass: ptr.To(""),
+			classes:      []client.Object{newTestStorageClass("ceph", true, true, now)},
+			size:         "5G",
+			status:       corev1.ConditionFalse,
+			message:      "Resizing volumes to 10G failed: StorageClass of volume mysql-db-openstack-galera-0 does not allow volume expansion",
+		},
+		{
+			name: "Default StorageClass allows expansion",
+			classes: []client.Object{
+				newTestStorageClass("local-storage", false, false, now),
+				newTestStorageClass("ceph", true, true, now),
+			},
+			size:         "5G",
+			expanded:     true,
+			status:       corev1.ConditionFalse,
+			message:      "Resizing volumes to 10G: 0/3 volumes resized",
+			requeueAfter: 10 * time.Second,
+		},
+		{
+			name: "Default StorageClass does not allow expansion",
+			classes: []client.Object{
+				newTestStorageClass("local-storage", false, true, now),
+				newTestStorageClass("ceph", true, false, now),
+			},
+			size:    "5G",
+			status:  corev1.ConditionFalse,
+			message: "Resizing volumes to 10G failed: StorageClass of volume mysql-db-openstack-galera-0 does not allow volume expansion",
+		},
+		{
+			name: "Most recent default StorageClass",
+			classes: []client.Object{
+				newTestStorageClass("local-storage", false, true, now.Add(-time.Hour)),
+				newTestStorageClass("ceph", true, true, now),
+			},
+			size:         "5G",
+			expanded:     true,
+			status:       corev1.ConditionFalse,
+			message:      "Resizing volumes to 10G: 0/3 volumes resized",
+			requeueAfter: 10 * time.Second,
+		},
+		{
+			name:    "No default StorageClass",
+			classes: []client.Object{newTestStorageClass("ceph", true, false, now)},
+			size:    "5G",
+			status:  corev1.ConditionFalse,
+			message: "Resizing volumes to 10G failed: StorageClass of volume mysql-db-openstack-galera-0 does not allow volume expansion",
+		},
+		{
+			name:          "Resize failure",
+			storageClass:  ptr.To("ceph"),
+			classes:       []client.Object{newTestStorageClass("ceph", true, false, now)},
+			size:          "5G",
+			expanded:      true,
+			resizeFailure: true,
+			status:        corev1.ConditionFalse,
+			message:       "Resizing volumes to 10G failed: volume mysql-db-openstack-galera-0: ControllerResizeFailed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+
+			galera := newTestGalera("openstack", 3)
+			objs := append([]client.Object{galera}, tt.classes...)
+			for i := 0; i < 3; i++ {
+				pvc := newTestDataVolumeClaim(galera, i, tt.storageClass, tt.size)
+				if tt.resizeFailure {
+					pvc.Status.AllocatedResourceStatuses = map[corev1.ResourceName]corev1.ClaimResourceStatus{
+						corev1.ResourceStorage: corev1.PersistentVolumeClaimControllerResizeFailed,
+					}
+				}
+				objs = append(objs, pvc)
+			}
+			c := newFakeClient(objs...)
+			r := newTestGaleraReconciler(c)
+			galera.Status.Conditions = condition.Conditions{}
+
+			result, err := r.reconcileStorage(ctx, newTestHelper(t, c, galera), galera)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result).To(Equal(ctrl.Result{RequeueAfter: tt.requeueAfter}))
+			cond := galera.Status.Conditions.Get(mariadbv1.GaleraStorageReadyCondition)
+			g.Expect(cond.Status).To(Equal(tt.status))
+			g.Expect(cond.Message).To(Equal(tt.message))
+
+			pvc := get(t, c, newTestDataVolumeClaim(galera, 0, nil, tt.size))
+			request := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+			if tt.expanded {
+				g.Expect(request.String()).To(Equal("10G"))
+			} else {
+				g.Expect(request.String()).To(Equal(tt.size))
+			}
+		})
+	}
+}
+
+func TestReconcileStorageResized(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera := newTestGalera("openstack", 3)
+	objs := []client.Object{galera, newTestStorageClass("ceph", true, true, time.Now())}
+	for i := 0; i < 3; i++ {
+		objs = append(objs, newTestDataVolumeClaim(galera, i, nil, "5G"))
+	}
+	c := newFakeClient(objs...)
+	r := newTestGaleraReconciler(c)
+	galera.Status.Conditions = condition.Conditions{}
+	h := newTestHelper(t, c, galera)
+
+	_, err := r.reconcileStorage(ctx, h, galera)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// the volumes are only resized once their filesystem has grown
+	for i := 0; i < 2; i++ {
+		pvc := get(t, c, newTestDataVolumeClaim(galera, i, nil, "5G"))
+		pvc.Status.Capacity[corev1.ResourceStorage] = resource.MustParse("10G")
+		g.Expect(c.Status().Update(ctx, pvc)).To(Succeed())
+	}
+	_, err = r.reconcileStorage(ctx, h, galera)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(galera.Status.Conditions.Get(mariadbv1.GaleraStorageReadyCondition).Message).To(
+		Equal("Resizing volumes to 10G: 2/3 volumes resized"))
+
+	pvc := get(t, c, newTestDataVolumeClaim(galera, 2, nil, "5G"))
+	pvc.Status.Capacity[corev1.ResourceStorage] = resource.MustParse("10G")
+	g.Expect(c.Status().Update(ctx, pvc)).To(Succeed())
+	result, err := r.reconcileStorage(ctx, h, galera)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	g.Expect(galera.Status.Conditions.IsTrue(mariadbv1.GaleraStorageReadyCondition)).To(BeTrue())
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	util "github.com/openstack-k8s-operators/lib-common/modules/common/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubectl/pkg/util/podutils"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+)
+
+// galeraUpgradeNodeTimeout is how long an upgraded galera pod can take to
+// rejoin the cluster before the upgrade is rolled back. It accounts for a
+// full state transfer
+const galeraUpgradeNodeTimeout = time.Duration(30) * time.Minute
+
+// checkImageUpgrade starts the rollout of a new container image when it changed
+// in the spec, and reports the progress of the rollout in the status
+func checkImageUpgrade(helper *helper.Helper, instance *mariadbv1.Galera) {
+	image := instance.Spec.ContainerImage
+	running := instance.Status.ContainerImage
+	up := instance.Status.Upgrade
+	switch {
+	case up != nil && up.ToImage == image:
+		// upgrade already in progress, or rolled back
+	case up != nil && up.RollbackReason != "" && image == running:
+		// the failed upgrade was reverted in the spec
+		util.LogForObject(helper, fmt.Sprintf("Upgrade to %s cancelled", up.ToImage), instance)
+		instance.Status.Upgrade = nil
+	case up == nil && (running == "" || running == image):
+		// nothing to upgrade, or the cluster was never fully deployed
+	default:
+		util.LogForObject(helper, fmt.Sprintf("Container image changed (%s -> %s), starting upgrade", running, image), instance)
+		now := metav1.Now()
+		instance.Status.Upgrade = &mariadbv1.GaleraUpgradeStatus{
+			FromImage:     running,
+			ToImage:       image,
+			FromVersion:   instance.Status.ServerVersion,
+			Partition:     max(*instance.Spec.Replicas-1, 0),
+			NodeStartTime: &now,
+		}
+	}
+
+	up = instance.Status.Upgrade
+	if up == nil {
+		instance.Status.Conditions.MarkTrue(mariadbv1.GaleraUpgradeReadyCondition, mariadbv1.GaleraUpgradeReadyMessage)
+	} else if up.RollbackReason != "" {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.GaleraUpgradeReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityWarning,
+			mariadbv1.GaleraUpgradeRolledBackMessage,
+			up.ToImage, up.RollbackReason, up.FromImage))
+	} else {
+		instance.Status.Conditions.MarkFalse(
+			mariadbv1.GaleraUpgradeReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			mariadbv1.GaleraUpgradeInProgressMessage,
+			up.ToImage, fmt.Sprintf("%s-%d", mariadb.StatefulSetName(instance.Name), up.Partition))
+	}
+}
+
+// reconcileUpgrade rolls a new container image out one galera pod at a time.
+// The statefulset partition is only lowered once the last upgraded pod is synced
+// with the cluster. Once all the pods run the new image, the system tables are
+// upgraded. The pods are rolled back to the previous image if an upgraded pod
+// does not rejoin the cluster in time, or if the new image downgrades the server
+func (r *GaleraReconciler) reconcileUpgrade(ctx context.Context, h *helper.Helper, instance *mariadbv1.Galera, statefulset *appsv1.StatefulSet, pods []corev1.Pod) (ctrl.Result, error) {
+	log := h.GetLogger()
+	up := instance.Status.Upgrade
+	if up.RollbackReason != "" {
+		// the statefulset restores the previous image on its own
+		return ctrl.Result{}, nil
+	}
+
+	// wait for the statefulset to account for the latest partition
+	if statefulset.Status.ObservedGeneration != statefulset.Generation {
+		return ctrl.Result{RequeueAfter: time.Duration(3) * time.Second}, nil
+	}
+
+	name := fmt.Sprintf("%s-%d", statefulset.Name, up.Partition)
+	pod := getPodFromName(pods, name)
+	synced := false
+	if pod != nil && pod.Labels[appsv1.ControllerRevisionHashLabelKey] == statefulset.Status.UpdateRevision &&
+		podutils.IsPodReady(pod) {
+		state, err := getGaleraStatusVariable(ctx, h, r.config, instance, name, "wsrep_local_state_comment")
+		synced = err == nil && state == "Synced"
+	}
+	if !synced {
+		if up.NodeStartTime != nil && time.Since(up.NodeStartTime.Time) > galeraUpgradeNodeTimeout {
+			up.RollbackReason = fmt.Sprintf("pod %s did not rejoin the cluster within %s", name, galeraUpgradeNodeTimeout)
+			util.LogForObject(h, fmt.Sprintf("Upgrade to %s failed, rolling back to %s", up.ToImage, up.FromImage), instance)
+			return ctrl.Result{Requeue: true}, nil
+		}
+		log.Info("Waiting for upgraded pod to be synced", "pod", name)
+		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+	}
+
+	version, err := getServerVersion(ctx, h, r.config, instance, name)
+	if err != nil {
+		log.Info("Server version not available yet", "pod", name, "error", err.Error())
+		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+	}
+	if mariadbv1.IsServerDowngrade(up.FromVersion, version) {
+		up.RollbackReason = fmt.Sprintf("server version %s is older than %s", version, up.FromVersion)
+		util.LogForObject(h, fmt.Sprintf("Upgrade to %s refused, rolling back to %s", up.ToImage, up.FromImage), instance)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if up.Partition > 0 {
+		// only restart the next pod if the whole cluster is available
+		if statefulset.Status.AvailableReplicas != statefulset.Status.Replicas {
+			return ctrl.Result{RequeueAfter: time.Duration(3) * time.Second}, nil
+		}
+		log.Info("Pod upgraded", "pod", name, "version", version)
+		now := metav1.Now()
+		up.Partition--
+		up.NodeStartTime = &now
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// all the pods run the new image, upgrade the system tables. The upgrade
+	// is not replicated, so it runs on every node. The nodes already upgraded
+	// are recorded, so that a failed attempt resumes from the failed node
+	if version != up.FromVersion {
+		for i := int32(0); i < *instance.Spec.Replicas; i++ {
+			name = fmt.Sprintf("%s-%d", statefulset.Name, i)
+			if slices.Contains(up.SystemTablesUpgraded, name) {
+				continue
+			}
+			instance.Status.Conditions.MarkFalse(
+				mariadbv1.GaleraUpgradeReadyCondition,
+				condition.RequestedReason,
+				condition.SeverityInfo,
+				mariadbv1.GaleraUpgradeRunningMessage,
+				name)
+			err = execInPod(ctx, h, r.config, instance.Namespace, name, "galera",
+				[]string{"/bin/bash", "/var/lib/operator-scripts/mysql_upgrade.sh"},
+				func(stdout *bytes.Buffer, _ *bytes.Buffer) error {
+					log.Info("System tables upgraded", "pod", name, "output", strings.TrimSpace(stdout.String()))
+					return nil
+				})
+			if err != nil {
+				log.Error(err, "Failed to upgrade the system tables", "pod", name)
+				return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+			}
+			up.SystemTablesUpgraded = append(up.SystemTablesUpgraded, name)
+		}
+	}
+
+	util.LogForObject(h, fmt.Sprintf("Upgrade to %s finished, server version %s", up.ToImage, version), instance)
+	instance.Status.ServerVersion = version
+	instance.Status.ContainerImage = up.ToImage
+	instance.Status.Upgrade = nil
+	instance.Status.Conditions.MarkTrue(mariadbv1.GaleraUpgradeReadyCondition, mariadbv1.GaleraUpgradeReadyMessage)
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	testFromImage = "quay.io/podified-antelope-centos9/openstack-mariadb:10.5"
+	testToImage   = "quay.io/podified-antelope-centos9/openstack-mariadb:10.11"
+)
+
+func TestCheckImageUpgrade(t *testing.T) {
+	tests := []struct {
+		name      string
+		running   string
+		upgrade   *mariadbv1.GaleraUpgradeStatus
+		expected  *mariadbv1.GaleraUpgradeStatus
+		condition corev1.ConditionStatus
+	}{
+		{
+			name:      "Cluster never deployed",
+			running:   "",
+			condition: corev1.ConditionTrue,
+		},
+		{
+			name:      "Image unchanged",
+			running:   testToImage,
+			condition: corev1.ConditionTrue,
+		},
+		{
+			name:      "Image changed",
+			running:   testFromImage,
+			expected:  &mariadbv1.GaleraUpgradeStatus{FromImage: testFromImage, ToImage: testToImage, FromVersion: "10.5.22-MariaDB", Partition: 2},
+			condition: corev1.ConditionFalse,
+		},
+		{
+			name:      "Upgrade in progress",
+			running:   testFromImage,
+			upgrade:   &mariadbv1.GaleraUpgradeStatus{FromImage: testFromImage, ToImage: testToImage, Partition: 1},
+			expected:  &mariadbv1.GaleraUpgradeStatus{FromImage: testFromImage, ToImage: testToImage, Partition: 1},
+			condition: corev1.ConditionFalse,
+		},
+		{
+			name:      "Upgrade rolled back",
+			running:   testFromImage,
+			upgrade:   &mariadbv1.GaleraUpgradeStatus{FromImage: testFromImage, ToImage: testToImage, RollbackReason: "failed"},
+			expected:  &mariadbv1.GaleraUpgradeStatus{FromImage: testFromImage, ToImage: testToImage, RollbackReason: "failed"},
+			condition: corev1.ConditionFalse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			instance := newTestGalera("openstack", 3)
+			instance.Spec.ContainerImage = testToImage
+			instance.Status.ContainerImage = tt.running
+			instance.Status.ServerVersion = "10.5.22-MariaDB"
+			instance.Status.Upgrade = tt.upgrade
+			c := newFakeClient(instance)
+
+			checkImageUpgrade(newTestHelper(t, c, instance), instance)
+			if tt.expected != nil {
+				g.Expect(instance.Status.Upgrade).ToNot(BeNil())
+				instance.Status.Upgrade.NodeStartTime = nil
+			}
+			g.Expect(instance.Status.Upgrade).To(Equal(tt.expected))
+			g.Expect(instance.Status.Conditions.Get(mariadbv1.GaleraUpgradeReadyCondition).Status).To(Equal(tt.condition))
+		})
+	}
+}
+
+func TestCheckImageUpgradeCancelled(t *testing.T) {
+	g := NewWithT(t)
+
+	// a failed upgrade is reverted in the spec
+	instance := newTestGalera("openstack", 3)
+	instance.Spec.ContainerImage = testFromImage
+	instance.Status.ContainerImage = testFromImage
+	instance.Status.Upgrade = &mariadbv1.GaleraUpgradeStatus{FromImage: testFromImage, ToImage: testToImage, RollbackReason: "failed"}
+	c := newFakeClient(instance)
+
+	checkImageUpgrade(newTestHelper(t, c, instance), instance)
+	g.Expect(instance.Status.Upgrade).To(BeNil())
+	g.Expect(instance.Status.Conditions.IsTrue(mariadbv1.GaleraUpgradeReadyCondition)).To(BeTrue())
+}
+
+// upgradeTest holds a galera cluster being upgraded, whose pods run
+// either the previous revision of the statefulset or the updated one
+type upgradeTest struct {
+	t        *testing.T
+	r        *GaleraReconciler
+	instance *mariadbv1.Galera
+	sts      *appsv1.StatefulSet
+	pods     []corev1.Pod
+	versions map[string]string
+	exec     *fakeExec
+}
+
+func newUpgradeTest(t *testing.T) *upgradeTest {
+	instance := newTestGalera("openstack", 3)
+	instance.Spec.ContainerImage = testToImage
+	instance.Status.ContainerImage = testFromImage
+	instance.Status.ServerVersion = "10.5.22-MariaDB"
+	instance.Status.Bootstrapped = true
+	c := newFakeClient(instance)
+	checkImageUpgrade(newTestHelper(t, c, instance), instance)
+
+	u := &upgradeTest{
+		t:        t,
+		r:        newTestGaleraReconciler(c),
+		instance: instance,
+		sts: &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "openstack-galera", Namespace: testNamespace, Generation: 1},
+			Status: appsv1.StatefulSetStatus{
+				ObservedGeneration: 1, Replicas: 3, AvailableReplicas: 3,
+				CurrentRevision: "rev1", UpdateRevision: "rev2",
+			},
+		},
+		versions: map[string]string{},
+	}
+	for i := 0; i < 3; i++ {
+		pod := newTestGaleraPod(instance, i, true)
+		pod.Labels[appsv1.ControllerRevisionHashLabelKey] = "rev1"
+		u.pods = append(u.pods, *pod)
+		u.versions[pod.Name] = "10.5.22-MariaDB"
+	}
+	u.exec = stubExec(t, func(pod string, cmd string) (string, error) {
+		switch {
+		case strings.Contains(cmd, "wsrep_local_state_comment"):
+			return "wsrep_local_state_comment\tSynced\n", nil
+		case cmd == "SELECT VERSION();":
+			return u.versions[pod] + "\n", nil
+		}
+		return "", nil
+	})
+	return u
+}
+
+// restart simulates the restart of a pod by the statefulset controller
+func (u *upgradeTest) restart(index int, version string) {
+	u.pods[index].Labels[appsv1.ControllerRevisionHashLabelKey] = "rev2"
+	u.versions[u.pods[index].Name] = version
+}
+
+func (u *upgradeTest) reconcile() ctrl.Result {
+	result, err := u.r.reconcileUpgrade(context.Background(), newTestHelper(u.t, u.r.Client, u.instance), u.instance, u.sts, u.pods)
+	NewWithT(u.t).Expect(err).ToNot(HaveOccurred())
+	return result
+}
+
+// partition returns the partition of the statefulset generated for the galera CR
+func (u *upgradeTest) partition() int32 {
+	sts, err := mariadb.StatefulSet(u.instance, "hash")
+	NewWithT(u.t).Expect(err).ToNot(HaveOccurred())
+	return *sts.Spec.UpdateStrategy.RollingUpdate.Partition
+}
+
+func (u *upgradeTest) image() string {
+	sts, err := mariadb.StatefulSet(u.instance, "hash")
+	NewWithT(u.t).Expect(err).ToNot(HaveOccurred())
+	return sts.Spec.Template.Spec.Containers[0].Image
+}
+
+func TestReconcileUpgrade(t *testing.T) {
+	g := NewWithT(t)
+	u := newUpgradeTest(t)
+
+	// the new image is only rolled out to the last pod
+	g.Expect(u.instance.Status.Upgrade.Partition).To(BeEquivalentTo(2))
+	g.Expect(u.partition()).To(BeEquivalentTo(2))
+	g.Expect(u.image()).To(Equal(testToImage))
+	g.Expect(u.reconcile().RequeueAfter).To(Equal(10 * time.Second))
+	g.Expect(u.instance.Status.Upgrade.Partition).To(BeEquivalentTo(2))
+
+	// the partition is lowered one pod at a time, once the last
+	// upgraded pod is synced and the whole cluster is available
+	for i := 2; i > 0; i-- {
+		u.restart(i, "10.11.6-MariaDB")
+		u.sts.Status.AvailableReplicas = 2
+		g.Expect(u.reconcile().RequeueAfter).To(Equal(3 * time.Second))
+		g.Expect(u.instance.Status.Upgrade.Partition).To(BeEquivalentTo(i))
+
+		u.sts.Status.AvailableReplicas = 3
+		g.Expect(u.reconcile().Requeue).To(BeTrue())
+		g.Expect(u.instance.Status.Upgrade.Partition).To(BeEquivalentTo(i - 1))
+		g.Expect(u.partition()).To(BeEquivalentTo(i - 1))
+	}
+
+	// once the last pod is upgraded, the system tables are upgraded on
+	// every node, as the upgrade is not replicated
+	u.restart(0, "10.11.6-MariaDB")
+	g.Expect(u.reconcile()).To(Equal(ctrl.Result{}))
+	for i := 0; i < 3; i++ {
+		g.Expect(u.exec.ran(fmt.Sprintf("openstack-galera-%d", i), "mysql_upgrade.sh")).To(HaveLen(1))
+	}
+	g.Expect(u.instance.Status.Upgrade).To(BeNil())
+	g.Expect(u.instance.Status.ContainerImage).To(Equal(testToImage))
+	g.Expect(u.instance.Status.ServerVersion).To(Equal("10.11.6-MariaDB"))
+	g.Expect(u.instance.Status.Conditions.IsTrue(mariadbv1.GaleraUpgradeReadyCondition)).To(BeTrue())
+	g.Expect(u.partition()).To(BeZero())
+}
+
+func TestReconcileUpgradeSystemTablesFailure(t *testing.T) {
+	g := NewWithT(t)
+	u := newUpgradeTest(t)
+	u.instance.Status.Upgrade.Partition = 0
+	for i := range u.pods {
+		u.restart(i, "10.11.6-MariaDB")
+	}
+	failing := "openstack-galera-1"
+	u.exec.reply = func(pod string, cmd string) (string, error) {
+		switch {
+		case strings.Contains(cmd, "wsrep_local_state_comment"):
+			return "wsrep_local_state_comment\tSynced\n", nil
+		case cmd == "SELECT VERSION();":
+			return "10.11.6-MariaDB\n", nil
+		case pod == failing:
+			return "", errors.New("command terminated with exit code 1")
+		}
+		return "", nil
+	}
+
+	// the upgrade is retried until the system tables are upgraded
+	g.Expect(u.reconcile().RequeueAfter).To(Equal(10 * time.Second))
+	g.Expect(u.instance.Status.Upgrade).ToNot(BeNil())
+	g.Expect(u.instance.Status.Upgrade.SystemTablesUpgraded).To(Equal([]string{"openstack-galera-0"}))
+	g.Expect(u.instance.Status.Conditions.Get(mariadbv1.GaleraUpgradeReadyCondition).Message).To(Equal("Running mariadb-upgrade on pod openstack-galera-1"))
+
+	// a retry resumes from the node that failed
+	failing = ""
+	g.Expect(u.reconcile()).To(Equal(ctrl.Result{}))
+	g.Expect(u.exec.ran("openstack-galera-0", "mysql_upgrade.sh")).To(HaveLen(1))
+	g.Expect(u.exec.ran("openstack-galera-1", "mysql_upgrade.sh")).To(HaveLen(2))
+	g.Expect(u.exec.ran("openstack-galera-2", "mysql_upgrade.sh")).To(HaveLen(1))
+	g.Expect(u.instance.Status.Upgrade).To(BeNil())
+}
+
+func TestReconcileUpgradeRollback(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(u *upgradeTest)
+		reason  string
+	}{
+		{
+			name: "Pod does not rejoin in time",
+			prepare: func(u *upgradeTest) {
+				started := metav1.NewTime(time.Now().Add(-galeraUpgradeNodeTimeout - time.Minute))
+				u.instance.Status.Upgrade.NodeStartTime = &started
+				u.restart(2, "10.11.6-MariaDB")
+				u.pods[2].Status.Conditions[0].Status = corev1.ConditionFalse
+			},
+			reason: "pod openstack-galera-2 did not rejoin the cluster within 30m0s",
+		},
+		{
+			name: "Server downgrade",
+			prepare: func(u *upgradeTest) {
+				u.restart(2, "10.3.39-MariaDB")
+			},
+			reason: "server version 10.3.39-MariaDB is older than 10.5.22-MariaDB",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			u := newUpgradeTest(t)
+			tt.prepare(u)
+
+			g.Expect(u.reconcile().Requeue).To(BeTrue())
+			g.Expect(u.instance.Status.Upgrade.RollbackReason).To(Equal(tt.reason))
+
+			// all the pods go back to the previous image
+			g.Expect(u.partition()).To(BeZero())
+			g.Expect(u.image()).To(Equal(testFromImage))
+			checkImageUpgrade(newTestHelper(t, u.r.Client, u.instance), u.instance)
+			cond := u.instance.Status.Conditions.Get(mariadbv1.GaleraUpgradeReadyCondition)
+			g.Expect(cond.Reason).To(Equal(condition.Reason(condition.ErrorReason)))
+			g.Expect(cond.Message).To(ContainSubstring(tt.reason))
+
+			// nothing happens until the spec changes again
+			g.Expect(u.reconcile()).To(Equal(ctrl.Result{}))
+			g.Expect(u.exec.ran("openstack-galera-2", "mysql_upgrade.sh")).To(BeEmpty())
+		})
+	}
+}
@@ -0,0 +1,460 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	job "github.com/openstack-k8s-operators/lib-common/modules/common/job"
+	"github.com/openstack-k8s-operators/lib-common/modules/common/service"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+)
+
+// GaleraBackupReconciler reconciles a GaleraBackup object
+type GaleraBackupReconciler struct {
+	client.Client
+	Kclient kubernetes.Interface
+	config  *rest.Config
+	Scheme  *runtime.Scheme
+}
+
+// backupResult holds the summary reported by a backup job on completion
+type backupResult struct {
+	UUID  string `json:"uuid"`
+	Seqno string `json:"seqno"`
+	Size  int64  `json:"size"`
+}
+
+// findBackupNode returns the name of a Synced galera pod to take a backup from.
+// Pods that are not the active endpoint of the galera service are preferred,
+// so that the backup does not impact the node serving client traffic
+func (r *GaleraBackupReconciler) findBackupNode(ctx context.Context, h *helper.Helper, galera *mariadbv1.Galera, pods []corev1.Pod) string {
+	activePod := ""
+	svc, err := service.GetServiceWithName(ctx, h, galera.Name, galera.Namespace)
+	if err == nil {
+		activePod = svc.Spec.Selector[mariadb.ActivePodSelectorKey]
+	}
+
+	candidates := []corev1.Pod{}
+	for _, pod := range getReadyPods(pods) {
+		// pods with attributes in the galera status are still being started
+		if _, found := galera.Status.Attributes[pod.Name]; found {
+			continue
+		}
+		candidates = append(candidates, pod)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		iActive, jActive := candidates[i].Name == activePod, candidates[j].Name == activePod
+		if iActive != jActive {
+			return jActive
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+
+	for _, pod := range candidates {
+		state, err := getGaleraStatusVariable(ctx, h, r.config, galera, pod.Name, "wsrep_local_state_comment")
+		if err != nil {
+			h.GetLogger().Info("Could not retrieve galera state", "pod", pod.Name, "error", err.Error())
+			continue
+		}
+		if state == "Synced" {
+			return pod.Name
+		}
+	}
+	return ""
+}
+
+// getBackupJobResult retrieves the summary written by a successful backup job
+// in the termination message of its container
+func (r *GaleraBackupReconciler) getBackupJobResult(ctx context.Context, instance *mariadbv1.GaleraBackup) (*backupResult, error) {
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(instance.Namespace),
+		client.MatchingLabels(map[string]string{"job-name": mariadb.BackupJobName(instance)}),
+	}
+	if err := r.List(ctx, podList, listOpts...); err != nil {
+		return nil, err
+	}
+
+	for _, pod := range podList.Items {
+		if pod.Status.Phase != corev1.PodSucceeded {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Terminated == nil {
+				continue
+			}
+			result := &backupResult{}
+			if err := json.Unmarshal([]byte(status.State.Terminated.Message), result); err != nil {
+				return nil, fmt.Errorf("unable to parse result of backup job pod %s: %w", pod.Name, err)
+			}
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("no result found for backup job %s", mariadb.BackupJobName(instance))
+}
+
+// RBAC for galerabackup resources
+// +kubebuilder:rbac:groups=mariadb.openstack.org,resources=galerabackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=mariadb.openstack.org,resources=galerabackups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=mariadb.openstack.org,resources=galerabackups/finalizers,verbs=update;patch
+
+// RBAC for backup jobs
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile - GaleraBackup
+func (r *GaleraBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, _err error) {
+	log := GetLog(ctx, "galerabackup")
+
+	// Fetch the GaleraBackup instance
+	instance := &mariadbv1.GaleraBackup{}
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	helper, err := helper.NewHelper(
+		instance,
+		r.Client,
+		r.Kclient,
+		r.Scheme,
+		log,
+	)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// initialize status if Conditions is nil, but do not reset if it already
+	// exists
+	isNewInstance := instance.Status.Conditions == nil
+	if isNewInstance {
+		instance.Status.Conditions = condition.Conditions{}
+	}
+
+	// Save a copy of the condtions so that we can restore the LastTransitionTime
+	// when a condition's state doesn't change.
+	savedConditions := instance.Status.Conditions.DeepCopy()
+
+	// Always patch the instance status when exiting this function so we can
+	// persist any changes.
+	defer func() {
+		condition.RestoreLastTransitionTimes(
+			&instance.Status.Conditions, savedConditions)
+		if instance.Status.Conditions.IsUnknown(condition.ReadyCondition) {
+			instance.Status.Conditions.Set(
+				instance.Status.Conditions.Mirror(condition.ReadyCondition))
+		}
+		err := helper.PatchInstance(ctx, instance)
+		if err != nil {
+			_err = err
+			return
+		}
+	}()
+
+	// initialize conditions used later as Status=Unknown
+	cl := condition.CreateList(
+		condition.UnknownCondition(condition.ReadyCondition, condition.InitReason, condition.ReadyInitMessage),
+		condition.UnknownCondition(mariadbv1.MariaDBServerReadyCondition, condition.InitReason, mariadbv1.MariaDBServerReadyInitMessage),
+		condition.UnknownCondition(mariadbv1.GaleraBackupReadyCondition, condition.InitReason, mariadbv1.GaleraBackupReadyInitMessage),
+	)
+
+	instance.Status.Conditions.Init(&cl)
+
+	// If we're not deleting this and the backup object doesn't have our finalizer, add it.
+	if instance.DeletionTimestamp.IsZero() && controllerutil.AddFinalizer(instance, helper.GetFinalizer()) || isNewInstance {
+		return ctrl.Result{}, nil
+	}
+
+	// Handle backup delete
+	if !instance.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, instance, helper)
+	}
+
+	// A backup is only taken once, nothing more to do once it completed
+	if instance.Status.Completed {
+		instance.Status.Conditions.MarkTrue(mariadbv1.MariaDBServerReadyCondition, mariadbv1.MariaDBServerReadyMessage)
+		instance.Status.Conditions.MarkTrue(mariadbv1.GaleraBackupReadyCondition, mariadbv1.GaleraBackupReadyMessage)
+		instance.Status.Conditions.MarkTrue(condition.ReadyCondition, condition.ReadyMessage)
+		return ctrl.Result{}, nil
+	}
+
+	galera, err := GetDatabaseObject(ctx, r.Client, instance.Spec.DatabaseInstance, instance.Namespace)
+	if err != nil {
+		if k8s_errors.IsNotFound(err) {
+			instance.Status.Conditions.Set(condition.FalseCondition(
+				mariadbv1.MariaDBServerReadyCondition,
+				mariadbv1.ReasonDBNotFound,
+				condition.SeverityInfo,
+				mariadbv1.MariaDBErrorRetrievingMariaDBGaleraMessage,
+				instance.Spec.DatabaseInstance))
+			return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+		}
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.MariaDBServerReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityError,
+			mariadbv1.MariaDBErrorRetrievingMariaDBGaleraMessage,
+			err))
+		return ctrl.Result{}, err
+	}
+
+	if !galera.Status.Bootstrapped {
+		log.Info("DB bootstrap not complete. Requeue...")
+		instance.Status.Conditions.MarkFalse(
+			mariadbv1.MariaDBServerReadyCondition,
+			mariadbv1.ReasonDBWaitingInitialized,
+			condition.SeverityInfo,
+			mariadbv1.MariaDBServerNotBootstrappedMessage,
+		)
+		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+	}
+
+	instance.Status.Conditions.MarkTrue(mariadbv1.MariaDBServerReadyCondition, mariadbv1.MariaDBServerReadyMessage)
+
+	// Retrieve pods managed by the galera statefulset
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(galera.Namespace),
+		client.MatchingLabels(mariadb.StatefulSetLabels(galera)),
+	}
+	if err = r.List(ctx, podList, listOpts...); err != nil {
+		log.Error(err, "Failed to list pods")
+		return ctrl.Result{}, err
+	}
+
+	// Select the node to back up
+	if instance.Status.Node == "" {
+		node := r.findBackupNode(ctx, helper, galera, podList.Items)
+		if node == "" {
+			instance.Status.Conditions.Set(condition.FalseCondition(
+				mariadbv1.GaleraBackupReadyCondition,
+				condition.RequestedReason,
+				condition.SeverityInfo,
+				mariadbv1.GaleraBackupNoSyncedNodeMessage))
+			return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+		}
+		log.Info("Galera node selected for backup", "pod", node)
+		now := metav1.Now()
+		instance.Status.Node = node
+		instance.Status.Path = mariadb.BackupPath(instance)
+		instance.Status.StartTime = &now
+	}
+
+	pod := getPodFromName(podList.Items, instance.Status.Node)
+	if pod == nil {
+		// the node went away before the backup could run, select another one
+		log.Info("Galera node selected for backup no longer exists", "pod", instance.Status.Node)
+		instance.Status.Node = ""
+		return ctrl.Result{RequeueAfter: time.Duration(3) * time.Second}, nil
+	}
+
+	// Desync the node while the backup is running. Do not desync
+	// it again once the backup job is finished
+	backupJobRunning := true
+	existingJob, err := job.GetJobWithName(ctx, helper, mariadb.BackupJobName(instance), instance.Namespace)
+	if err == nil {
+		backupJobRunning = existingJob.Status.Succeeded == 0 && existingJob.Status.Failed == 0
+	} else if !k8s_errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if backupJobRunning {
+		err = setGaleraNodeDesync(ctx, helper, r.config, galera, pod.Name, true)
+		if err != nil {
+			log.Error(err, "Failed to desync galera node", "pod", pod.Name)
+			return ctrl.Result{}, err
+		}
+	}
+
+	jobDef, err := mariadb.BackupJob(instance, galera, pod)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	backupJob := job.NewJob(
+		jobDef,
+		mariadbv1.GaleraBackupHash,
+		false,
+		time.Duration(5)*time.Second,
+		instance.Status.Hash[mariadbv1.GaleraBackupHash],
+	)
+	ctrlResult, err := backupJob.DoJob(ctx, helper)
+	if (ctrlResult != ctrl.Result{}) {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.GaleraBackupReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			mariadbv1.GaleraBackupReadyRunningMessage,
+			instance.Status.Node))
+		return ctrlResult, nil
+	}
+	if err != nil {
+		if resyncErr := setGaleraNodeDesync(ctx, helper, r.config, galera, pod.Name, false); resyncErr != nil {
+			log.Error(resyncErr, "Failed to resync galera node", "pod", pod.Name)
+		}
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.GaleraBackupReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityError,
+			mariadbv1.GaleraBackupReadyErrorMessage,
+			err.Error()))
+		if backupJob.HasReachedLimit() {
+			// do not retry, a failed backup must be recreated
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if backupJob.HasChanged() {
+		if instance.Status.Hash == nil {
+			instance.Status.Hash = make(map[string]string)
+		}
+		instance.Status.Hash[mariadbv1.GaleraBackupHash] = backupJob.GetHash()
+		log.Info(fmt.Sprintf("Job %s hash added - %s", jobDef.Name, instance.Status.Hash[mariadbv1.GaleraBackupHash]))
+	}
+
+	// Resync the node before reading the result of the job, as the next
+	// reconciles do not desync or resync it once the job is finished
+	err = setGaleraNodeDesync(ctx, helper, r.config, galera, pod.Name, false)
+	if err != nil {
+		log.Error(err, "Failed to resync galera node", "pod", pod.Name)
+		return ctrl.Result{}, err
+	}
+
+	backup, err := r.getBackupJobResult(ctx, instance)
+	if err != nil {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.GaleraBackupReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityWarning,
+			mariadbv1.GaleraBackupReadyErrorMessage,
+			err.Error()))
+		return ctrl.Result{}, err
+	}
+
+	// backup finished, round up its size for readability
+	now := metav1.Now()
+	size := (backup.Size + (1 << 20) - 1) &^ ((1 << 20) - 1)
+	instance.Status.UUID = backup.UUID
+	instance.Status.Seqno = backup.Seqno
+	instance.Status.Size = resource.NewQuantity(size, resource.BinarySI).String()
+	instance.Status.CompletionTime = &now
+	instance.Status.Duration = &metav1.Duration{Duration: now.Sub(instance.Status.StartTime.Time).Round(time.Second)}
+	instance.Status.Completed = true
+	log.Info("Galera backup completed", "pod", pod.Name, "seqno", backup.Seqno, "size", instance.Status.Size)
+
+	instance.Status.Conditions.MarkTrue(mariadbv1.GaleraBackupReadyCondition, mariadbv1.GaleraBackupReadyMessage)
+
+	// We reached the end of the Reconcile, update the Ready condition based on
+	// the sub conditions
+	if instance.Status.Conditions.AllSubConditionIsTrue() {
+		instance.Status.Conditions.MarkTrue(
+			condition.ReadyCondition, condition.ReadyMessage)
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *GaleraBackupReconciler) reconcileDelete(ctx context.Context, instance *mariadbv1.GaleraBackup, helper *helper.Helper) (ctrl.Result, error) {
+	log := helper.GetLogger()
+	log.Info("Reconciling GaleraBackup delete")
+
+	galera, err := GetDatabaseObject(ctx, r.Client, instance.Spec.DatabaseInstance, instance.Namespace)
+	if err != nil && !k8s_errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if k8s_errors.IsNotFound(err) {
+		// without the galera CR there is no image to run the cleanup job
+		if instance.Status.Path != "" {
+			log.Info("Galera not found, backup artifact left on storage", "claim", instance.Spec.Storage.ClaimName, "path", instance.Status.Path)
+		}
+		controllerutil.RemoveFinalizer(instance, helper.GetFinalizer())
+		return ctrl.Result{}, nil
+	}
+
+	// Do not leave the node desynced if the backup is interrupted
+	if !instance.Status.Completed && instance.Status.Node != "" {
+		err = setGaleraNodeDesync(ctx, helper, r.config, galera, instance.Status.Node, false)
+		if err != nil {
+			log.Info("Could not resync galera node", "pod", instance.Status.Node, "error", err.Error())
+		}
+	}
+
+	// Remove the backup artifact from the backup storage, if it still exists
+	if instance.Status.Path != "" {
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = r.Get(ctx, types.NamespacedName{Name: instance.Spec.Storage.ClaimName, Namespace: instance.Namespace}, pvc)
+		if err != nil && !k8s_errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if err == nil {
+			jobDef, err := mariadb.BackupCleanupJob(instance, galera)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			cleanupJob := job.NewJob(
+				jobDef,
+				mariadbv1.GaleraBackupCleanupHash,
+				false,
+				time.Duration(5)*time.Second,
+				instance.Status.Hash[mariadbv1.GaleraBackupCleanupHash],
+			)
+			ctrlResult, err := cleanupJob.DoJob(ctx, helper)
+			if (ctrlResult != ctrl.Result{}) {
+				return ctrlResult, nil
+			}
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	controllerutil.RemoveFinalizer(instance, helper.GetFinalizer())
+	log.Info("Reconciled GaleraBackup delete successfully")
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GaleraBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.config = mgr.GetConfig()
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mariadbv1.GaleraBackup{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newTestGaleraBackup(g *mariadbv1.Galera) *mariadbv1.GaleraBackup {
+	return &mariadbv1.GaleraBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: g.Namespace},
+		Spec: mariadbv1.GaleraBackupSpec{
+			DatabaseInstance: g.Name,
+			Storage:          mariadbv1.GaleraBackupStorage{ClaimName: "backup-storage"},
+		},
+	}
+}
+
+// newBootstrappedGalera returns a galera CR and the pods of a running cluster
+func newBootstrappedGalera(replicas int32) (*mariadbv1.Galera, []client.Object) {
+	g := newTestGalera("openstack", replicas)
+	g.Status.Bootstrapped = true
+	objs := []client.Object{g, newTestSecret()}
+	for i := 0; i < int(replicas); i++ {
+		objs = append(objs, newTestGaleraPod(g, i, true))
+	}
+	return g, objs
+}
+
+// newBackupJobPod returns the pod of a successful backup job, with the
+// summary of the backup in its termination message
+func newBackupJobPod(b *mariadbv1.GaleraBackup, message string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      mariadb.BackupJobName(b) + "-abcde",
+			Namespace: b.Namespace,
+			Labels:    map[string]string{"job-name": mariadb.BackupJobName(b)},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodSucceeded,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "galera-backup",
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{Message: message},
+				},
+			}},
+		},
+	}
+}
+
+func TestGaleraBackupWaitsForGalera(t *testing.T) {
+	g := NewWithT(t)
+
+	galera := newTestGalera("openstack", 3)
+	backup := newTestGaleraBackup(galera)
+	c := newFakeClient(backup)
+	r := &GaleraBackupReconciler{Client: c, Scheme: c.Scheme()}
+
+	_, err := reconcileN(t, r, backup, 3)
+	g.Expect(err).ToNot(HaveOccurred())
+	cond := get(t, c, backup).Status.Conditions.Get(mariadbv1.MariaDBServerReadyCondition)
+	g.Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(cond.Reason).To(Equal(condition.Reason(mariadbv1.ReasonDBNotFound)))
+
+	// the cluster exists but is not bootstrapped yet
+	g.Expect(c.Create(context.Background(), galera)).To(Succeed())
+	_, err = reconcileN(t, r, backup, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	cond = get(t, c, backup).Status.Conditions.Get(mariadbv1.MariaDBServerReadyCondition)
+	g.Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(cond.Reason).To(Equal(condition.Reason(mariadbv1.ReasonDBWaitingInitialized)))
+	g.Expect(get(t, c, backup).Status.Node).To(BeEmpty())
+}
+
+func TestGaleraBackup(t *testing.T) {
+	g := NewWithT(t)
+
+	galera, objs := newBootstrappedGalera(3)
+	backup := newTestGaleraBackup(galera)
+	c := newFakeClient(append(objs, backup)...)
+	r := &GaleraBackupReconciler{Client: c, Scheme: c.Scheme()}
+	// the first node is not Synced, the backup must be taken from the second one
+	synced := galeraStatusReply("Synced")
+	exec := stubExec(t, func(pod string, cmd string) (string, error) {
+		if pod == "openstack-galera-0" {
+			return galeraStatusReply("Joined")(pod, cmd)
+		}
+		return synced(pod, cmd)
+	})
+
+	_, err := reconcileN(t, r, backup, 3)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	backup = get(t, c, backup)
+	g.Expect(backup.Status.Node).To(Equal("openstack-galera-1"))
+	g.Expect(backup.Status.Path).To(Equal("openstack/backup"))
+	g.Expect(backup.Status.StartTime).ToNot(BeNil())
+	g.Expect(backup.Status.Completed).To(BeFalse())
+	cond := backup.Status.Conditions.Get(mariadbv1.GaleraBackupReadyCondition)
+	g.Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(cond.Message).To(ContainSubstring("in progress on node openstack-galera-1"))
+	g.Expect(exec.ran("openstack-galera-1", "wsrep_desync=ON")).ToNot(BeEmpty())
+	g.Expect(exec.ran("openstack-galera-1", "wsrep_desync=OFF")).To(BeEmpty())
+
+	// the job copies the datadir of the selected node to the backup storage,
+	// without exposing the password on its command line
+	job := getJob(t, c, mariadb.BackupJobName(backup))
+	g.Expect(*job.Spec.BackoffLimit).To(BeZero())
+	claims := []string{}
+	for _, v := range job.Spec.Template.Spec.Volumes {
+		claims = append(claims, v.PersistentVolumeClaim.ClaimName)
+	}
+	g.Expect(claims).To(ConsistOf("mysql-db-openstack-galera-1", "backup-storage"))
+	container := job.Spec.Template.Spec.Containers[0]
+	g.Expect(strings.Join(container.Command, " ")).To(ContainSubstring("BACKUP_DIR=/var/lib/mysql-backup/openstack/backup"))
+	g.Expect(strings.Join(container.Command, " ")).ToNot(ContainSubstring("--password"))
+	g.Expect(container.Env[0].Name).To(Equal("MYSQL_PWD"))
+	g.Expect(container.Env[0].ValueFrom.SecretKeyRef.Name).To(Equal("osp-secret"))
+
+	simulateJobSuccess(t, c, job.Name)
+	g.Expect(c.Create(context.Background(),
+		newBackupJobPod(backup, `{"uuid":"3a0a9e5c-0000-11ef-0000-000000000000","seqno":"42","size":3145729}`))).To(Succeed())
+	_, err = reconcileN(t, r, backup, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	backup = get(t, c, backup)
+	g.Expect(backup.Status.Completed).To(BeTrue())
+	g.Expect(backup.Status.UUID).To(Equal("3a0a9e5c-0000-11ef-0000-000000000000"))
+	g.Expect(backup.Status.Seqno).To(Equal("42"))
+	g.Expect(backup.Status.Size).To(Equal("4Mi"))
+	g.Expect(backup.Status.CompletionTime).ToNot(BeNil())
+	g.Expect(backup.Status.Duration).ToNot(BeNil())
+	g.Expect(backup.Status.Conditions.IsTrue(mariadbv1.GaleraBackupReadyCondition)).To(BeTrue())
+	g.Expect(backup.Status.Conditions.IsTrue(condition.ReadyCondition)).To(BeTrue())
+	g.Expect(exec.ran("openstack-galera-1", "wsrep_desync=OFF")).ToNot(BeEmpty())
+	g.Expect(exec.ran("openstack-galera-0", "wsrep_desync")).To(BeEmpty())
+
+	// a completed backup is never taken again
+	desyncs := len(exec.ran("openstack-galera-1", "wsrep_desync"))
+	_, err = reconcileN(t, r, backup, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(exec.ran("openstack-galera-1", "wsrep_desync")).To(HaveLen(desyncs))
+}
+
+func TestGaleraBackupFailure(t *testing.T) {
+	g := NewWithT(t)
+
+	galera, objs := newBootstrappedGalera(1)
+	backup := newTestGaleraBackup(galera)
+	c := newFakeClient(append(objs, backup)...)
+	r := &GaleraBackupReconciler{Client: c, Scheme: c.Scheme()}
+	exec := stubExec(t, galeraStatusReply("Synced"))
+
+	_, err := reconcileN(t, r, backup, 3)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(exec.ran("openstack-galera-0", "wsrep_desync=ON")).ToNot(BeEmpty())
+
+	job := getJob(t, c, mariadb.BackupJobName(backup))
+	job.Status.Failed = 1
+	g.Expect(c.Status().Update(context.Background(), job)).To(Succeed())
+
+	// a failed backup is not retried, and the node is resynced
+	result, err := reconcileN(t, r, backup, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.Requeue).To(BeFalse())
+	g.Expect(result.RequeueAfter).To(BeZero())
+	backup = get(t, c, backup)
+	g.Expect(backup.Status.Completed).To(BeFalse())
+	cond := backup.Status.Conditions.Get(mariadbv1.GaleraBackupReadyCondition)
+	g.Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(cond.Reason).To(Equal(condition.Reason(condition.ErrorReason)))
+	g.Expect(exec.ran("openstack-galera-0", "wsrep_desync=OFF")).ToNot(BeEmpty())
+}
+
+func TestGaleraBackupUnreadableResult(t *testing.T) {
+	g := NewWithT(t)
+
+	galera, objs := newBootstrappedGalera(1)
+	backup := newTestGaleraBackup(galera)
+	c := newFakeClient(append(objs, backup)...)
+	r := &GaleraBackupReconciler{Client: c, Scheme: c.Scheme()}
+	exec := stubExec(t, galeraStatusReply("Synced"))
+
+	_, err := reconcileN(t, r, backup, 3)
+	g.Expect(err).ToNot(HaveOccurred())
+	simulateJobSuccess(t, c, mariadb.BackupJobName(backup))
+	g.Expect(c.Create(context.Background(), newBackupJobPod(backup, "not json"))).To(Succeed())
+
+	// the node is resynced even though the result of the job can't be read
+	_, err = reconcileN(t, r, backup, 1)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(get(t, c, backup).Status.Completed).To(BeFalse())
+	g.Expect(exec.ran("openstack-galera-0", "wsrep_desync=OFF")).ToNot(BeEmpty())
+}
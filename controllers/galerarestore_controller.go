@@ -0,0 +1,374 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	job "github.com/openstack-k8s-operators/lib-common/modules/common/job"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+)
+
+// GaleraRestoreReconciler reconciles a GaleraRestore object
+type GaleraRestoreReconciler struct {
+	client.Client
+	Kclient kubernetes.Interface
+	Scheme  *runtime.Scheme
+}
+
+// setRestoreAnnotation adds or removes the annotation that keeps a galera cluster stopped during a restore
+func (r *GaleraRestoreReconciler) setRestoreAnnotation(ctx context.Context, galera *mariadbv1.Galera, restoreName string) error {
+	patch := client.MergeFrom(galera.DeepCopy())
+	if restoreName != "" {
+		if galera.Annotations == nil {
+			galera.Annotations = map[string]string{}
+		}
+		galera.Annotations[mariadbv1.GaleraRestoreAnnotation] = restoreName
+	} else {
+		delete(galera.Annotations, mariadbv1.GaleraRestoreAnnotation)
+	}
+	return r.Patch(ctx, galera, patch)
+}
+
+// runRestoreJob runs a job that modifies the datadir of a stopped galera pod
+// and records its hash in the status of the GaleraRestore once it has finished
+func runRestoreJob(ctx context.Context, h *helper.Helper, instance *mariadbv1.GaleraRestore, jobDef *batchv1.Job, hashKey string) (ctrl.Result, error) {
+	restoreJob := job.NewJob(
+		jobDef,
+		hashKey,
+		false,
+		time.Duration(5)*time.Second,
+		instance.Status.Hash[hashKey],
+	)
+	ctrlResult, err := restoreJob.DoJob(ctx, h)
+	if (ctrlResult != ctrl.Result{}) {
+		return ctrlResult, nil
+	}
+	if err != nil {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.GaleraRestoreReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityError,
+			mariadbv1.GaleraRestoreReadyErrorMessage,
+			err.Error()))
+		if restoreJob.HasReachedLimit() {
+			// do not retry, the data on disk needs to be inspected
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if restoreJob.HasChanged() {
+		if instance.Status.Hash == nil {
+			instance.Status.Hash = make(map[string]string)
+		}
+		instance.Status.Hash[hashKey] = restoreJob.GetHash()
+		h.GetLogger().Info(fmt.Sprintf("Job %s hash added - %s", jobDef.Name, instance.Status.Hash[hashKey]))
+	}
+	return ctrl.Result{}, nil
+}
+
+// RBAC for galerarestore resources
+// +kubebuilder:rbac:groups=mariadb.openstack.org,resources=galerarestores,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=mariadb.openstack.org,resources=galerarestores/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=mariadb.openstack.org,resources=galerarestores/finalizers,verbs=update;patch
+// +kubebuilder:rbac:groups=mariadb.openstack.org,resources=galerabackups,verbs=get;list;watch
+
+// Reconcile - GaleraRestore
+func (r *GaleraRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, _err error) {
+	log := GetLog(ctx, "galerarestore")
+
+	// Fetch the GaleraRestore instance
+	instance := &mariadbv1.GaleraRestore{}
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	helper, err := helper.NewHelper(
+		instance,
+		r.Client,
+		r.Kclient,
+		r.Scheme,
+		log,
+	)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// initialize status if Conditions is nil, but do not reset if it already
+	// exists
+	isNewInstance := instance.Status.Conditions == nil
+	if isNewInstance {
+		instance.Status.Conditions = condition.Conditions{}
+	}
+
+	// Save a copy of the condtions so that we can restore the LastTransitionTime
+	// when a condition's state doesn't change.
+	savedConditions := instance.Status.Conditions.DeepCopy()
+
+	// Always patch the instance status when exiting this function so we can
+	// persist any changes.
+	defer func() {
+		condition.RestoreLastTransitionTimes(
+			&instance.Status.Conditions, savedConditions)
+		if instance.Status.Conditions.IsUnknown(condition.ReadyCondition) {
+			instance.Status.Conditions.Set(
+				instance.Status.Conditions.Mirror(condition.ReadyCondition))
+		}
+		err := helper.PatchInstance(ctx, instance)
+		if err != nil {
+			_err = err
+			return
+		}
+	}()
+
+	// initialize conditions used later as Status=Unknown
+	cl := condition.CreateList(
+		condition.UnknownCondition(condition.ReadyCondition, condition.InitReason, condition.ReadyInitMessage),
+		condition.UnknownCondition(mariadbv1.MariaDBServerReadyCondition, condition.InitReason, mariadbv1.MariaDBServerReadyInitMessage),
+		condition.UnknownCondition(mariadbv1.GaleraRestoreReadyCondition, condition.InitReason, mariadbv1.GaleraRestoreReadyInitMessage),
+	)
+
+	instance.Status.Conditions.Init(&cl)
+
+	// If we're not deleting this and the restore object doesn't have our finalizer, add it.
+	if instance.DeletionTimestamp.IsZero() && controllerutil.AddFinalizer(instance, helper.GetFinalizer()) || isNewInstance {
+		return ctrl.Result{}, nil
+	}
+
+	// Handle restore delete
+	if !instance.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, instance, helper)
+	}
+
+	// A restore is only run once, nothing more to do once it completed
+	if instance.Status.Completed {
+		instance.Status.Conditions.MarkTrue(mariadbv1.MariaDBServerReadyCondition, mariadbv1.MariaDBServerReadyMessage)
+		instance.Status.Conditions.MarkTrue(mariadbv1.GaleraRestoreReadyCondition, mariadbv1.GaleraRestoreReadyMessage)
+		instance.Status.Conditions.MarkTrue(condition.ReadyCondition, condition.ReadyMessage)
+		return ctrl.Result{}, nil
+	}
+
+	backup := &mariadbv1.GaleraBackup{}
+	err = r.Get(ctx, types.NamespacedName{Name: instance.Spec.BackupName, Namespace: instance.Namespace}, backup)
+	if err != nil && !k8s_errors.IsNotFound(err) {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.GaleraRestoreReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityWarning,
+			mariadbv1.GaleraRestoreReadyErrorMessage,
+			err.Error()))
+		return ctrl.Result{}, err
+	}
+	if k8s_errors.IsNotFound(err) || !backup.Status.Completed {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.GaleraRestoreReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			mariadbv1.GaleraRestoreBackupNotReadyMessage,
+			instance.Spec.BackupName))
+		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+	}
+
+	galera, err := GetDatabaseObject(ctx, r.Client, instance.Spec.DatabaseInstance, instance.Namespace)
+	if err != nil {
+		if k8s_errors.IsNotFound(err) {
+			instance.Status.Conditions.Set(condition.FalseCondition(
+				mariadbv1.MariaDBServerReadyCondition,
+				mariadbv1.ReasonDBNotFound,
+				condition.SeverityInfo,
+				mariadbv1.MariaDBErrorRetrievingMariaDBGaleraMessage,
+				instance.Spec.DatabaseInstance))
+			return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+		}
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.MariaDBServerReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityError,
+			mariadbv1.MariaDBErrorRetrievingMariaDBGaleraMessage,
+			err))
+		return ctrl.Result{}, err
+	}
+
+	instance.Status.Conditions.MarkTrue(mariadbv1.MariaDBServerReadyCondition, mariadbv1.MariaDBServerReadyMessage)
+
+	if instance.Spec.PointInTime != nil && galera.Spec.BinlogArchive == nil {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.GaleraRestoreReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityError,
+			mariadbv1.GaleraRestoreNoBinlogArchiveMessage,
+			galera.Name))
+		return ctrl.Result{}, nil
+	}
+
+	if !instance.Status.DataRestored {
+		// Only one restore can own the galera cluster at a time
+		owner, found := galera.Annotations[mariadbv1.GaleraRestoreAnnotation]
+		if found && owner != instance.Name {
+			instance.Status.Conditions.Set(condition.FalseCondition(
+				mariadbv1.GaleraRestoreReadyCondition,
+				condition.RequestedReason,
+				condition.SeverityWarning,
+				mariadbv1.GaleraRestoreConflictMessage,
+				galera.Name, owner))
+			return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+		}
+
+		// Request the galera cluster to stop, and to stay stopped
+		// until the restore is finished
+		if !found {
+			log.Info("Requesting galera cluster stop for restore", "galera", galera.Name)
+			if err = r.setRestoreAnnotation(ctx, galera, instance.Name); err != nil {
+				return ctrl.Result{}, err
+			}
+			now := metav1.Now()
+			instance.Status.StartTime = &now
+			// the cluster will be bootstrapped from the first pod
+			instance.Status.Node = mariadb.StatefulSetName(galera.Name) + "-0"
+			instance.Status.UUID = backup.Status.UUID
+			instance.Status.Seqno = backup.Status.Seqno
+		}
+
+		statefulset := &appsv1.StatefulSet{}
+		err = r.Get(ctx, types.NamespacedName{Name: mariadb.StatefulSetName(galera.Name), Namespace: galera.Namespace}, statefulset)
+		if err != nil && !k8s_errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		stopped := err == nil && galera.Status.StopRequired &&
+			*statefulset.Spec.Replicas == 0 && statefulset.Status.Replicas == 0
+		if !stopped {
+			instance.Status.Conditions.Set(condition.FalseCondition(
+				mariadbv1.GaleraRestoreReadyCondition,
+				condition.RequestedReason,
+				condition.SeverityInfo,
+				mariadbv1.GaleraRestoreStoppingMessage))
+			return ctrl.Result{RequeueAfter: time.Duration(5) * time.Second}, nil
+		}
+
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.GaleraRestoreReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			mariadbv1.GaleraRestoreRunningMessage,
+			backup.Name, instance.Status.Node))
+
+		// Restore the backup on the bootstrap node
+		jobDef, err := mariadb.RestoreJob(instance, galera, backup, instance.Status.Node)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		ctrlResult, err := runRestoreJob(ctx, helper, instance, jobDef, mariadbv1.GaleraRestoreHash)
+		if (ctrlResult != ctrl.Result{}) || err != nil || instance.Status.Hash[mariadbv1.GaleraRestoreHash] == "" {
+			return ctrlResult, err
+		}
+
+		// Discard the data of all the other nodes, so they
+		// get the restored data via SST when they rejoin
+		for i := 1; i < int(*galera.Spec.Replicas); i++ {
+			podName := fmt.Sprintf("%s-%d", mariadb.StatefulSetName(galera.Name), i)
+			jobDef, err := mariadb.ResetDataJob(instance, galera, podName)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			hashKey := "reset-" + podName
+			ctrlResult, err := runRestoreJob(ctx, helper, instance, jobDef, hashKey)
+			if (ctrlResult != ctrl.Result{}) || err != nil || instance.Status.Hash[hashKey] == "" {
+				return ctrlResult, err
+			}
+		}
+
+		// Let the galera operator restart the cluster
+		log.Info("Backup restored, restarting galera cluster", "galera", galera.Name)
+		if err = r.setRestoreAnnotation(ctx, galera, ""); err != nil {
+			return ctrl.Result{}, err
+		}
+		instance.Status.DataRestored = true
+	}
+
+	// Wait for the cluster to be bootstrapped from the restored node
+	if !galera.Status.Conditions.IsTrue(condition.DeploymentReadyCondition) || !galera.Status.Bootstrapped {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			mariadbv1.GaleraRestoreReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			mariadbv1.GaleraRestoreRestartingMessage))
+		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+	}
+
+	now := metav1.Now()
+	instance.Status.CompletionTime = &now
+	instance.Status.Completed = true
+	log.Info("Galera restore completed", "galera", galera.Name, "backup", backup.Name)
+
+	instance.Status.Conditions.MarkTrue(mariadbv1.GaleraRestoreReadyCondition, mariadbv1.GaleraRestoreReadyMessage)
+
+	// We reached the end of the Reconcile, update the Ready condition based on
+	// the sub conditions
+	if instance.Status.Conditions.AllSubConditionIsTrue() {
+		instance.Status.Conditions.MarkTrue(
+			condition.ReadyCondition, condition.ReadyMessage)
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *GaleraRestoreReconciler) reconcileDelete(ctx context.Context, instance *mariadbv1.GaleraRestore, helper *helper.Helper) (ctrl.Result, error) {
+	helper.GetLogger().Info("Reconciling GaleraRestore delete")
+
+	// Do not leave the galera cluster stopped if the restore is interrupted
+	galera, err := GetDatabaseObject(ctx, r.Client, instance.Spec.DatabaseInstance, instance.Namespace)
+	if err != nil && !k8s_errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if err == nil && galera.Annotations[mariadbv1.GaleraRestoreAnnotation] == instance.Name {
+		if err = r.setRestoreAnnotation(ctx, galera, ""); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	controllerutil.RemoveFinalizer(instance, helper.GetFinalizer())
+	helper.GetLogger().Info("Reconciled GaleraRestore delete successfully")
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GaleraRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mariadbv1.GaleraRestore{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
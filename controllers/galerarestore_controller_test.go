@@ -0,0 +1,218 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestGaleraRestore(g *mariadbv1.Galera, b *mariadbv1.GaleraBackup) *mariadbv1.GaleraRestore {
+	return &mariadbv1.GaleraRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: g.Namespace},
+		Spec: mariadbv1.GaleraRestoreSpec{
+			DatabaseInstance: g.Name,
+			BackupName:       b.Name,
+		},
+	}
+}
+
+func newCompletedGaleraBackup(g *mariadbv1.Galera) *mariadbv1.GaleraBackup {
+	b := newTestGaleraBackup(g)
+	b.Status.Completed = true
+	b.Status.Node = "openstack-galera-1"
+	b.Status.Path = mariadb.BackupPath(b)
+	b.Status.UUID = "3a0a9e5c-0000-11ef-0000-000000000000"
+	b.Status.Seqno = "42"
+	return b
+}
+
+func jobClaims(t *testing.T, r *GaleraRestoreReconciler, name string) []string {
+	claims := []string{}
+	for _, v := range getJob(t, r.Client, name).Spec.Template.Spec.Volumes {
+		claims = append(claims, v.PersistentVolumeClaim.ClaimName)
+	}
+	return claims
+}
+
+func TestGaleraRestoreWaitsForBackup(t *testing.T) {
+	g := NewWithT(t)
+
+	galera := newTestGalera("openstack", 3)
+	backup := newTestGaleraBackup(galera)
+	restore := newTestGaleraRestore(galera, backup)
+	c := newFakeClient(galera, backup, restore)
+	r := &GaleraRestoreReconciler{Client: c, Scheme: c.Scheme()}
+
+	_, err := reconcileN(t, r, restore, 3)
+	g.Expect(err).ToNot(HaveOccurred())
+	cond := get(t, c, restore).Status.Conditions.Get(mariadbv1.GaleraRestoreReadyCondition)
+	g.Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(cond.Message).To(Equal("GaleraBackup backup is not complete"))
+	// the cluster is left running until the backup can be restored
+	g.Expect(get(t, c, galera).Annotations).ToNot(HaveKey(mariadbv1.GaleraRestoreAnnotation))
+}
+
+func TestGaleraRestoreNeedsBinlogArchive(t *testing.T) {
+	g := NewWithT(t)
+
+	galera := newTestGalera("openstack", 3)
+	backup := newCompletedGaleraBackup(galera)
+	restore := newTestGaleraRestore(galera, backup)
+	restore.Spec.PointInTime = &mariadbv1.GaleraRestorePointInTime{GTID: "0-1-100"}
+	c := newFakeClient(galera, backup, restore)
+	r := &GaleraRestoreReconciler{Client: c, Scheme: c.Scheme()}
+
+	_, err := reconcileN(t, r, restore, 3)
+	g.Expect(err).ToNot(HaveOccurred())
+	cond := get(t, c, restore).Status.Conditions.Get(mariadbv1.GaleraRestoreReadyCondition)
+	g.Expect(cond.Reason).To(Equal(condition.Reason(condition.ErrorReason)))
+	g.Expect(cond.Message).To(ContainSubstring("has no binlog archive"))
+	g.Expect(get(t, c, galera).Annotations).ToNot(HaveKey(mariadbv1.GaleraRestoreAnnotation))
+}
+
+func TestGaleraRestore(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera := newTestGalera("openstack", 3)
+	backup := newCompletedGaleraBackup(galera)
+	restore := newTestGaleraRestore(galera, backup)
+	c := newFakeClient(galera, newTestSecret(), backup, restore)
+	gr := newTestGaleraReconciler(c)
+	r := &GaleraRestoreReconciler{Client: c, Scheme: c.Scheme()}
+	stubExec(t, nil)
+	sts := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "openstack-galera", Namespace: testNamespace}}
+
+	_, err := reconcileN(t, gr, galera, 4)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(*get(t, c, sts).Spec.Replicas).To(BeEquivalentTo(3))
+
+	// the restore requests the cluster to stop
+	_, err = reconcileN(t, r, restore, 3)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(get(t, c, galera).Annotations).To(HaveKeyWithValue(mariadbv1.GaleraRestoreAnnotation, "restore"))
+	restore = get(t, c, restore)
+	g.Expect(restore.Status.Node).To(Equal("openstack-galera-0"))
+	g.Expect(restore.Status.UUID).To(Equal(backup.Status.UUID))
+	g.Expect(restore.Status.Seqno).To(Equal("42"))
+	g.Expect(restore.Status.Conditions.Get(mariadbv1.GaleraRestoreReadyCondition).Message).To(Equal(mariadbv1.GaleraRestoreStoppingMessage))
+
+	// the galera controller stops all the pods and forgets what it
+	// probed from them, the data on disk is about to be replaced
+	galera = get(t, c, galera)
+	galera.Status.Attributes = map[string]mariadbv1.GaleraAttributes{
+		"openstack-galera-0": {Seqno: "100"},
+		"openstack-galera-1": {Seqno: "101", Gcomm: "gcomm://openstack-galera-0"},
+	}
+	g.Expect(c.Status().Update(ctx, galera)).To(Succeed())
+	_, err = reconcileN(t, gr, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	galera = get(t, c, galera)
+	g.Expect(galera.Status.StopRequired).To(BeTrue())
+	g.Expect(galera.Status.Attributes).To(BeEmpty())
+	cond := galera.Status.Conditions.Get(condition.DeploymentReadyCondition)
+	g.Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(cond.Message).To(Equal("Galera cluster stopped for GaleraRestore restore"))
+	g.Expect(*get(t, c, sts).Spec.Replicas).To(BeZero())
+	g.Expect(recordedEvents(gr.Recorder)).To(ContainElement(ContainSubstring("ClusterStopRequired")))
+
+	// the cluster stays stopped for as long as the restore runs
+	_, err = reconcileN(t, gr, galera, 2)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(get(t, c, galera).Status.StopRequired).To(BeTrue())
+
+	// the backup is restored on the bootstrap node
+	_, err = reconcileN(t, r, restore, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	restore = get(t, c, restore)
+	g.Expect(restore.Status.Conditions.Get(mariadbv1.GaleraRestoreReadyCondition).Message).To(
+		Equal("Restoring backup backup on node openstack-galera-0"))
+	g.Expect(jobClaims(t, r, mariadb.RestoreJobName(restore))).To(ConsistOf("mysql-db-openstack-galera-0", "backup-storage"))
+
+	// and the data of the other nodes is discarded, one after the other
+	simulateJobSuccess(t, c, mariadb.RestoreJobName(restore))
+	_, err = reconcileN(t, r, restore, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(jobClaims(t, r, mariadb.ResetDataJobName(restore, "openstack-galera-1"))).To(ConsistOf("mysql-db-openstack-galera-1"))
+	g.Expect(get(t, c, galera).Annotations).To(HaveKey(mariadbv1.GaleraRestoreAnnotation))
+
+	simulateJobSuccess(t, c, mariadb.ResetDataJobName(restore, "openstack-galera-1"))
+	_, err = reconcileN(t, r, restore, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(jobClaims(t, r, mariadb.ResetDataJobName(restore, "openstack-galera-2"))).To(ConsistOf("mysql-db-openstack-galera-2"))
+
+	simulateJobSuccess(t, c, mariadb.ResetDataJobName(restore, "openstack-galera-2"))
+	_, err = reconcileN(t, r, restore, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	restore = get(t, c, restore)
+	g.Expect(restore.Status.DataRestored).To(BeTrue())
+	g.Expect(restore.Status.Completed).To(BeFalse())
+	g.Expect(restore.Status.Conditions.Get(mariadbv1.GaleraRestoreReadyCondition).Message).To(Equal(mariadbv1.GaleraRestoreRestartingMessage))
+	g.Expect(get(t, c, galera).Annotations).ToNot(HaveKey(mariadbv1.GaleraRestoreAnnotation))
+
+	// once the annotation is gone, the galera controller restarts the cluster
+	_, err = reconcileN(t, gr, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(get(t, c, galera).Status.StopRequired).To(BeFalse())
+	g.Expect(recordedEvents(gr.Recorder)).To(ContainElement(ContainSubstring("ClusterStopped")))
+	_, err = reconcileN(t, gr, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(*get(t, c, sts).Spec.Replicas).To(BeEquivalentTo(3))
+
+	// the restore completes when the cluster is bootstrapped again
+	galera = get(t, c, galera)
+	galera.Status.Bootstrapped = true
+	galera.Status.Conditions.MarkTrue(condition.DeploymentReadyCondition, condition.DeploymentReadyMessage)
+	g.Expect(c.Status().Update(ctx, galera)).To(Succeed())
+	_, err = reconcileN(t, r, restore, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	restore = get(t, c, restore)
+	g.Expect(restore.Status.Completed).To(BeTrue())
+	g.Expect(restore.Status.CompletionTime).ToNot(BeNil())
+	g.Expect(restore.Status.Conditions.IsTrue(condition.ReadyCondition)).To(BeTrue())
+}
+
+func TestGaleraRestoreDeleteRestartsCluster(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera := newTestGalera("openstack", 3)
+	backup := newCompletedGaleraBackup(galera)
+	restore := newTestGaleraRestore(galera, backup)
+	c := newFakeClient(galera, backup, restore)
+	r := &GaleraRestoreReconciler{Client: c, Scheme: c.Scheme()}
+
+	_, err := reconcileN(t, r, restore, 3)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(get(t, c, galera).Annotations).To(HaveKey(mariadbv1.GaleraRestoreAnnotation))
+
+	// an interrupted restore does not leave the cluster stopped
+	g.Expect(c.Delete(ctx, get(t, c, restore))).To(Succeed())
+	_, err = reconcileN(t, r, restore, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(get(t, c, galera).Annotations).ToNot(HaveKey(mariadbv1.GaleraRestoreAnnotation))
+}
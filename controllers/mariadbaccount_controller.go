@@ -27,37 +27,100 @@ import (
 	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
 	job "github.com/openstack-k8s-operators/lib-common/modules/common/job"
 	"github.com/openstack-k8s-operators/lib-common/modules/common/secret"
+	util "github.com/openstack-k8s-operators/lib-common/modules/common/util"
 	databasev1beta1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
 	mariadb "github.com/openstack-k8s-operators/mariadb-operator/pkg/mariadb"
+	corev1 "k8s.io/api/core/v1"
 	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // MariaDBAccountReconciler reconciles a MariaDBAccount object
 type MariaDBAccountReconciler struct {
 	client.Client
-	Kclient kubernetes.Interface
-	Log     logr.Logger
-	Scheme  *runtime.Scheme
+	Kclient  kubernetes.Interface
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
+// field to index to reconcile on account secret change
+const accountSecretNameField = ".spec.secret"
+
 // SetupWithManager -
 func (r *MariaDBAccountReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// index the secret name, to reconcile the accounts whose
+	// password changed in their secret
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &databasev1beta1.MariaDBAccount{}, accountSecretNameField, func(rawObj client.Object) []string {
+		cr := rawObj.(*databasev1beta1.MariaDBAccount)
+		if cr.Spec.Secret != "" {
+			return []string{cr.Spec.Secret}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&databasev1beta1.MariaDBAccount{}).
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.findObjectsForSecret),
+			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}),
+		).
 		Complete(r)
 }
 
+// findObjectsForSecret - returns a reconcile request for every MariaDBAccount using the secret
+func (r *MariaDBAccountReconciler) findObjectsForSecret(ctx context.Context, src client.Object) []reconcile.Request {
+	requests := []reconcile.Request{}
+
+	l := log.FromContext(context.Background()).WithName("Controllers").WithName("MariaDBAccount")
+
+	crList := &databasev1beta1.MariaDBAccountList{}
+	listOps := &client.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector(accountSecretNameField, src.GetName()),
+		Namespace:     src.GetNamespace(),
+	}
+	err := r.List(ctx, crList, listOps)
+	if err != nil {
+		l.Error(err, fmt.Sprintf("listing %s for field: %s - %s", crList.GroupVersionKind().Kind, accountSecretNameField, src.GetNamespace()))
+		return requests
+	}
+
+	for _, item := range crList.Items {
+		l.Info(fmt.Sprintf("input source %s changed, reconcile: %s - %s", src.GetName(), item.GetName(), item.GetNamespace()))
+
+		requests = append(requests,
+			reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      item.GetName(),
+					Namespace: item.GetNamespace(),
+				},
+			},
+		)
+	}
+
+	return requests
+}
+
 //+kubebuilder:rbac:groups=mariadb.openstack.org,resources=mariadbaccounts,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=mariadb.openstack.org,resources=mariadbaccounts/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=mariadb.openstack.org,resources=mariadbaccounts/finalizers,verbs=update;patch
-//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;create;update;delete;patch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;delete;patch
 
 // Reconcile
 func (r *MariaDBAccountReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, _err error) {
@@ -120,7 +183,7 @@ func (r *MariaDBAccountReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	instance.Status.Conditions.Init(&cl)
 
 	if instance.DeletionTimestamp.IsZero() || isNewInstance { //revive:disable:indent-error-flow
-		return r.reconcileCreate(ctx, log, helper, instance)
+		return r.reconcileCreate(ctx, log, helper, instance, savedConditions)
 	} else {
 		return r.reconcileDelete(ctx, log, helper, instance)
 	}
@@ -130,7 +193,8 @@ func (r *MariaDBAccountReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 // reconcileDelete - run reconcile for case where delete timestamp is zero
 func (r *MariaDBAccountReconciler) reconcileCreate(
 	ctx context.Context, log logr.Logger,
-	helper *helper.Helper, instance *databasev1beta1.MariaDBAccount) (result ctrl.Result, _err error) {
+	helper *helper.Helper, instance *databasev1beta1.MariaDBAccount,
+	savedConditions condition.Conditions) (result ctrl.Result, _err error) {
 
 	// this is following from how the MariaDBDatabase CRD works.
 	// the related Galera / MariaDB object is given as a label, while
@@ -241,6 +305,20 @@ func (r *MariaDBAccountReconciler) reconcileCreate(
 
 	if !dbGalera.Status.Bootstrapped {
 		log.Info("DB bootstrap not complete. Requeue...")
+		// the conditions are reset on every reconcile, only report the
+		// wait when it starts
+		if !savedConditions.IsFalse(databasev1beta1.MariaDBServerReadyCondition) {
+			r.Recorder.Eventf(instance, corev1.EventTypeNormal, "WaitingForGalera",
+				"Galera cluster %s is not bootstrapped yet", dbGalera.Name)
+		}
+
+		instance.Status.Conditions.MarkFalse(
+			databasev1beta1.MariaDBServerReadyCondition,
+			databasev1beta1.ReasonDBWaitingInitialized,
+			condition.SeverityInfo,
+			databasev1beta1.MariaDBServerNotBootstrappedMessage,
+		)
+
 		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
 	}
 
@@ -262,7 +340,7 @@ func (r *MariaDBAccountReconciler) reconcileCreate(
 	// account create
 
 	// ensure secret is present before running a job
-	_, secretResult, err := secret.VerifySecret(
+	passwordHash, secretResult, err := secret.VerifySecret(
 		ctx,
 		types.NamespacedName{Name: instance.Spec.Secret, Namespace: instance.Namespace},
 		[]string{databasev1beta1.DatabasePasswordSelector},
@@ -280,9 +358,22 @@ func (r *MariaDBAccountReconciler) reconcileCreate(
 		return secretResult, err
 	}
 
+	// when the password in the secret changed since it was last set on the
+	// account, the previous password optionally stays valid for a grace period
+	if appliedHash, found := instance.Status.Hash[databasev1beta1.AccountPasswordHash]; found &&
+		appliedHash != passwordHash &&
+		instance.Spec.RotationGracePeriod != nil &&
+		instance.Status.PreviousPasswordExpiry == nil {
+		expiry := metav1.NewTime(time.Now().Add(instance.Spec.RotationGracePeriod.Duration))
+		instance.Status.PreviousPasswordExpiry = &expiry
+		log.Info(fmt.Sprintf("Password of account '%s' changed, previous password accepted until %s", instance.Name, expiry))
+	}
+	keepPreviousPassword := instance.Status.PreviousPasswordExpiry != nil &&
+		time.Now().Before(instance.Status.PreviousPasswordExpiry.Time)
+
 	log.Info(fmt.Sprintf("Running account create '%s' MariaDBDatabase '%s'", instance.Name, mariadbDatabaseName))
 
-	jobDef, err := mariadb.CreateDbAccountJob(instance, mariadbDatabase.Spec.Name, dbHostname, dbAdminSecret, dbContainerImage, serviceAccountName, dbGalera.Spec.NodeSelector)
+	jobDef, err := mariadb.CreateDbAccountJob(instance, mariadbDatabase.Spec.Name, dbHostname, dbAdminSecret, dbContainerImage, serviceAccountName, dbGalera.Spec.NodeSelector, passwordHash, keepPreviousPassword)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
@@ -299,27 +390,45 @@ func (r *MariaDBAccountReconciler) reconcileCreate(
 		ctx,
 		helper,
 	)
+	recordJobResult(ctx, helper, accountCreateJob, jobDef, "account-create", ctrlResult, err)
 	if (ctrlResult != ctrl.Result{}) {
 		// TODO: should this be ctrlResult, err ?
 		return ctrlResult, nil
 	}
 	if err != nil {
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, "JobFailed",
+			"Account creation job %s failed: %s", jobDef.Name, err.Error())
 		return ctrl.Result{}, err
 	}
 	if accountCreateJob.HasChanged() {
+		r.Recorder.Eventf(instance, corev1.EventTypeNormal, "AccountUpdated",
+			"Account %s configured in database %s", instance.Spec.UserName, mariadbDatabase.Spec.Name)
 		if instance.Status.Hash == nil {
 			instance.Status.Hash = make(map[string]string)
 		}
 		instance.Status.Hash[databasev1beta1.AccountCreateHash] = accountCreateJob.GetHash()
 		log.Info(fmt.Sprintf("Job %s hash added - %s", jobDef.Name, instance.Status.Hash[databasev1beta1.AccountCreateHash]))
 	}
+	instance.Status.Hash, _ = util.SetHash(instance.Status.Hash, databasev1beta1.AccountPasswordHash, passwordHash)
 
 	// database creation finished
 
-	instance.Status.Conditions.MarkTrue(
-		databasev1beta1.MariaDBAccountReadyCondition,
-		databasev1beta1.MariaDBAccountReadyMessage,
-	)
+	if keepPreviousPassword {
+		// run the job again once the grace period expired, to only
+		// keep the new password on the account
+		instance.Status.Conditions.MarkTrue(
+			databasev1beta1.MariaDBAccountReadyCondition,
+			databasev1beta1.MariaDBAccountPreviousPasswordValidMessage,
+			instance.Status.PreviousPasswordExpiry.Format(time.RFC3339),
+		)
+		result = ctrl.Result{RequeueAfter: time.Until(instance.Status.PreviousPasswordExpiry.Time)}
+	} else {
+		instance.Status.PreviousPasswordExpiry = nil
+		instance.Status.Conditions.MarkTrue(
+			databasev1beta1.MariaDBAccountReadyCondition,
+			databasev1beta1.MariaDBAccountReadyMessage,
+		)
+	}
 
 	// We reached the end of the Reconcile, update the Ready condition based on
 	// the sub conditions
@@ -327,7 +436,7 @@ func (r *MariaDBAccountReconciler) reconcileCreate(
 		instance.Status.Conditions.MarkTrue(
 			condition.ReadyCondition, condition.ReadyMessage)
 	}
-	return ctrl.Result{}, nil
+	return result, nil
 }
 
 // reconcileDelete - run reconcile for case where delete timestamp is non zero
@@ -524,14 +633,19 @@ func (r *MariaDBAccountReconciler) reconcileDelete(
 		ctx,
 		helper,
 	)
+	recordJobResult(ctx, helper, accountDeleteJob, jobDef, "account-delete", ctrlResult, err)
 	if (ctrlResult != ctrl.Result{}) {
 		// TODO: should this be ctrlResult, err ?
 		return ctrlResult, nil
 	}
 	if err != nil {
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, "JobFailed",
+			"Account deletion job %s failed: %s", jobDef.Name, err.Error())
 		return ctrl.Result{}, err
 	}
 	if accountDeleteJob.HasChanged() {
+		r.Recorder.Eventf(instance, corev1.EventTypeNormal, "AccountDeleted",
+			"Account %s deleted from database %s", instance.Spec.UserName, mariadbDatabase.Spec.Name)
 		if instance.Status.Hash == nil {
 			instance.Status.Hash = make(map[string]string)
 		}
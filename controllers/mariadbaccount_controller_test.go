@@ -0,0 +1,247 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// newTestAccountReconciler returns a MariaDBAccount reconciler for an
+// account of the database "nova" hosted on a bootstrapped galera CR
+func newTestAccountReconciler(t *testing.T, account *mariadbv1.MariaDBAccount, password string) *MariaDBAccountReconciler {
+	galera := newTestGalera("openstack", 3)
+	galera.Status.Bootstrapped = true
+	database := &mariadbv1.MariaDBDatabase{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nova",
+			Namespace: testNamespace,
+			Labels:    map[string]string{"dbName": galera.Name},
+		},
+		Spec: mariadbv1.MariaDBDatabaseSpec{Name: "nova"},
+	}
+	database.Status.Conditions.MarkTrue(mariadbv1.MariaDBDatabaseReadyCondition, mariadbv1.MariaDBDatabaseReadyMessage)
+	accountSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: account.Spec.Secret, Namespace: testNamespace},
+		Data:       map[string][]byte{mariadbv1.DatabasePasswordSelector: []byte(password)},
+	}
+	// the hostname of the galera service is looked up from its labels
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      galera.Name,
+			Namespace: testNamespace,
+			Labels:    map[string]string{"app": "mariadb", "cr": "mariadb-" + galera.Name},
+		},
+	}
+
+	c := newFakeClient(galera, newTestSecret(), database, accountSecret, account)
+	t.Setenv("OPERATOR_TEMPLATES", "../templates")
+	return &MariaDBAccountReconciler{
+		Client:   c,
+		Kclient:  kfake.NewSimpleClientset(svc),
+		Log:      ctrl.Log.WithName("test"),
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(100),
+	}
+}
+
+func newTestAccount() *mariadbv1.MariaDBAccount {
+	return &mariadbv1.MariaDBAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nova",
+			Namespace: testNamespace,
+			Labels:    map[string]string{"mariaDBDatabaseName": "nova"},
+		},
+		Spec: mariadbv1.MariaDBAccountSpec{
+			UserName: "nova",
+			Secret:   "nova-db-secret",
+		},
+	}
+}
+
+// runAccountJob reconciles an account until its job has run successfully,
+// and returns the script run by the job
+func runAccountJob(t *testing.T, r *MariaDBAccountReconciler, account *mariadbv1.MariaDBAccount) (ctrl.Result, string) {
+	g := NewWithT(t)
+	// an outdated job is deleted before the new one gets created
+	for i := 0; i < 8; i++ {
+		_, err := reconcileN(t, r, account, 1)
+		g.Expect(err).ToNot(HaveOccurred())
+		job := &batchv1.Job{}
+		err = r.Get(context.Background(), types.NamespacedName{Name: "nova-account-create", Namespace: testNamespace}, job)
+		if err == nil && job.Status.Succeeded == 0 {
+			script := job.Spec.Template.Spec.Containers[0].Command[2]
+			simulateJobSuccess(t, r.Client, job.Name)
+			result, err := reconcileN(t, r, account, 1)
+			g.Expect(err).ToNot(HaveOccurred())
+			return result, script
+		}
+	}
+	t.Fatal("account job not run")
+	return ctrl.Result{}, ""
+}
+
+func TestMariaDBAccountPasswordGracePeriod(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	account := newTestAccount()
+	account.Spec.RotationGracePeriod = &metav1.Duration{Duration: time.Hour}
+	r := newTestAccountReconciler(t, account, "first")
+
+	_, script := runAccountJob(t, r, account)
+	g.Expect(script).ToNot(ContainSubstring("authentication_string"))
+	account = get(t, r.Client, account)
+	g.Expect(account.Status.Conditions.IsTrue(condition.ReadyCondition)).To(BeTrue())
+	g.Expect(account.Status.PreviousPasswordExpiry).To(BeNil())
+
+	// on a password change, the previous password stays valid and
+	// the account is reconciled again at the end of the grace period
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "nova-db-secret", Namespace: testNamespace}}
+	secret = get(t, r.Client, secret)
+	secret.Data[mariadbv1.DatabasePasswordSelector] = []byte("second")
+	g.Expect(r.Update(ctx, secret)).To(Succeed())
+
+	result, script := runAccountJob(t, r, account)
+	g.Expect(script).To(ContainSubstring("authentication_string"))
+	account = get(t, r.Client, account)
+	g.Expect(account.Status.PreviousPasswordExpiry).ToNot(BeNil())
+	g.Expect(account.Status.PreviousPasswordExpiry.Time).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+	g.Expect(result.RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute))
+	cond := account.Status.Conditions.Get(mariadbv1.MariaDBAccountReadyCondition)
+	g.Expect(cond.Status).To(Equal(corev1.ConditionTrue))
+	g.Expect(cond.Message).To(HavePrefix("MariaDBAccount password rotated, previous password still accepted until"))
+
+	// once expired, only the new password is kept
+	expired := metav1.NewTime(time.Now().Add(-time.Second))
+	account.Status.PreviousPasswordExpiry = &expired
+	g.Expect(r.Status().Update(ctx, account)).To(Succeed())
+
+	result, script = runAccountJob(t, r, account)
+	g.Expect(script).ToNot(ContainSubstring("authentication_string"))
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	account = get(t, r.Client, account)
+	g.Expect(account.Status.PreviousPasswordExpiry).To(BeNil())
+	g.Expect(account.Status.Conditions.Get(mariadbv1.MariaDBAccountReadyCondition).Message).To(Equal(mariadbv1.MariaDBAccountReadyMessage))
+}
+
+func TestMariaDBAccountPasswordRotatedTwice(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	account := newTestAccount()
+	account.Spec.RotationGracePeriod = &metav1.Duration{Duration: time.Hour}
+	r := newTestAccountReconciler(t, account, "first")
+	runAccountJob(t, r, account)
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "nova-db-secret", Namespace: testNamespace}}
+	for _, password := range []string{"second", "third"} {
+		secret = get(t, r.Client, secret)
+		secret.Data[mariadbv1.DatabasePasswordSelector] = []byte(password)
+		g.Expect(r.Update(ctx, secret)).To(Succeed())
+
+		// within the grace period, the account accepts two passwords and the
+		// one set last is the first alternative of the account. It is the one
+		// kept on the next rotation, not the password the account started with
+		_, script := runAccountJob(t, r, account)
+		g.Expect(script).To(ContainSubstring(
+			"coalesce(json_value(priv, '$.auth_or[0].authentication_string'), json_value(priv, '$.authentication_string'))"))
+		g.Expect(script).To(ContainSubstring("USING PASSWORD('$DatabasePassword') OR mysql_native_password USING '${previous}'"))
+		account = get(t, r.Client, account)
+		g.Expect(account.Status.PreviousPasswordExpiry).ToNot(BeNil())
+		g.Expect(account.Status.Conditions.IsTrue(mariadbv1.MariaDBAccountReadyCondition)).To(BeTrue())
+	}
+}
+
+func TestMariaDBAccountPasswordChangeWithoutGracePeriod(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	account := newTestAccount()
+	r := newTestAccountReconciler(t, account, "first")
+	runAccountJob(t, r, account)
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "nova-db-secret", Namespace: testNamespace}}
+	secret = get(t, r.Client, secret)
+	secret.Data[mariadbv1.DatabasePasswordSelector] = []byte("second")
+	g.Expect(r.Update(ctx, secret)).To(Succeed())
+
+	// the new password replaces the previous one right away
+	result, script := runAccountJob(t, r, account)
+	g.Expect(script).ToNot(ContainSubstring("authentication_string"))
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	g.Expect(get(t, r.Client, account).Status.PreviousPasswordExpiry).To(BeNil())
+}
+
+func TestMariaDBAccountEvents(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	account := newTestAccount()
+	r := newTestAccountReconciler(t, account, "password")
+	galera := get(t, r.Client, newTestGalera("openstack", 3))
+	galera.Status.Bootstrapped = false
+	g.Expect(r.Status().Update(ctx, galera)).To(Succeed())
+
+	// the wait for the galera cluster is only reported once
+	_, err := reconcileN(t, r, account, 5)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(recordedEvents(r.Recorder)).To(ConsistOf(
+		"Normal WaitingForGalera Galera cluster openstack is not bootstrapped yet"))
+
+	galera = get(t, r.Client, galera)
+	galera.Status.Bootstrapped = true
+	g.Expect(r.Status().Update(ctx, galera)).To(Succeed())
+	job := &batchv1.Job{}
+	for i := 0; i < 8; i++ {
+		_, err = reconcileN(t, r, account, 1)
+		g.Expect(err).ToNot(HaveOccurred())
+		if r.Get(ctx, types.NamespacedName{Name: "nova-account-create", Namespace: testNamespace}, job) == nil {
+			break
+		}
+	}
+	g.Expect(job.Name).To(Equal("nova-account-create"))
+	g.Expect(recordedEvents(r.Recorder)).To(BeEmpty())
+
+	// a failed job is reported every time it is retried
+	job.Status.Failed = 1
+	g.Expect(r.Status().Update(ctx, job)).To(Succeed())
+	_, err = reconcileN(t, r, account, 1)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(recordedEvents(r.Recorder)).To(ConsistOf(
+		HavePrefix("Warning JobFailed Account creation job nova-account-create failed")))
+
+	// and the configuration of the account once
+	simulateJobSuccess(t, r.Client, job.Name)
+	_, err = reconcileN(t, r, account, 2)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(recordedEvents(r.Recorder)).To(ConsistOf(
+		"Normal AccountUpdated Account nova configured in database nova"))
+}
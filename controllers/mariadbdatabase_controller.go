@@ -21,9 +21,11 @@ import (
 	"fmt"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -38,8 +40,9 @@ import (
 // MariaDBDatabaseReconciler reconciles a MariaDBDatabase object
 type MariaDBDatabaseReconciler struct {
 	client.Client
-	Kclient kubernetes.Interface
-	Scheme  *runtime.Scheme
+	Kclient  kubernetes.Interface
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=mariadb.openstack.org,resources=mariadbdatabases,verbs=get;list;watch;create;update;patch;delete
@@ -168,6 +171,12 @@ func (r *MariaDBDatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 
 	if !dbGalera.Status.Bootstrapped {
 		log.Info("DB bootstrap not complete. Requeue...")
+		// the conditions are reset on every reconcile, only report the
+		// wait when it starts
+		if !savedConditions.IsFalse(databasev1beta1.MariaDBServerReadyCondition) {
+			r.Recorder.Eventf(instance, corev1.EventTypeNormal, "WaitingForGalera",
+				"Galera cluster %s is not bootstrapped yet", dbGalera.Name)
+		}
 
 		instance.Status.Conditions.MarkFalse(
 			databasev1beta1.MariaDBServerReadyCondition,
@@ -212,13 +221,18 @@ func (r *MariaDBDatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		ctx,
 		helper,
 	)
+	recordJobResult(ctx, helper, dbCreateJob, jobDef, "database-create", ctrlResult, err)
 	if (ctrlResult != ctrl.Result{}) {
 		return ctrlResult, nil
 	}
 	if err != nil {
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, "JobFailed",
+			"Database creation job %s failed: %s", jobDef.Name, err.Error())
 		return ctrl.Result{}, err
 	}
 	if dbCreateJob.HasChanged() {
+		r.Recorder.Eventf(instance, corev1.EventTypeNormal, "DatabaseCreated",
+			"Database %s created in galera cluster %s", instance.Spec.Name, dbGalera.Name)
 		if instance.Status.Hash == nil {
 			instance.Status.Hash = make(map[string]string)
 		}
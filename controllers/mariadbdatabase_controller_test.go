@@ -0,0 +1,102 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newTestDatabaseReconciler returns a MariaDBDatabase reconciler for the
+// database "nova" of a galera CR that is not bootstrapped yet
+func newTestDatabaseReconciler(t *testing.T) (*MariaDBDatabaseReconciler, *mariadbv1.Galera, *mariadbv1.MariaDBDatabase) {
+	galera := newTestGalera("openstack", 3)
+	database := &mariadbv1.MariaDBDatabase{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nova",
+			Namespace: testNamespace,
+			Labels:    map[string]string{"dbName": galera.Name},
+		},
+		Spec: mariadbv1.MariaDBDatabaseSpec{Name: "nova"},
+	}
+	// the hostname of the galera service is looked up from its labels
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      galera.Name,
+			Namespace: testNamespace,
+			Labels:    map[string]string{"app": "mariadb", "cr": "mariadb-" + galera.Name},
+		},
+	}
+
+	c := newFakeClient(galera, newTestSecret(), database)
+	return &MariaDBDatabaseReconciler{
+		Client:   c,
+		Kclient:  kfake.NewSimpleClientset(svc),
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(100),
+	}, galera, database
+}
+
+func TestMariaDBDatabaseEvents(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	r, galera, database := newTestDatabaseReconciler(t)
+
+	// the wait for the galera cluster is only reported once
+	_, err := reconcileN(t, r, database, 5)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(recordedEvents(r.Recorder)).To(ConsistOf(
+		"Normal WaitingForGalera Galera cluster openstack is not bootstrapped yet"))
+
+	galera = get(t, r.Client, galera)
+	galera.Status.Bootstrapped = true
+	g.Expect(r.Status().Update(ctx, galera)).To(Succeed())
+	_, err = reconcileN(t, r, database, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	job := getJob(t, r.Client, "nova-db-create")
+	g.Expect(recordedEvents(r.Recorder)).To(BeEmpty())
+
+	// a failed job is reported every time it is retried
+	job.Status.Failed = 1
+	g.Expect(r.Status().Update(ctx, job)).To(Succeed())
+	_, err = reconcileN(t, r, database, 1)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(recordedEvents(r.Recorder)).To(ConsistOf(
+		HavePrefix("Warning JobFailed Database creation job nova-db-create failed")))
+
+	// and the creation of the database once
+	job = get(t, r.Client, &batchv1.Job{ObjectMeta: job.ObjectMeta})
+	job.Status.Succeeded = 1
+	g.Expect(r.Status().Update(ctx, job)).To(Succeed())
+	_, err = reconcileN(t, r, database, 2)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(recordedEvents(r.Recorder)).To(ConsistOf(
+		"Normal DatabaseCreated Database nova created in galera cluster openstack"))
+	g.Expect(get(t, r.Client, database).Status.Completed).To(BeTrue())
+	g.Expect(r.Get(ctx, client.ObjectKeyFromObject(job), job)).To(Succeed())
+}
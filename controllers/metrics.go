@@ -0,0 +1,145 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	job "github.com/openstack-k8s-operators/lib-common/modules/common/job"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	"github.com/prometheus/client_golang/prometheus"
+	batchv1 "k8s.io/api/batch/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Operator metrics, exposed on the metrics endpoint of the manager
+// alongside the generic controller-runtime metrics
+var (
+	galeraBootstrapAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mariadb_galera_bootstrap_attempts_total",
+			Help: "Number of times a galera node was instructed to bootstrap a new cluster",
+		},
+		[]string{"namespace", "galera"},
+	)
+
+	galeraBootstrapDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "mariadb_galera_bootstrap_duration_seconds",
+			Help:    "Time between the first inspection of the nodes of a stopped galera cluster and the cluster running again",
+			Buckets: []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+		},
+		[]string{"namespace", "galera"},
+	)
+
+	galeraBootstrapped = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mariadb_galera_bootstrapped",
+			Help: "Whether the galera cluster is running (1) or waiting to be bootstrapped (0)",
+		},
+		[]string{"namespace", "galera"},
+	)
+
+	galeraGcommInjectionFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mariadb_galera_gcomm_injection_failures_total",
+			Help: "Number of failures to push a gcomm URI to a galera node",
+		},
+		[]string{"namespace", "galera"},
+	)
+
+	galeraClusterStops = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mariadb_galera_cluster_stops_total",
+			Help: "Number of times all the nodes of a galera cluster were stopped",
+		},
+		[]string{"namespace", "galera", "reason"},
+	)
+
+	galeraSeqnoErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mariadb_galera_seqno_retrieval_errors_total",
+			Help: "Number of failures to retrieve the seqno of a galera node",
+		},
+		[]string{"namespace", "galera"},
+	)
+
+	jobDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "mariadb_job_duration_seconds",
+			Help:    "Duration of the successful database and account jobs",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+		},
+		[]string{"namespace", "type"},
+	)
+
+	jobFailedAttempts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mariadb_job_failed_attempts",
+			Help: "Number of failed attempts of the last run of a database or account job",
+		},
+		[]string{"namespace", "type", "job"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		galeraBootstrapAttempts,
+		galeraBootstrapDuration,
+		galeraBootstrapped,
+		galeraGcommInjectionFailures,
+		galeraClusterStops,
+		galeraSeqnoErrors,
+		jobDuration,
+		jobFailedAttempts,
+	)
+}
+
+// recordJobResult updates the metrics of a database or account job after a
+// call to DoJob. The duration is recorded once, when the job is seen finished
+func recordJobResult(ctx context.Context, h *helper.Helper, j *job.Job, jobDef *batchv1.Job, jobType string, ctrlResult ctrl.Result, err error) {
+	if err != nil || (ctrlResult != ctrl.Result{}) {
+		// the job is still running or has reached its backoff limit
+		if failed := j.GetTotalFailedAttempts(); failed > 0 {
+			jobFailedAttempts.WithLabelValues(jobDef.Namespace, jobType, jobDef.Name).Set(float64(failed))
+		}
+		return
+	}
+	jobFailedAttempts.DeleteLabelValues(jobDef.Namespace, jobType, jobDef.Name)
+	if !j.HasChanged() {
+		return
+	}
+	actual, getErr := job.GetJobWithName(ctx, h, jobDef.Name, jobDef.Namespace)
+	if getErr != nil || actual.Status.StartTime == nil || actual.Status.CompletionTime == nil {
+		return
+	}
+	jobDuration.WithLabelValues(jobDef.Namespace, jobType).Observe(
+		actual.Status.CompletionTime.Sub(actual.Status.StartTime.Time).Seconds())
+}
+
+// deleteGaleraMetrics removes the metrics series of a deleted galera CR
+func deleteGaleraMetrics(instance *mariadbv1.Galera) {
+	l := prometheus.Labels{"namespace": instance.Namespace, "galera": instance.Name}
+	galeraBootstrapAttempts.DeletePartialMatch(l)
+	galeraBootstrapDuration.DeletePartialMatch(l)
+	galeraBootstrapped.DeletePartialMatch(l)
+	galeraGcommInjectionFailures.DeletePartialMatch(l)
+	galeraClusterStops.DeletePartialMatch(l)
+	galeraSeqnoErrors.DeletePartialMatch(l)
+}
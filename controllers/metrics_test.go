@@ -0,0 +1,189 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The metrics are global, so every test uses its own galera CR name
+
+// histogramCount returns the number of observations of a histogram series
+func histogramCount(t *testing.T, h *prometheus.HistogramVec, labels ...string) uint64 {
+	m := &dto.Metric{}
+	if err := h.WithLabelValues(labels...).(prometheus.Histogram).Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+// newStoppedGalera returns a galera CR whose pods all wait for a gcomm URI
+func newStoppedGalera(name string) (*mariadbv1.Galera, []client.Object) {
+	galera := newTestGalera(name, 3)
+	objs := []client.Object{galera, newTestSecret()}
+	for i := 0; i < 3; i++ {
+		objs = append(objs, newTestGaleraPod(galera, i, false))
+	}
+	return galera, objs
+}
+
+func TestGaleraBootstrapMetrics(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	galera, objs := newStoppedGalera("metrics-bootstrap")
+	c := newFakeClient(objs...)
+	r := newTestGaleraReconciler(c)
+	stubExec(t, waitingPodReply(map[string]string{
+		"metrics-bootstrap-galera-0": "10", "metrics-bootstrap-galera-1": "12", "metrics-bootstrap-galera-2": "11",
+	}))
+
+	_, err := reconcileN(t, r, galera, 4)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(get(t, c, galera).Status.Attributes["metrics-bootstrap-galera-1"].Gcomm).To(Equal("gcomm://"))
+	g.Expect(testutil.ToFloat64(galeraBootstrapAttempts.WithLabelValues(testNamespace, "metrics-bootstrap"))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(galeraBootstrapped.WithLabelValues(testNamespace, "metrics-bootstrap"))).To(Equal(0.0))
+	g.Expect(histogramCount(t, galeraBootstrapDuration, testNamespace, "metrics-bootstrap")).To(BeZero())
+
+	// the duration of the bootstrap is recorded once the cluster runs
+	galera = get(t, c, galera)
+	g.Expect(galera.Status.InspectionStartTime).ToNot(BeNil())
+	simulateStatefulSetAvailable(t, c, galera)
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(testutil.ToFloat64(galeraBootstrapped.WithLabelValues(testNamespace, "metrics-bootstrap"))).To(Equal(1.0))
+	g.Expect(histogramCount(t, galeraBootstrapDuration, testNamespace, "metrics-bootstrap")).To(BeEquivalentTo(1))
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(histogramCount(t, galeraBootstrapDuration, testNamespace, "metrics-bootstrap")).To(BeEquivalentTo(1))
+
+	// the series of a deleted galera CR are removed
+	g.Expect(c.Delete(ctx, get(t, c, galera))).To(Succeed())
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(galeraBootstrapAttempts.DeleteLabelValues(testNamespace, "metrics-bootstrap")).To(BeFalse())
+	g.Expect(galeraBootstrapped.DeleteLabelValues(testNamespace, "metrics-bootstrap")).To(BeFalse())
+}
+
+func TestGaleraFailureMetrics(t *testing.T) {
+	g := NewWithT(t)
+
+	galera, objs := newStoppedGalera("metrics-failures")
+	c := newFakeClient(objs...)
+	r := newTestGaleraReconciler(c)
+	// the first pod can't be inspected, and the bootstrap node can't be started
+	waiting := waitingPodReply(map[string]string{
+		"metrics-failures-galera-0": "10", "metrics-failures-galera-1": "12", "metrics-failures-galera-2": "11",
+	})
+	stubExec(t, func(pod string, cmd string) (string, error) {
+		if pod == "metrics-failures-galera-0" && strings.Contains(cmd, "detect_last_commit.sh") {
+			return "", errors.New("command terminated with exit code 1")
+		}
+		if strings.Contains(cmd, "echo 'gcomm://'") {
+			return "", errors.New("container not found")
+		}
+		return waiting(pod, cmd)
+	})
+
+	_, err := reconcileN(t, r, galera, 4)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(testutil.ToFloat64(galeraSeqnoErrors.WithLabelValues(testNamespace, "metrics-failures"))).To(BeNumerically(">=", 1))
+	g.Expect(testutil.ToFloat64(galeraGcommInjectionFailures.WithLabelValues(testNamespace, "metrics-failures"))).To(BeZero())
+
+	// once the seqno of all the pods is known, the bootstrap is attempted
+	stubExec(t, func(pod string, cmd string) (string, error) {
+		if strings.Contains(cmd, "echo 'gcomm://'") {
+			return "", errors.New("container not found")
+		}
+		return waiting(pod, cmd)
+	})
+	_, err = reconcileN(t, r, galera, 1)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(testutil.ToFloat64(galeraGcommInjectionFailures.WithLabelValues(testNamespace, "metrics-failures"))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(galeraBootstrapAttempts.WithLabelValues(testNamespace, "metrics-failures"))).To(BeZero())
+}
+
+func TestGaleraClusterStopMetrics(t *testing.T) {
+	g := NewWithT(t)
+
+	galera := newTestGalera("metrics-stop", 3)
+	galera.Annotations = map[string]string{mariadbv1.GaleraRestoreAnnotation: "restore"}
+	c := newFakeClient(galera, newTestSecret())
+	r := newTestGaleraReconciler(c)
+	stubExec(t, nil)
+
+	_, err := reconcileN(t, r, galera, 6)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(get(t, c, galera).Status.StopRequired).To(BeTrue())
+	// a stop is only counted once, when requested
+	g.Expect(testutil.ToFloat64(galeraClusterStops.WithLabelValues(testNamespace, "metrics-stop", "Restore"))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(galeraClusterStops.WithLabelValues(testNamespace, "metrics-stop", "ClusterProperties"))).To(BeZero())
+}
+
+func TestJobMetrics(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	account := newTestAccount()
+	r := newTestAccountReconciler(t, account, "password")
+	name := "nova-account-create"
+	durations := histogramCount(t, jobDuration, testNamespace, "account-create")
+
+	// the job is created once the account is set up
+	j := &batchv1.Job{}
+	for i := 0; i < 8; i++ {
+		_, err := reconcileN(t, r, account, 1)
+		g.Expect(err).ToNot(HaveOccurred())
+		if r.Get(ctx, client.ObjectKey{Name: name, Namespace: testNamespace}, j) == nil {
+			break
+		}
+	}
+	g.Expect(j.Name).To(Equal(name))
+
+	// the failed attempts of a running job are reported, DoJob
+	// returns them as an error until the job succeeds
+	j.Status.Failed = 2
+	g.Expect(r.Status().Update(ctx, j)).To(Succeed())
+	_, err := reconcileN(t, r, account, 1)
+	g.Expect(err).To(MatchError(ContainSubstring("Job Attempt #2 Failed")))
+	g.Expect(testutil.ToFloat64(jobFailedAttempts.WithLabelValues(testNamespace, "account-create", name))).To(Equal(2.0))
+
+	// and the duration of the job once it succeeded
+	j = get(t, r.Client, j)
+	j.Status.Succeeded = 1
+	j.Status.StartTime = &metav1.Time{Time: time.Now().Add(-5 * time.Second)}
+	j.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+	g.Expect(r.Status().Update(ctx, j)).To(Succeed())
+	_, err = reconcileN(t, r, account, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(histogramCount(t, jobDuration, testNamespace, "account-create")).To(Equal(durations + 1))
+	g.Expect(jobFailedAttempts.DeleteLabelValues(testNamespace, "account-create", name)).To(BeFalse())
+}
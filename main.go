@@ -112,17 +112,19 @@ func main() {
 	}
 
 	if err = (&controllers.GaleraReconciler{
-		Client:  mgr.GetClient(),
-		Kclient: kclient,
-		Scheme:  mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Kclient:  kclient,
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("galera-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Galera")
 		os.Exit(1)
 	}
 	if err = (&controllers.MariaDBDatabaseReconciler{
-		Client:  mgr.GetClient(),
-		Kclient: kclient,
-		Scheme:  mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Kclient:  kclient,
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("mariadbdatabase-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MariaDBDatabase")
 		os.Exit(1)
@@ -143,11 +145,28 @@ func main() {
 	}
 
 	if err = (&controllers.MariaDBAccountReconciler{
+		Client:   mgr.GetClient(),
+		Kclient:  kclient,
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("mariadbaccount-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MariaDBAccount")
+		os.Exit(1)
+	}
+	if err = (&controllers.GaleraBackupReconciler{
 		Client:  mgr.GetClient(),
 		Kclient: kclient,
 		Scheme:  mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "MariaDBAccount")
+		setupLog.Error(err, "unable to create controller", "controller", "GaleraBackup")
+		os.Exit(1)
+	}
+	if err = (&controllers.GaleraRestoreReconciler{
+		Client:  mgr.GetClient(),
+		Kclient: kclient,
+		Scheme:  mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GaleraRestore")
 		os.Exit(1)
 	}
 	//+kubebuilder:scaffold:builder
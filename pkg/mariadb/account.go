@@ -16,15 +16,29 @@ type accountCreateOrDeleteOptions struct {
 	DatabaseHostname      string
 	DatabaseAdminUsername string
 	RequireTLS            string
+	KeepPreviousPassword  bool
+	Privileges            string
+	ReadOnly              bool
 }
 
-func CreateDbAccountJob(account *databasev1beta1.MariaDBAccount, databaseName string, databaseHostName string, databaseSecret string, containerImage string, serviceAccountName string, nodeSelector *map[string]string) (*batchv1.Job, error) {
+// CreateDbAccountJob returns a job that creates the account, or updates its
+// privileges and password. passwordHash is the hash of the account secret, so
+// that a password change in the secret results in a new job run.
+// When keepPreviousPassword is set, the password currently set on the account
+// stays valid alongside the new one
+func CreateDbAccountJob(account *databasev1beta1.MariaDBAccount, databaseName string, databaseHostName string, databaseSecret string, containerImage string, serviceAccountName string, nodeSelector *map[string]string, passwordHash string, keepPreviousPassword bool) (*batchv1.Job, error) {
 	var tlsStatement string
 	if account.Spec.RequireTLS {
 		tlsStatement = " REQUIRE SSL"
 	} else {
 		tlsStatement = ""
 	}
+	privileges := "ALL PRIVILEGES"
+	// this is the only thing that keeps the clients of
+	// the read service from writing to the database
+	if account.Spec.ReadOnly {
+		privileges = "SELECT, SHOW VIEW"
+	}
 
 	opts := accountCreateOrDeleteOptions{
 		account.Spec.UserName,
@@ -32,6 +46,9 @@ func CreateDbAccountJob(account *databasev1beta1.MariaDBAccount, databaseName st
 		databaseHostName,
 		"root",
 		tlsStatement,
+		keepPreviousPassword,
+		privileges,
+		account.Spec.ReadOnly,
 	}
 	dbCmd, err := util.ExecuteTemplateFile("account.sh", &opts)
 	if err != nil {
@@ -82,6 +99,10 @@ func CreateDbAccountJob(account *databasev1beta1.MariaDBAccount, databaseName st
 										},
 									},
 								},
+								{
+									Name:  "DatabasePasswordHash",
+									Value: passwordHash,
+								},
 							},
 						},
 					},
@@ -99,7 +120,7 @@ func CreateDbAccountJob(account *databasev1beta1.MariaDBAccount, databaseName st
 
 func DeleteDbAccountJob(account *databasev1beta1.MariaDBAccount, databaseName string, databaseHostName string, databaseSecret string, containerImage string, serviceAccountName string, nodeSelector *map[string]string) (*batchv1.Job, error) {
 
-	opts := accountCreateOrDeleteOptions{account.Spec.UserName, databaseName, databaseHostName, "root", ""}
+	opts := accountCreateOrDeleteOptions{account.Spec.UserName, databaseName, databaseHostName, "root", "", false, "", false}
 
 	delCmd, err := util.ExecuteTemplateFile("delete_account.sh", &opts)
 	if err != nil {
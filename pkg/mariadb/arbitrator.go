@@ -0,0 +1,200 @@
+package mariadb
+
+import (
+	"strconv"
+
+	common "github.com/openstack-k8s-operators/lib-common/modules/common"
+	labels "github.com/openstack-k8s-operators/lib-common/modules/common/labels"
+	tls "github.com/openstack-k8s-operators/lib-common/modules/common/tls"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// GaleraClusterName - default name of the galera cluster, as configured by wsrep_cluster_name
+	GaleraClusterName = "galera_cluster"
+)
+
+// ClusterName - name of the galera cluster the arbitrator joins. It is the one
+// set in the CustomServiceConfig of the galera CR, if any
+func ClusterName(g *mariadbv1.Galera) string {
+	if name, found := g.ClusterConfigOptions()["wsrep_cluster_name"]; found {
+		return name
+	}
+	return GaleraClusterName
+}
+
+// ArbitratorName - name of the arbitrator deployment of a galera CR
+func ArbitratorName(name string) string {
+	return name + "-arbitrator"
+}
+
+// ArbitratorHostname - DNS name of the arbitrator pod. The pod is part of the
+// headless service, so it gets resolved like the galera pods
+func ArbitratorHostname(g *mariadbv1.Galera) string {
+	return ArbitratorName(g.Name) + "." + ResourceName(g.Name) + "." + g.Namespace + ".svc"
+}
+
+// ArbitratorLabels - labels for the arbitrator pod. They match the selector of the
+// headless service, but not the one of the statefulset or the database service
+func ArbitratorLabels(g *mariadbv1.Galera) map[string]string {
+	return labels.GetLabels(g, "galera", map[string]string{
+		"owner":            "mariadb-operator",
+		"app":              "galera",
+		"cr":               "galera-" + g.Name,
+		common.AppSelector: ArbitratorName(g.Name),
+	})
+}
+
+// ArbitratorDeployment returns a Deployment running a galera arbitrator (garbd)
+// which joins the galera nodes listed in gcommURI
+func ArbitratorDeployment(g *mariadbv1.Galera, gcommURI string, configHash string) *appsv1.Deployment {
+	ls := ArbitratorLabels(g)
+	name := ArbitratorName(g.Name)
+	// the arbitrator alone can't form a cluster, stop it with the galera pods
+	replicas := ptr.To[int32](1)
+	if g.Status.StopRequired {
+		replicas = ptr.To[int32](0)
+	}
+	gcommTLS := g.Spec.TLS.Enabled() && g.Spec.TLS.Ca.CaBundleSecretName != ""
+
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: g.Namespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: ls,
+			},
+			// never run two arbitrators with the same name in the cluster
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RecreateDeploymentStrategyType,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: ls,
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: g.RbacResourceName(),
+					Hostname:           name,
+					Subdomain:          ResourceName(g.Name),
+					Containers: []corev1.Container{{
+						Image:   GaleraImage(g),
+						Name:    "garbd",
+						Command: []string{"/usr/bin/dumb-init", "--", "/usr/local/bin/kolla_start"},
+						Env: []corev1.EnvVar{{
+							Name:  "CR_CONFIG_HASH",
+							Value: configHash,
+						}, {
+							Name:  "KOLLA_CONFIG_STRATEGY",
+							Value: "COPY_ALWAYS",
+						}, {
+							Name:  "CLUSTER_NAME",
+							Value: ClusterName(g),
+						}, {
+							Name:  "GCOMM_URI",
+							Value: gcommURI,
+						}, {
+							Name:  "GCOMM_TLS",
+							Value: strconv.FormatBool(gcommTLS),
+						}},
+						Ports: []corev1.ContainerPort{{
+							ContainerPort: 4567,
+							Name:          "galera",
+						}},
+						VolumeMounts: getArbitratorVolumeMounts(g, gcommTLS),
+					}},
+					Volumes: getArbitratorVolumes(g, gcommTLS),
+				},
+			},
+		},
+	}
+
+	if g.Spec.Arbitrator != nil && g.Spec.Arbitrator.NodeSelector != nil {
+		dep.Spec.Template.Spec.NodeSelector = *g.Spec.Arbitrator.NodeSelector
+	}
+
+	return dep
+}
+
+func getArbitratorVolumes(g *mariadbv1.Galera, gcommTLS bool) []corev1.Volume {
+	volumes := []corev1.Volume{
+		{
+			Name: "kolla-config",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: g.Name + "-config-data",
+					},
+					Items: []corev1.KeyToPath{
+						{
+							Key:  "garbd_config.json",
+							Path: "config.json",
+						},
+					},
+				},
+			},
+		},
+		{
+			Name: "operator-scripts",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: g.Name + "-scripts",
+					},
+					Items: []corev1.KeyToPath{
+						{
+							Key:  "garbd_start.sh",
+							Path: "garbd_start.sh",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// the arbitrator only needs certificates when WSREP is encrypted
+	if gcommTLS {
+		svc := tls.Service{
+			SecretName: *g.Spec.TLS.GenericService.SecretName,
+			CertMount:  nil,
+			KeyMount:   nil,
+			CaMount:    nil,
+		}
+		volumes = append(volumes, svc.CreateVolume(GaleraCertPrefix), g.Spec.TLS.Ca.CreateVolume())
+	}
+
+	return volumes
+}
+
+func getArbitratorVolumeMounts(g *mariadbv1.Galera, gcommTLS bool) []corev1.VolumeMount {
+	volumeMounts := []corev1.VolumeMount{
+		{
+			MountPath: "/var/lib/operator-scripts",
+			ReadOnly:  true,
+			Name:      "operator-scripts",
+		}, {
+			MountPath: "/var/lib/kolla/config_files",
+			ReadOnly:  true,
+			Name:      "kolla-config",
+		},
+	}
+
+	if gcommTLS {
+		svc := tls.Service{
+			SecretName: *g.Spec.TLS.GenericService.SecretName,
+			CertMount:  nil,
+			KeyMount:   nil,
+			CaMount:    nil,
+		}
+		volumeMounts = append(volumeMounts, svc.CreateVolumeMounts(GaleraCertPrefix)...)
+		volumeMounts = append(volumeMounts, g.Spec.TLS.Ca.CreateVolumeMounts(nil)...)
+	}
+
+	return volumeMounts
+}
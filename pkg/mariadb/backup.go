@@ -0,0 +1,216 @@
+package mariadb
+
+import (
+	"time"
+
+	util "github.com/openstack-k8s-operators/lib-common/modules/common/util"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// BackupMountPath - location of the backup storage in backup and restore jobs
+	BackupMountPath = "/var/lib/mysql-backup"
+	// BinlogArchiveMountPath - location of the archived binary logs in galera pods and restore jobs
+	BinlogArchiveMountPath = "/var/lib/mysql-binlog-archive"
+)
+
+type backupOptions struct {
+	BackupMountPath       string
+	BackupPath            string
+	DatabaseAdminUsername string
+}
+
+// BackupPath - location of a backup artifact, relative to the root of the backup storage
+func BackupPath(b *mariadbv1.GaleraBackup) string {
+	return b.Spec.DatabaseInstance + "/" + b.Name
+}
+
+// BackupJobName - name of the job that takes a backup for a GaleraBackup CR
+func BackupJobName(b *mariadbv1.GaleraBackup) string {
+	return b.Name + "-backup"
+}
+
+// DataVolumeClaimName - name of the PVC that holds the datadir of a galera pod
+func DataVolumeClaimName(podName string) string {
+	return "mysql-db-" + podName
+}
+
+// BackupCleanupJobName - name of the job that deletes the artifact of a GaleraBackup CR
+func BackupCleanupJobName(b *mariadbv1.GaleraBackup) string {
+	return b.Name + "-cleanup"
+}
+
+// ScheduledBackupLabels - labels of the GaleraBackup CRs created from the backup schedule of a galera CR
+func ScheduledBackupLabels(g *mariadbv1.Galera) map[string]string {
+	return map[string]string{
+		"owner": "mariadb-operator", "app": "galerabackup", "cr": "galera-" + g.Name,
+	}
+}
+
+// ScheduledBackup returns a GaleraBackup CR for a run of the backup schedule of a galera CR
+func ScheduledBackup(g *mariadbv1.Galera, scheduledTime time.Time) *mariadbv1.GaleraBackup {
+	return &mariadbv1.GaleraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      g.Name + "-" + scheduledTime.UTC().Format("20060102150405"),
+			Namespace: g.Namespace,
+			Labels:    ScheduledBackupLabels(g),
+		},
+		Spec: mariadbv1.GaleraBackupSpec{
+			DatabaseInstance: g.Name,
+			Storage:          g.Spec.Backup.Storage,
+		},
+	}
+}
+
+// BackupJob returns a job that runs mariabackup against the datadir of a running galera pod
+func BackupJob(b *mariadbv1.GaleraBackup, g *mariadbv1.Galera, pod *corev1.Pod) (*batchv1.Job, error) {
+	opts := backupOptions{
+		BackupMountPath,
+		BackupPath(b),
+		"root",
+	}
+	backupCmd, err := util.ExecuteTemplateFile("backup.sh", &opts)
+	if err != nil {
+		return nil, err
+	}
+	labels := map[string]string{
+		"owner": "mariadb-operator", "cr": b.Name, "app": "galerabackup",
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      BackupJobName(b),
+			Namespace: b.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			// a failed backup is not retried, a new GaleraBackup must be created
+			BackoffLimit: ptr.To[int32](0),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: g.RbacResourceName(),
+					// the datadir volume can only be shared with
+					// the galera pod from the same worker node
+					NodeName: pod.Spec.NodeName,
+					Containers: []corev1.Container{
+						{
+							Name:    "galera-backup",
+							Image:   GaleraImage(g),
+							Command: []string{"/bin/bash", "-c", backupCmd},
+							Env: []corev1.EnvVar{
+								{
+									Name: "MYSQL_PWD",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: g.Spec.Secret,
+											},
+											Key: mariadbv1.DbRootPasswordSelector,
+										},
+									},
+								},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									MountPath: "/var/lib/mysql",
+									Name:      "mysql-db",
+									SubPath:   "mysql",
+								},
+								{
+									MountPath: BackupMountPath,
+									Name:      "backup",
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "mysql-db",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: DataVolumeClaimName(pod.Name),
+								},
+							},
+						},
+						{
+							Name: "backup",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: b.Spec.Storage.ClaimName,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return job, nil
+}
+
+// BackupCleanupJob returns a job that deletes the artifact of a backup from the backup storage
+func BackupCleanupJob(b *mariadbv1.GaleraBackup, g *mariadbv1.Galera) (*batchv1.Job, error) {
+	opts := backupOptions{
+		BackupMountPath,
+		b.Status.Path,
+		"root",
+	}
+	cleanupCmd, err := util.ExecuteTemplateFile("delete_backup.sh", &opts)
+	if err != nil {
+		return nil, err
+	}
+	labels := map[string]string{
+		"owner": "mariadb-operator", "cr": b.Name, "app": "galerabackup",
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      BackupCleanupJobName(b),
+			Namespace: b.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyOnFailure,
+					ServiceAccountName: g.RbacResourceName(),
+					Containers: []corev1.Container{
+						{
+							Name:    "galera-backup-cleanup",
+							Image:   GaleraImage(g),
+							Command: []string{"/bin/bash", "-c", cleanupCmd},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									MountPath: BackupMountPath,
+									Name:      "backup",
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "backup",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: b.Spec.Storage.ClaimName,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return job, nil
+}
@@ -0,0 +1,133 @@
+package mariadb
+
+import (
+	common "github.com/openstack-k8s-operators/lib-common/modules/common"
+	labels "github.com/openstack-k8s-operators/lib-common/modules/common/labels"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// MetricsUserName - database user of the mysqld exporter
+	MetricsUserName = "mysqld_exporter"
+
+	// MetricsPasswordKey - key of the password of the monitoring user in the metrics secret
+	MetricsPasswordKey = "password"
+
+	// MetricsPort - port of the mysqld exporter
+	MetricsPort = 9104
+)
+
+// ServiceMonitorGVK - kind of the prometheus-operator resource that
+// configures the scraping of the metrics service
+var ServiceMonitorGVK = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Version: "v1",
+	Kind:    "ServiceMonitor",
+}
+
+// MetricsName - name of the metrics service, secret and service monitor of a galera CR
+func MetricsName(name string) string {
+	return name + "-metrics"
+}
+
+// MetricsServiceLabels - labels for the metrics service. They must not match the
+// ServiceLabels, which are used to look up the database service of a galera CR
+func MetricsServiceLabels(database metav1.Object) map[string]string {
+	return labels.GetLabels(database, "mariadb", map[string]string{
+		"owner": "mariadb-operator",
+		"cr":    "mariadb-" + database.GetName(),
+		"app":   "mariadb-metrics",
+	})
+}
+
+// MetricsService - service to expose the mysqld exporter of every galera pod
+func MetricsService(g *mariadbv1.Galera) *corev1.Service {
+	selectors := LabelSelectors(g, "galera")
+	selectors[common.AppSelector] = StatefulSetName(g.Name)
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      MetricsName(g.Name),
+			Namespace: g.Namespace,
+			Labels:    MetricsServiceLabels(g),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: selectors,
+			Ports: []corev1.ServicePort{
+				{Name: "metrics", Port: MetricsPort, Protocol: corev1.ProtocolTCP},
+			},
+			// galera nodes that are not synced (e.g. donors) are not
+			// ready, but their metrics are the most interesting ones
+			PublishNotReadyAddresses: true,
+		},
+	}
+	return svc
+}
+
+// MetricsServiceMonitor - prometheus-operator ServiceMonitor that scrapes the
+// metrics service. It is unstructured, as the CRD is not always installed
+func MetricsServiceMonitor(g *mariadbv1.Galera) *unstructured.Unstructured {
+	sm := &unstructured.Unstructured{}
+	sm.SetGroupVersionKind(ServiceMonitorGVK)
+	sm.SetName(MetricsName(g.Name))
+	sm.SetNamespace(g.Namespace)
+	sm.SetLabels(MetricsServiceLabels(g))
+	sm.Object["spec"] = map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": toInterfaceMap(MetricsServiceLabels(g)),
+		},
+		"endpoints": []interface{}{
+			map[string]interface{}{
+				"port":     "metrics",
+				"interval": "30s",
+			},
+		},
+	}
+	return sm
+}
+
+func toInterfaceMap(m map[string]string) map[string]interface{} {
+	res := map[string]interface{}{}
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
+
+func getMetricsContainer(g *mariadbv1.Galera) corev1.Container {
+	return corev1.Container{
+		Image: g.Spec.Metrics.ContainerImage,
+		Name:  "mysqld-exporter",
+		// mysqld only listens on the address of the pod's hostname
+		Args: []string{
+			"--mysqld.address=$(POD_NAME):3306",
+			"--mysqld.username=" + MetricsUserName,
+			"--web.listen-address=:9104",
+		},
+		Env: []corev1.EnvVar{{
+			Name: "POD_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: "metadata.name",
+				},
+			},
+		}, {
+			Name: "MYSQLD_EXPORTER_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: MetricsName(g.Name),
+					},
+					Key: MetricsPasswordKey,
+				},
+			},
+		}},
+		Ports: []corev1.ContainerPort{{
+			ContainerPort: MetricsPort,
+			Name:          "metrics",
+		}},
+	}
+}
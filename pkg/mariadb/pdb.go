@@ -0,0 +1,46 @@
+package mariadb
+
+import (
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// PodDisruptionBudget returns the PodDisruptionBudget of the galera pods. It only
+// allows the eviction of a minority of the galera cluster's members at a time, so
+// that a node drain never causes a loss of quorum. The budget is relaxed while the
+// controller stops all the pods, as the cluster is not running anymore
+func PodDisruptionBudget(g *mariadbv1.Galera) *policyv1.PodDisruptionBudget {
+	replicas := int32(1)
+	if g.Spec.Replicas != nil {
+		replicas = *g.Spec.Replicas
+	}
+	// the arbitrator is not covered by the budget, but it counts in the quorum
+	members := replicas
+	if g.Spec.Arbitrator != nil {
+		members++
+	}
+	// a node drain must always be able to evict a pod. A single node
+	// has no quorum to protect, it is bootstrapped again once it is
+	// rescheduled, and a node evicted from a cluster of two leaves it
+	// gracefully, so the other node keeps the primary component
+	maxUnavailable := max((members-1)/2, 1)
+	if g.Status.StopRequired {
+		maxUnavailable = max(replicas, 1)
+	}
+
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ResourceName(g.Name),
+			Namespace: g.Namespace,
+			Labels:    StatefulSetLabels(g),
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &intstr.IntOrString{Type: intstr.Int, IntVal: maxUnavailable},
+			Selector: &metav1.LabelSelector{
+				MatchLabels: StatefulSetLabels(g),
+			},
+		},
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mariadb
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+)
+
+func TestPodDisruptionBudget(t *testing.T) {
+	tests := []struct {
+		replicas     int32
+		arbitrator   bool
+		stopRequired bool
+		unavailable  int
+	}{
+		{replicas: 1, unavailable: 1},
+		{replicas: 2, unavailable: 1},
+		{replicas: 3, unavailable: 1},
+		{replicas: 5, unavailable: 2},
+		{replicas: 1, arbitrator: true, unavailable: 1},
+		{replicas: 2, arbitrator: true, unavailable: 1},
+		{replicas: 3, arbitrator: true, unavailable: 1},
+		{replicas: 4, arbitrator: true, unavailable: 2},
+		{replicas: 1, stopRequired: true, unavailable: 1},
+		{replicas: 3, stopRequired: true, unavailable: 3},
+		{replicas: 5, stopRequired: true, unavailable: 5},
+		{replicas: 2, arbitrator: true, stopRequired: true, unavailable: 2},
+	}
+
+	for _, tt := range tests {
+		name := fmt.Sprintf("%d replicas, arbitrator %t, stop required %t", tt.replicas, tt.arbitrator, tt.stopRequired)
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			galera := newTestGalera(mariadbv1.GaleraOverrideSpec{})
+			galera.Spec.Replicas = ptr.To(tt.replicas)
+			if tt.arbitrator {
+				galera.Spec.Arbitrator = &mariadbv1.GaleraArbitrator{}
+			}
+			galera.Status.StopRequired = tt.stopRequired
+
+			pdb := PodDisruptionBudget(galera)
+			g.Expect(*pdb.Spec.MaxUnavailable).To(Equal(intstr.FromInt(tt.unavailable)))
+			g.Expect(pdb.Spec.MinAvailable).To(BeNil())
+			g.Expect(pdb.Spec.Selector.MatchLabels).To(Equal(StatefulSetLabels(galera)))
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package mariadb
+
+import (
+	util "github.com/openstack-k8s-operators/lib-common/modules/common/util"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// archiveNodeDataDir is where a restore job mounts the datadir of the node
+// whose binary logs are replayed, when it is not the restored node
+const archiveNodeDataDir = "/var/lib/mysql-archive-node"
+
+type restoreOptions struct {
+	BackupMountPath    string
+	BackupPath         string
+	UUID               string
+	Seqno              string
+	ArchiveMountPath   string
+	ArchiveNode        string
+	ArchiveNodeDataDir string
+	ArchivePodPrefix   string
+	PreviousArchiveDir string
+	Replay             bool
+	StopGTID           string
+	StopDatetime       string
+}
+
+// RestoreJobName - name of the job that restores a backup on the datadir of a galera pod
+func RestoreJobName(r *mariadbv1.GaleraRestore) string {
+	return r.Name + "-restore"
+}
+
+// ResetDataJobName - name of the job that discards the datadir of a galera pod
+func ResetDataJobName(r *mariadbv1.GaleraRestore, podName string) string {
+	return r.Name + "-reset-" + podName
+}
+
+// RestoreJob returns a job that copies a backup artifact onto the datadir of a stopped
+// galera pod, and marks that pod as safe to bootstrap the cluster from
+func RestoreJob(r *mariadbv1.GaleraRestore, g *mariadbv1.Galera, b *mariadbv1.GaleraBackup, podName string) (*batchv1.Job, error) {
+	opts := restoreOptions{
+		BackupMountPath:  BackupMountPath,
+		BackupPath:       b.Status.Path,
+		UUID:             b.Status.UUID,
+		Seqno:            b.Status.Seqno,
+		ArchivePodPrefix: StatefulSetName(g.Name),
+	}
+	if g.Spec.BinlogArchive != nil {
+		opts.ArchiveMountPath = BinlogArchiveMountPath
+		opts.ArchiveNode = b.Status.Node
+		opts.ArchiveNodeDataDir = "/var/lib/mysql"
+		if b.Status.Node != podName {
+			opts.ArchiveNodeDataDir = archiveNodeDataDir
+		}
+		opts.PreviousArchiveDir = "before-" + r.Name
+	}
+	if pit := r.Spec.PointInTime; pit != nil {
+		opts.Replay = true
+		opts.StopGTID = pit.GTID
+		if pit.Time != nil {
+			// mysqlbinlog interprets the time in the timezone of
+			// the job container, which runs in UTC
+			opts.StopDatetime = pit.Time.UTC().Format("2006-01-02 15:04:05")
+		}
+	}
+	restoreCmd, err := util.ExecuteTemplateFile("restore.sh", &opts)
+	if err != nil {
+		return nil, err
+	}
+	job := restoreJobCommon(r, g, RestoreJobName(r), podName, restoreCmd)
+	spec := &job.Spec.Template.Spec
+	spec.Containers[0].VolumeMounts = append(spec.Containers[0].VolumeMounts,
+		corev1.VolumeMount{
+			MountPath: BackupMountPath,
+			Name:      "backup",
+			ReadOnly:  true,
+		})
+	spec.Volumes = append(spec.Volumes,
+		corev1.Volume{
+			Name: "backup",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: b.Spec.Storage.ClaimName,
+					ReadOnly:  true,
+				},
+			},
+		})
+	if g.Spec.BinlogArchive != nil {
+		spec.Containers[0].VolumeMounts = append(spec.Containers[0].VolumeMounts,
+			corev1.VolumeMount{
+				MountPath: BinlogArchiveMountPath,
+				Name:      "binlog-archive",
+			})
+		spec.Volumes = append(spec.Volumes, getBinlogArchiveVolume(g.Spec.BinlogArchive.ClaimName))
+	}
+	if opts.Replay {
+		// the binary logs are replayed as root
+		spec.Containers[0].Env = append(spec.Containers[0].Env,
+			corev1.EnvVar{
+				Name: "MYSQL_PWD",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: g.Spec.Secret,
+						},
+						Key: mariadbv1.DbRootPasswordSelector,
+					},
+				},
+			})
+	}
+	if opts.Replay && opts.ArchiveNodeDataDir == archiveNodeDataDir {
+		// the last binary log of the node whose archive is replayed
+		// is still in its datadir
+		spec.Containers[0].VolumeMounts = append(spec.Containers[0].VolumeMounts,
+			corev1.VolumeMount{
+				MountPath: archiveNodeDataDir,
+				Name:      "archive-node-db",
+				SubPath:   "mysql",
+				ReadOnly:  true,
+			})
+		spec.Volumes = append(spec.Volumes,
+			corev1.Volume{
+				Name: "archive-node-db",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: DataVolumeClaimName(opts.ArchiveNode),
+						ReadOnly:  true,
+					},
+				},
+			})
+	}
+
+	return job, nil
+}
+
+// ResetDataJob returns a job that discards the datadir of a stopped galera pod,
+// to force a full state transfer when the pod rejoins the cluster
+func ResetDataJob(r *mariadbv1.GaleraRestore, g *mariadbv1.Galera, podName string) (*batchv1.Job, error) {
+	resetCmd, err := util.ExecuteTemplateFile("reset_datadir.sh", nil)
+	if err != nil {
+		return nil, err
+	}
+	return restoreJobCommon(r, g, ResetDataJobName(r, podName), podName, resetCmd), nil
+}
+
+func restoreJobCommon(r *mariadbv1.GaleraRestore, g *mariadbv1.Galera, name string, podName string, cmd string) *batchv1.Job {
+	labels := map[string]string{
+		"owner": "mariadb-operator", "cr": r.Name, "app": "galerarestore",
+	}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: r.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			// a failed restore is not retried, the data on disk needs inspection
+			BackoffLimit: ptr.To[int32](0),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: g.RbacResourceName(),
+					Containers: []corev1.Container{
+						{
+							Name:    "galera-restore",
+							Image:   GaleraImage(g),
+							Command: []string{"/bin/bash", "-c", cmd},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									MountPath: "/var/lib/mysql",
+									Name:      "mysql-db",
+									SubPath:   "mysql",
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "mysql-db",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: DataVolumeClaimName(podName),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,285 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mariadb
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestBackup() *mariadbv1.GaleraBackup {
+	b := &mariadbv1.GaleraBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "test"},
+		Spec: mariadbv1.GaleraBackupSpec{
+			DatabaseInstance: "openstack",
+			Storage:          mariadbv1.GaleraBackupStorage{ClaimName: "backup-storage"},
+		},
+	}
+	b.Status.Node = "openstack-galera-1"
+	b.Status.Path = "test/backup"
+	b.Status.UUID = "3a0a9e5c-0000-11ef-0000-000000000000"
+	b.Status.Seqno = "42"
+	return b
+}
+
+func newTestRestore(pit *mariadbv1.GaleraRestorePointInTime) *mariadbv1.GaleraRestore {
+	return &mariadbv1.GaleraRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: "test"},
+		Spec: mariadbv1.GaleraRestoreSpec{
+			DatabaseInstance: "openstack",
+			BackupName:       "backup",
+			PointInTime:      pit,
+		},
+	}
+}
+
+// restoreScript returns the script run by a restore job
+func restoreScript(job *batchv1.Job) string {
+	return job.Spec.Template.Spec.Containers[0].Command[2]
+}
+
+func TestRestoreJob(t *testing.T) {
+	stopTime := metav1.NewTime(time.Date(2024, 5, 2, 14, 30, 0, 0, time.FixedZone("CEST", 2*3600)))
+
+	tests := []struct {
+		name        string
+		archive     bool
+		pit         *mariadbv1.GaleraRestorePointInTime
+		podName     string
+		contains    []string
+		notContains []string
+		volumes     []string
+	}{
+		{
+			name:        "Backup only",
+			podName:     "openstack-galera-0",
+			contains:    []string{"mariabackup --copy-back", "uuid:    3a0a9e5c-0000-11ef-0000-000000000000", "seqno:   42"},
+			notContains: []string{"mysqlbinlog", "mysql-binlog-archive"},
+			volumes:     []string{"mysql-db", "backup"},
+		},
+		{
+			name:        "Binlog archive without replay",
+			archive:     true,
+			podName:     "openstack-galera-0",
+			contains:    []string{`mkdir -p "/var/lib/mysql-binlog-archive/before-restore"`, `-name "openstack-galera-*"`},
+			notContains: []string{"mysqlbinlog"},
+			volumes:     []string{"mysql-db", "backup", "binlog-archive"},
+		},
+		{
+			name:    "Replay all the archived binlogs",
+			archive: true,
+			pit:     &mariadbv1.GaleraRestorePointInTime{},
+			podName: "openstack-galera-1",
+			contains: []string{
+				"ARCHIVE_DIR=/var/lib/mysql-binlog-archive/openstack-galera-1",
+				`"/var/lib/mysql/mysql-bin.index"`,
+				`mysqlbinlog --start-position="${binlog_pos}" ${binlogs}`,
+			},
+			notContains: []string{"--stop-position", "--stop-datetime"},
+			volumes:     []string{"mysql-db", "backup", "binlog-archive"},
+		},
+		{
+			name:    "Replay up to a GTID",
+			archive: true,
+			pit:     &mariadbv1.GaleraRestorePointInTime{GTID: "1-1-4242"},
+			podName: "openstack-galera-1",
+			contains: []string{
+				`mysqlbinlog --start-position="${binlog_gtid}" --stop-position="1-1-4242" ${binlogs}`,
+			},
+			notContains: []string{"--stop-datetime"},
+			volumes:     []string{"mysql-db", "backup", "binlog-archive"},
+		},
+		{
+			name:    "Replay up to a time",
+			archive: true,
+			pit:     &mariadbv1.GaleraRestorePointInTime{Time: &stopTime},
+			podName: "openstack-galera-1",
+			contains: []string{
+				// the time is converted to the UTC timezone of the job
+				`mysqlbinlog --start-position="${binlog_pos}" --stop-datetime="2024-05-02 12:30:00" ${binlogs}`,
+			},
+			notContains: []string{"--stop-position"},
+			volumes:     []string{"mysql-db", "backup", "binlog-archive"},
+		},
+		{
+			name:    "Replay the binlogs of another node",
+			archive: true,
+			pit:     &mariadbv1.GaleraRestorePointInTime{},
+			podName: "openstack-galera-0",
+			contains: []string{
+				"ARCHIVE_DIR=/var/lib/mysql-binlog-archive/openstack-galera-1",
+				`"/var/lib/mysql-archive-node/mysql-bin.index"`,
+			},
+			volumes: []string{"mysql-db", "backup", "binlog-archive", "archive-node-db"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			t.Setenv("OPERATOR_TEMPLATES", "../../templates")
+
+			galera := newTestGalera(mariadbv1.GaleraOverrideSpec{})
+			if tt.archive {
+				galera.Spec.BinlogArchive = &mariadbv1.GaleraBinlogArchive{ClaimName: "binlog-archive"}
+			}
+			job, err := RestoreJob(newTestRestore(tt.pit), galera, newTestBackup(), tt.podName)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			script := restoreScript(job)
+			for _, s := range tt.contains {
+				g.Expect(script).To(ContainSubstring(s))
+			}
+			for _, s := range tt.notContains {
+				g.Expect(script).ToNot(ContainSubstring(s))
+			}
+			volumes := []string{}
+			for _, v := range job.Spec.Template.Spec.Volumes {
+				volumes = append(volumes, v.Name)
+			}
+			g.Expect(volumes).To(ConsistOf(tt.volumes))
+			g.Expect(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal(DataVolumeClaimName(tt.podName)))
+		})
+	}
+}
+
+func TestRestoreJobArchiveMounts(t *testing.T) {
+	g := NewWithT(t)
+	t.Setenv("OPERATOR_TEMPLATES", "../../templates")
+
+	galera := newTestGalera(mariadbv1.GaleraOverrideSpec{})
+	galera.Spec.BinlogArchive = &mariadbv1.GaleraBinlogArchive{ClaimName: "binlog-archive"}
+	job, err := RestoreJob(newTestRestore(&mariadbv1.GaleraRestorePointInTime{}), galera, newTestBackup(), "openstack-galera-0")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// the archive is written to, while the backup and the datadir of
+	// the node whose binary logs are replayed are only read
+	spec := job.Spec.Template.Spec
+	g.Expect(spec.Containers[0].VolumeMounts).To(ConsistOf(
+		corev1.VolumeMount{Name: "mysql-db", MountPath: "/var/lib/mysql", SubPath: "mysql"},
+		corev1.VolumeMount{Name: "backup", MountPath: BackupMountPath, ReadOnly: true},
+		corev1.VolumeMount{Name: "binlog-archive", MountPath: BinlogArchiveMountPath},
+		corev1.VolumeMount{Name: "archive-node-db", MountPath: archiveNodeDataDir, SubPath: "mysql", ReadOnly: true},
+	))
+	g.Expect(spec.Volumes).To(ContainElement(corev1.Volume{
+		Name: "archive-node-db",
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: "mysql-db-openstack-galera-1",
+				ReadOnly:  true,
+			},
+		},
+	}))
+}
+
+// replayStubs are the commands called by the replay of a restore job. The
+// stub of the mysql client fails like a server started without grant tables
+// when it is sent an account change
+var replayStubs = map[string]string{
+	"mariabackup": "exit 0",
+	"mysqladmin":  "exit 0",
+	"mysqld_safe": `echo "$@" > "${STUB_DIR}/mysqld_safe.args"`,
+	"mysqlbinlog": `echo "GRANT ALL PRIVILEGES ON keystone.* TO 'keystone'@'%';"; exit ${MYSQLBINLOG_EXIT:-0}`,
+	"mysql": `input=$(cat)
+if echo "${input}" | grep -q GRANT && grep -q -- --skip-grant-tables "${STUB_DIR}/mysqld_safe.args"; then
+    echo "ERROR 1290 (HY000): The MariaDB server is running with the --skip-grant-tables option" >&2
+    exit 1
+fi
+echo "${input}" > "${STUB_DIR}/replayed.sql"`,
+}
+
+func TestRestoreReplayScript(t *testing.T) {
+	tests := []struct {
+		name           string
+		mysqlbinlogErr bool
+	}{
+		{name: "Binlog with an account change"},
+		{name: "Failed binlog read", mysqlbinlogErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			t.Setenv("OPERATOR_TEMPLATES", "../../templates")
+
+			galera := newTestGalera(mariadbv1.GaleraOverrideSpec{})
+			galera.Spec.BinlogArchive = &mariadbv1.GaleraBinlogArchive{ClaimName: "binlog-archive"}
+			job, err := RestoreJob(newTestRestore(&mariadbv1.GaleraRestorePointInTime{}), galera, newTestBackup(), "openstack-galera-1")
+			g.Expect(err).ToNot(HaveOccurred())
+			// the binary logs are replayed as root
+			g.Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(And(
+				HaveField("Name", "MYSQL_PWD"),
+				HaveField("ValueFrom.SecretKeyRef.Name", "osp-secret"))))
+
+			// run the script on local directories instead of the volumes of the job
+			dir := t.TempDir()
+			script := strings.NewReplacer(
+				BinlogArchiveMountPath, filepath.Join(dir, "archive"),
+				BackupMountPath, filepath.Join(dir, "backup"),
+				"/var/lib/mysql", filepath.Join(dir, "datadir"),
+				"/tmp/replay.sock", filepath.Join(dir, "replay.sock"),
+			).Replace(restoreScript(job))
+			files := map[string]string{
+				"backup/test/backup/xtrabackup_binlog_info":   "mysql-bin.000001\t4\t1-1-1\n",
+				"archive/openstack-galera-1/mysql-bin.000001": "",
+				"datadir/ibdata1": "",
+			}
+			for name, content := range replayStubs {
+				files["bin/"+name] = "#!/bin/bash\n" + content + "\n"
+			}
+			for name, content := range files {
+				path := filepath.Join(dir, name)
+				g.Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+				g.Expect(os.WriteFile(path, []byte(content), 0o755)).To(Succeed())
+			}
+
+			cmd := exec.Command("/bin/bash", "-c", script)
+			cmd.Env = append(os.Environ(),
+				"PATH="+filepath.Join(dir, "bin")+":"+os.Getenv("PATH"),
+				"STUB_DIR="+dir)
+			if tt.mysqlbinlogErr {
+				cmd.Env = append(cmd.Env, "MYSQLBINLOG_EXIT=1")
+			}
+			out, err := cmd.CombinedOutput()
+
+			// a failure of mysqlbinlog fails the restore, even though
+			// its output is piped to the mysql client
+			if tt.mysqlbinlogErr {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(filepath.Join(dir, "datadir", "grastate.dat")).ToNot(BeAnExistingFile())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred(), string(out))
+			replayed, err := os.ReadFile(filepath.Join(dir, "replayed.sql"))
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(string(replayed)).To(ContainSubstring("GRANT ALL PRIVILEGES ON keystone.*"))
+			g.Expect(string(replayed)).To(HaveSuffix("SHUTDOWN;\n"))
+			g.Expect(filepath.Join(dir, "datadir", "grastate.dat")).To(BeAnExistingFile())
+		})
+	}
+}
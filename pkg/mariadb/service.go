@@ -1,13 +1,16 @@
 package mariadb
 
 import (
+	common "github.com/openstack-k8s-operators/lib-common/modules/common"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func Service(db metav1.Object) *corev1.Service {
+// Service - service to expose the galera cluster to database clients
+func Service(db *mariadbv1.Galera) *corev1.Service {
 	selectors := LabelSelectors(db, "galera")
-	// NOTE(dciabrin) we currently deploy the Galera cluster as A/P,
+	// NOTE(dciabrin) by default we deploy the Galera cluster as A/P,
 	// by configuring the service's label selector to create
 	// a single endpoint matching a single pod's name.
 	// This label is later updated by a script called by Galera any
@@ -26,7 +29,14 @@ func Service(db metav1.Object) *corev1.Service {
 	//     service. This is true as long the first pod is not in a
 	//     network partition without quorum.
 	//     TODO improve that fallback pod selection
-	selectors[ActivePodSelectorKey] = db.GetName() + "-galera-0"
+	// In A/A mode, there is no active pod in the label selector, so the
+	// service balances the traffic across all the Synced (i.e. ready) pods
+	// of the statefulset, which excludes the arbitrator pod if any
+	if !db.IsActiveActive() {
+		selectors[ActivePodSelectorKey] = db.GetName() + "-galera-0"
+	} else {
+		selectors[common.AppSelector] = StatefulSetName(db.GetName())
+	}
 	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      db.GetName(),
@@ -43,7 +53,71 @@ func Service(db metav1.Object) *corev1.Service {
 	return svc
 }
 
-// HeadlessService - service to give galera pods connectivity via DNS
+// ReadServiceName - name of the service exposing the passive galera nodes
+func ReadServiceName(name string) string {
+	return name + "-read"
+}
+
+// ReadService - service to expose the Synced galera nodes that are not the
+// active endpoint of the database service, so that read-only clients can be
+// offloaded from the active node. It has no label selector, its endpoints
+// are managed by the galera controller.
+// The service itself does not prevent writes, which galera would replicate
+// to the whole cluster. Clients are kept read-only by using an account with
+// readOnly set, which is only granted read privileges
+func ReadService(db *mariadbv1.Galera) *corev1.Service {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ReadServiceName(db.GetName()),
+			Namespace: db.GetNamespace(),
+			Labels:    ReadServiceLabels(db),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{Name: "database", Port: 3306, Protocol: corev1.ProtocolTCP},
+			},
+		},
+	}
+	return svc
+}
+
+// ReadEndpoints - endpoints of the read service, targeting the given pods
+func ReadEndpoints(db *mariadbv1.Galera, pods []corev1.Pod) *corev1.Endpoints {
+	ep := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ReadServiceName(db.GetName()),
+			Namespace: db.GetNamespace(),
+			Labels:    ReadServiceLabels(db),
+		},
+	}
+	addresses := []corev1.EndpointAddress{}
+	for _, pod := range pods {
+		if pod.Status.PodIP == "" {
+			continue
+		}
+		addresses = append(addresses, corev1.EndpointAddress{
+			IP: pod.Status.PodIP,
+			TargetRef: &corev1.ObjectReference{
+				Kind:      "Pod",
+				Name:      pod.Name,
+				Namespace: pod.Namespace,
+				UID:       pod.UID,
+			},
+		})
+	}
+	if len(addresses) > 0 {
+		ep.Subsets = []corev1.EndpointSubset{{
+			Addresses: addresses,
+			Ports: []corev1.EndpointPort{
+				{Name: "database", Port: 3306, Protocol: corev1.ProtocolTCP},
+			},
+		}}
+	}
+	return ep
+}
+
+// HeadlessService - service to give galera pods connectivity via DNS. Its
+// selector also matches the arbitrator pod, if any
 func HeadlessService(db metav1.Object) *corev1.Service {
 	name := ResourceName(db.GetName())
 	dep := &corev1.Service{
@@ -0,0 +1,14 @@
+package mariadb
+
+const (
+	// SSTUserName - database user of mariabackup on the donor of a state snapshot transfer
+	SSTUserName = "galera_sst"
+
+	// SSTPasswordKey - key of the password of the SST user in the SST secret
+	SSTPasswordKey = "password"
+)
+
+// SSTSecretName - name of the secret holding the password of the SST user of a galera CR
+func SSTSecretName(name string) string {
+	return name + "-sst"
+}
@@ -1,18 +1,24 @@
 package mariadb
 
 import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
 	common "github.com/openstack-k8s-operators/lib-common/modules/common"
 	"github.com/openstack-k8s-operators/lib-common/modules/common/affinity"
+	"github.com/openstack-k8s-operators/lib-common/modules/common/util"
 	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	resource "k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/utils/ptr"
 )
 
 // StatefulSet returns a StatefulSet object for the galera cluster
-func StatefulSet(g *mariadbv1.Galera, configHash string) *appsv1.StatefulSet {
+func StatefulSet(g *mariadbv1.Galera, configHash string) (*appsv1.StatefulSet, error) {
 	ls := StatefulSetLabels(g)
 	name := StatefulSetName(g.Name)
 	var replicas *int32
@@ -21,6 +27,12 @@ func StatefulSet(g *mariadbv1.Galera, configHash string) *appsv1.StatefulSet {
 	} else {
 		replicas = g.Spec.Replicas
 	}
+	// a new container image is rolled out one pod at a time, by
+	// lowering the partition once the last updated pod is synced
+	var partition int32
+	if g.Status.Upgrade != nil && g.Status.Upgrade.RollbackReason == "" {
+		partition = g.Status.Upgrade.Partition
+	}
 	storage := g.Spec.StorageClass
 	storageRequest := resource.MustParse(g.Spec.StorageRequest)
 	sts := &appsv1.StatefulSet{
@@ -35,6 +47,12 @@ func StatefulSet(g *mariadbv1.Galera, configHash string) *appsv1.StatefulSet {
 				MatchLabels: ls,
 			},
 			PodManagementPolicy: appsv1.ParallelPodManagement,
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+				Type: appsv1.RollingUpdateStatefulSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{
+					Partition: &partition,
+				},
+			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: ls,
@@ -78,16 +96,88 @@ func StatefulSet(g *mariadbv1.Galera, configHash string) *appsv1.StatefulSet {
 		},
 		corev1.LabelHostname,
 	)
+	// Spread the pods evenly across zones when requested, so that a
+	// cluster of more than three nodes keeps its quorum when a single
+	// zone is lost. The constraint does not depend on the number of
+	// replicas, so that scaling the cluster does not restart the pods
+	if g.Spec.ZoneSpread {
+		sts.Spec.Template.Spec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{{
+			MaxSkew:           1,
+			TopologyKey:       corev1.LabelTopologyZone,
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector: &metav1.LabelSelector{
+				MatchLabels: ls,
+			},
+		}}
+	}
 	if g.Spec.NodeSelector != nil {
 		sts.Spec.Template.Spec.NodeSelector = *g.Spec.NodeSelector
 	}
 
-	return sts
+	err := applyPodTemplateOverride(&sts.Spec.Template, g.Spec.Override)
+	if err != nil {
+		return nil, err
+	}
+
+	return sts, nil
+}
+
+// applyPodTemplateOverride patches a pod template with the override of a galera CR.
+// The labels of the pod template are kept, as the statefulset selects pods with them.
+// The override can only change the existing containers, and can't replace the volumes
+func applyPodTemplateOverride(template *corev1.PodTemplateSpec, override mariadbv1.GaleraOverrideSpec) error {
+	if override.EmbeddedLabelsAnnotations != nil {
+		template.Labels = util.MergeStringMaps(template.Labels, override.Labels)
+		template.Annotations = util.MergeStringMaps(template.Annotations, override.Annotations)
+	}
+	if override.Spec == nil {
+		return nil
+	}
+
+	for _, c := range override.Spec.Containers {
+		if !slices.ContainsFunc(template.Spec.Containers, func(existing corev1.Container) bool { return existing.Name == c.Name }) {
+			return fmt.Errorf("container %s of the pod spec override is not a galera container", c.Name)
+		}
+	}
+	for _, v := range override.Spec.Volumes {
+		if slices.ContainsFunc(template.Spec.Volumes, func(existing corev1.Volume) bool { return existing.Name == v.Name }) {
+			return fmt.Errorf("volume %s of the pod spec override replaces a galera volume", v.Name)
+		}
+	}
+
+	original, err := json.Marshal(template.Spec)
+	if err != nil {
+		return fmt.Errorf("error marshalling pod spec: %w", err)
+	}
+	patch, err := json.Marshal(override.Spec)
+	if err != nil {
+		return fmt.Errorf("error marshalling pod spec override: %w", err)
+	}
+	patched, err := strategicpatch.StrategicMergePatch(original, patch, corev1.PodSpec{})
+	if err != nil {
+		return fmt.Errorf("error patching pod spec: %w", err)
+	}
+	spec := corev1.PodSpec{}
+	err = json.Unmarshal(patched, &spec)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling patched pod spec: %w", err)
+	}
+	template.Spec = spec
+	return nil
+}
+
+// GaleraImage returns the container image the galera pods run. After a failed
+// upgrade, the pods are kept on the image they ran before the upgrade
+func GaleraImage(g *mariadbv1.Galera) string {
+	if g.Status.Upgrade != nil && g.Status.Upgrade.RollbackReason != "" {
+		return g.Status.Upgrade.FromImage
+	}
+	return g.Spec.ContainerImage
 }
 
 func getGaleraInitContainers(g *mariadbv1.Galera) []corev1.Container {
 	return []corev1.Container{{
-		Image:   g.Spec.ContainerImage,
+		Image:   GaleraImage(g),
 		Name:    "mysql-bootstrap",
 		Command: []string{"bash", "/var/lib/operator-scripts/mysql_bootstrap.sh"},
 		Env: []corev1.EnvVar{{
@@ -113,7 +203,7 @@ func getGaleraInitContainers(g *mariadbv1.Galera) []corev1.Container {
 
 func getGaleraContainers(g *mariadbv1.Galera, configHash string) []corev1.Container {
 	containers := []corev1.Container{{
-		Image:   g.Spec.ContainerImage,
+		Image:   GaleraImage(g),
 		Name:    "galera",
 		Command: []string{"/usr/bin/dumb-init", "--", "/usr/local/bin/kolla_start"},
 		Env: []corev1.EnvVar{{
@@ -141,6 +231,7 @@ func getGaleraContainers(g *mariadbv1.Galera, configHash string) []corev1.Contai
 			Name:          "galera",
 		}},
 		VolumeMounts: getGaleraVolumeMounts(g),
+		Resources:    g.Spec.Resources,
 		StartupProbe: &corev1.Probe{
 			ProbeHandler: corev1.ProbeHandler{
 				Exec: &corev1.ExecAction{
@@ -173,7 +264,7 @@ func getGaleraContainers(g *mariadbv1.Galera, configHash string) []corev1.Contai
 		},
 	}}
 	logSideCar := corev1.Container{
-		Image:        g.Spec.ContainerImage,
+		Image:        GaleraImage(g),
 		Name:         "log",
 		Command:      []string{"/usr/bin/dumb-init", "--", "/bin/sh", "-c", "tail -n+1 -F /var/log/mariadb/mariadb.log"},
 		VolumeMounts: getGaleraVolumeMounts(g),
@@ -183,5 +274,19 @@ func getGaleraContainers(g *mariadbv1.Galera, configHash string) []corev1.Contai
 		containers = append(containers, logSideCar)
 	}
 
+	if g.Spec.BinlogArchive != nil {
+		archiverSideCar := corev1.Container{
+			Image:        GaleraImage(g),
+			Name:         "binlog-archiver",
+			Command:      []string{"/usr/bin/dumb-init", "--", "/bin/bash", "/var/lib/operator-scripts/binlog_archiver.sh"},
+			VolumeMounts: getBinlogArchiverVolumeMounts(),
+		}
+		containers = append(containers, archiverSideCar)
+	}
+
+	if g.Spec.Metrics != nil {
+		containers = append(containers, getMetricsContainer(g))
+	}
+
 	return containers
 }
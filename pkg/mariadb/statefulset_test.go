@@ -0,0 +1,225 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mariadb
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //revive:disable:dot-imports
+
+	"github.com/openstack-k8s-operators/lib-common/modules/common/service"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/ptr"
+)
+
+func newTestGalera(override mariadbv1.GaleraOverrideSpec) *mariadbv1.Galera {
+	g := &mariadbv1.Galera{
+		ObjectMeta: metav1.ObjectMeta{Name: "openstack", Namespace: "test"},
+	}
+	g.Spec.Secret = "osp-secret"
+	g.Spec.StorageRequest = "500M"
+	g.Spec.Replicas = ptr.To[int32](3)
+	g.Spec.ContainerImage = "quay.io/podified-antelope-centos9/openstack-mariadb:current-podified"
+	g.Spec.Override = override
+	return g
+}
+
+func getContainer(sts *appsv1.StatefulSet, name string) *corev1.Container {
+	for i, c := range sts.Spec.Template.Spec.Containers {
+		if c.Name == name {
+			return &sts.Spec.Template.Spec.Containers[i]
+		}
+	}
+	return nil
+}
+
+func TestApplyPodTemplateOverride(t *testing.T) {
+	memory := corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")}
+	toleration := corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "database", Effect: corev1.TaintEffectNoSchedule}
+	extraVolume := corev1.Volume{Name: "extra", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}
+
+	tests := []struct {
+		name     string
+		override mariadbv1.GaleraOverrideSpec
+		err      string
+		check    func(g *WithT, sts *appsv1.StatefulSet)
+	}{
+		{
+			name: "No override",
+			check: func(g *WithT, sts *appsv1.StatefulSet) {
+				g.Expect(sts.Spec.Template.Spec.Tolerations).To(BeEmpty())
+				g.Expect(sts.Spec.Template.Spec.Affinity).ToNot(BeNil())
+			},
+		},
+		{
+			name: "Labels and selector are kept",
+			override: mariadbv1.GaleraOverrideSpec{
+				EmbeddedLabelsAnnotations: &service.EmbeddedLabelsAnnotations{
+					Labels:      map[string]string{"app": "other", "team": "database"},
+					Annotations: map[string]string{"backup": "daily"},
+				},
+			},
+			check: func(g *WithT, sts *appsv1.StatefulSet) {
+				g.Expect(sts.Spec.Template.Labels).To(HaveKeyWithValue("app", "galera"))
+				g.Expect(sts.Spec.Template.Labels).To(HaveKeyWithValue("team", "database"))
+				g.Expect(sts.Spec.Template.Annotations).To(HaveKeyWithValue("backup", "daily"))
+				g.Expect(sts.Spec.Selector.MatchLabels).ToNot(HaveKey("team"))
+				selector, err := metav1.LabelSelectorAsSelector(sts.Spec.Selector)
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(selector.Matches(labels.Set(sts.Spec.Template.Labels))).To(BeTrue())
+			},
+		},
+		{
+			name: "Tolerations",
+			override: mariadbv1.GaleraOverrideSpec{
+				Spec: &mariadbv1.GaleraPodSpecOverride{Tolerations: []corev1.Toleration{toleration}},
+			},
+			check: func(g *WithT, sts *appsv1.StatefulSet) {
+				g.Expect(sts.Spec.Template.Spec.Tolerations).To(ConsistOf(toleration))
+				// the other settings of the pods are unchanged
+				g.Expect(sts.Spec.Template.Spec.Affinity).ToNot(BeNil())
+				g.Expect(sts.Spec.Template.Spec.Containers).To(HaveLen(1))
+			},
+		},
+		{
+			name: "Containers are merged by name",
+			override: mariadbv1.GaleraOverrideSpec{
+				Spec: &mariadbv1.GaleraPodSpecOverride{Containers: []corev1.Container{{
+					Name:      "galera",
+					Resources: corev1.ResourceRequirements{Limits: memory},
+				}}},
+			},
+			check: func(g *WithT, sts *appsv1.StatefulSet) {
+				g.Expect(sts.Spec.Template.Spec.Containers).To(HaveLen(1))
+				galera := getContainer(sts, "galera")
+				g.Expect(galera.Resources.Limits).To(Equal(memory))
+				g.Expect(galera.Image).To(Equal("quay.io/podified-antelope-centos9/openstack-mariadb:current-podified"))
+				g.Expect(galera.Command).ToNot(BeEmpty())
+				g.Expect(galera.VolumeMounts).ToNot(BeEmpty())
+			},
+		},
+		{
+			name: "Unknown container",
+			override: mariadbv1.GaleraOverrideSpec{
+				Spec: &mariadbv1.GaleraPodSpecOverride{Containers: []corev1.Container{{Name: "sidecar"}}},
+			},
+			err: "container sidecar of the pod spec override is not a galera container",
+		},
+		{
+			name: "Extra volumes",
+			override: mariadbv1.GaleraOverrideSpec{
+				Spec: &mariadbv1.GaleraPodSpecOverride{Volumes: []corev1.Volume{extraVolume}},
+			},
+			check: func(g *WithT, sts *appsv1.StatefulSet) {
+				g.Expect(sts.Spec.Template.Spec.Volumes).To(ContainElement(extraVolume))
+				g.Expect(sts.Spec.Template.Spec.Volumes).To(ContainElement(HaveField("Name", "secrets")))
+			},
+		},
+		{
+			name: "Volumes can't be replaced by name",
+			override: mariadbv1.GaleraOverrideSpec{
+				Spec: &mariadbv1.GaleraPodSpecOverride{Volumes: []corev1.Volume{{
+					Name:         "secrets",
+					VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+				}}},
+			},
+			err: "volume secrets of the pod spec override replaces a galera volume",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			sts, err := StatefulSet(newTestGalera(tt.override), "hash")
+			if tt.err != "" {
+				g.Expect(err).To(MatchError(tt.err))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			tt.check(g, sts)
+		})
+	}
+}
+
+func TestBinlogArchiverSideCar(t *testing.T) {
+	g := NewWithT(t)
+
+	galera := newTestGalera(mariadbv1.GaleraOverrideSpec{})
+	sts, err := StatefulSet(galera, "hash")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(getContainer(sts, "binlog-archiver")).To(BeNil())
+	g.Expect(sts.Spec.Template.Spec.Volumes).ToNot(ContainElement(HaveField("Name", "binlog-archive")))
+
+	galera.Spec.BinlogArchive = &mariadbv1.GaleraBinlogArchive{ClaimName: "binlog-archive"}
+	sts, err = StatefulSet(galera, "hash")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// the sidecar runs the archiver script shipped with the operator scripts
+	archiver := getContainer(sts, "binlog-archiver")
+	g.Expect(archiver).ToNot(BeNil())
+	g.Expect(archiver.Image).To(Equal(galera.Spec.ContainerImage))
+	g.Expect(archiver.Command).To(ContainElement("/var/lib/operator-scripts/binlog_archiver.sh"))
+	g.Expect(sts.Spec.Template.Spec.Volumes).To(ContainElement(And(
+		HaveField("Name", "operator-scripts"),
+		HaveField("VolumeSource.ConfigMap.Items", ContainElement(HaveField("Key", "binlog_archiver.sh"))))))
+
+	// it reads the binary logs from the datadir and copies them to the
+	// archive volume, which only it mounts
+	g.Expect(archiver.VolumeMounts).To(ContainElements(
+		corev1.VolumeMount{Name: "mysql-db", MountPath: "/var/lib/mysql", SubPath: "mysql", ReadOnly: true},
+		corev1.VolumeMount{Name: "binlog-archive", MountPath: BinlogArchiveMountPath},
+	))
+	g.Expect(getContainer(sts, "galera").VolumeMounts).ToNot(ContainElement(HaveField("Name", "binlog-archive")))
+	g.Expect(sts.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+		Name: "binlog-archive",
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "binlog-archive"},
+		},
+	}))
+}
+
+// The webhook validates the override against the names of the containers
+// and volumes of the galera pods, which must match the generated pod template
+func TestOverrideValidationNames(t *testing.T) {
+	g := NewWithT(t)
+
+	galera := newTestGalera(mariadbv1.GaleraOverrideSpec{})
+	galera.Spec.LogToDisk = true
+	galera.Spec.BinlogArchive = &mariadbv1.GaleraBinlogArchive{ClaimName: "binlog-archive"}
+	galera.Spec.Metrics = &mariadbv1.GaleraMetrics{}
+	galera.Spec.TLS.SecretName = ptr.To("galera-tls")
+	galera.Spec.TLS.CaBundleSecretName = "combined-ca-bundle"
+	sts, err := StatefulSet(galera, "hash")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	containers := []string{}
+	for _, c := range sts.Spec.Template.Spec.Containers {
+		containers = append(containers, c.Name)
+	}
+	g.Expect(galera.Spec.GaleraContainers()).To(ConsistOf(containers))
+	volumes := []string{sts.Spec.VolumeClaimTemplates[0].Name}
+	for _, v := range sts.Spec.Template.Spec.Volumes {
+		volumes = append(volumes, v.Name)
+	}
+	g.Expect(mariadbv1.GaleraVolumes()).To(ContainElements(volumes))
+}
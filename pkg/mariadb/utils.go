@@ -20,6 +20,16 @@ func ServiceLabels(database metav1.Object) map[string]string {
 	})
 }
 
+// ReadServiceLabels - labels for the read service. They must not match the
+// ServiceLabels, which are used to look up the database service of a galera CR
+func ReadServiceLabels(database metav1.Object) map[string]string {
+	return labels.GetLabels(database, "mariadb", map[string]string{
+		"owner": "mariadb-operator",
+		"cr":    "mariadb-" + database.GetName(),
+		"app":   "mariadb-read",
+	})
+}
+
 // LabelSelectors - labels for service, match statefulset and service should match
 func LabelSelectors(database metav1.Object, dbType string) map[string]string {
 	return map[string]string{
@@ -37,20 +37,52 @@ func getGaleraVolumes(g *mariadbv1.Galera) []corev1.Volume {
 		}
 	}
 
-	volumes := []corev1.Volume{
-		{
-			Name: "secrets",
-			VolumeSource: corev1.VolumeSource{
-				Secret: &corev1.SecretVolumeSource{
-					SecretName: g.Spec.Secret,
-					Items: []corev1.KeyToPath{
-						{
-							Key:  "DbRootPassword",
-							Path: "dbpassword",
+	secrets := corev1.VolumeSource{
+		Secret: &corev1.SecretVolumeSource{
+			SecretName: g.Spec.Secret,
+			Items: []corev1.KeyToPath{
+				{
+					Key:  "DbRootPassword",
+					Path: "dbpassword",
+				},
+			},
+		},
+	}
+	// the credentials of the SST user are only needed by mariabackup
+	if g.Spec.SSTMethod == mariadbv1.GaleraSSTMethodMariabackup {
+		secrets = corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{
+						Secret: &corev1.SecretProjection{
+							LocalObjectReference: corev1.LocalObjectReference{
+								Name: g.Spec.Secret,
+							},
+							Items: secrets.Secret.Items,
+						},
+					},
+					{
+						Secret: &corev1.SecretProjection{
+							LocalObjectReference: corev1.LocalObjectReference{
+								Name: SSTSecretName(g.Name),
+							},
+							Items: []corev1.KeyToPath{
+								{
+									Key:  SSTPasswordKey,
+									Path: "sstpassword",
+								},
+							},
 						},
 					},
 				},
 			},
+		}
+	}
+
+	volumes := []corev1.Volume{
+		{
+			Name:         "secrets",
+			VolumeSource: secrets,
 		},
 		{
 			Name: "kolla-config",
@@ -117,6 +149,18 @@ func getGaleraVolumes(g *mariadbv1.Galera) []corev1.Volume {
 							Key:  "mysql_wsrep_notify.sh",
 							Path: "mysql_wsrep_notify.sh",
 						},
+						{
+							Key:  "binlog_archiver.sh",
+							Path: "binlog_archiver.sh",
+						},
+						{
+							Key:  "mysql_root_password_rotate.sh",
+							Path: "mysql_root_password_rotate.sh",
+						},
+						{
+							Key:  "mysql_upgrade.sh",
+							Path: "mysql_upgrade.sh",
+						},
 					},
 				},
 			},
@@ -138,6 +182,10 @@ func getGaleraVolumes(g *mariadbv1.Galera) []corev1.Volume {
 		}
 	}
 
+	if g.Spec.BinlogArchive != nil {
+		volumes = append(volumes, getBinlogArchiveVolume(g.Spec.BinlogArchive.ClaimName))
+	}
+
 	return volumes
 }
 
@@ -233,3 +281,32 @@ func getGaleraLogMount() corev1.VolumeMount {
 		SubPath:   "log",
 	}
 }
+
+func getBinlogArchiveVolume(claimName string) corev1.Volume {
+	return corev1.Volume{
+		Name: "binlog-archive",
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: claimName,
+			},
+		},
+	}
+}
+
+func getBinlogArchiverVolumeMounts() []corev1.VolumeMount {
+	return []corev1.VolumeMount{
+		{
+			MountPath: "/var/lib/mysql",
+			Name:      "mysql-db",
+			SubPath:   "mysql",
+			ReadOnly:  true,
+		}, {
+			MountPath: "/var/lib/operator-scripts",
+			ReadOnly:  true,
+			Name:      "operator-scripts",
+		}, {
+			MountPath: BinlogArchiveMountPath,
+			Name:      "binlog-archive",
+		},
+	}
+}
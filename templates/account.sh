@@ -1,7 +1,34 @@
 #!/bin/bash
 export DatabasePassword=${DatabasePassword:?"Please specify a DatabasePassword variable."}
 
-mysql -h {{.DatabaseHostname}} -u {{.DatabaseAdminUsername}} -P 3306 -e "GRANT ALL PRIVILEGES ON {{.DatabaseName}}.* TO '{{.UserName}}'@'localhost' IDENTIFIED BY '$DatabasePassword'{{.RequireTLS}};GRANT ALL PRIVILEGES ON {{.DatabaseName}}.* TO '{{.UserName}}'@'%' IDENTIFIED BY '$DatabasePassword'{{.RequireTLS}};"
+previous=""
+{{- if .KeepPreviousPassword}}
+
+# the password is being rotated, keep accepting the password currently
+# set on the account until the rotation grace period expires. When the
+# account already accepts two passwords, the one set last comes first
+# in auth_or, while the top-level authentication_string is the older one
+previous=$(mysql -h {{.DatabaseHostname}} -u {{.DatabaseAdminUsername}} -P 3306 -NB -e "select coalesce(json_value(priv, '$.auth_or[0].authentication_string'), json_value(priv, '$.authentication_string')) from mysql.global_priv where user='{{.UserName}}' and host='localhost';" )
+{{- end}}
+
+{{- if .ReadOnly}}
+
+# drop any write privilege previously granted to the account. This fails
+# harmlessly when the account or its grants don't exist yet
+mysql -h {{.DatabaseHostname}} -u {{.DatabaseAdminUsername}} -P 3306 -e "REVOKE ALL PRIVILEGES ON {{.DatabaseName}}.* FROM '{{.UserName}}'@'localhost';REVOKE ALL PRIVILEGES ON {{.DatabaseName}}.* FROM '{{.UserName}}'@'%';" 2>/dev/null
+{{- end}}
+
+if [ -z "${previous}" ]; then
+    mysql -h {{.DatabaseHostname}} -u {{.DatabaseAdminUsername}} -P 3306 -e "GRANT {{.Privileges}} ON {{.DatabaseName}}.* TO '{{.UserName}}'@'localhost' IDENTIFIED BY '$DatabasePassword'{{.RequireTLS}};GRANT {{.Privileges}} ON {{.DatabaseName}}.* TO '{{.UserName}}'@'%' IDENTIFIED BY '$DatabasePassword'{{.RequireTLS}};"
+else
+    current=$(mysql -h {{.DatabaseHostname}} -u {{.DatabaseAdminUsername}} -P 3306 -NB -e "select password('$DatabasePassword');" )
+    mysql -h {{.DatabaseHostname}} -u {{.DatabaseAdminUsername}} -P 3306 -e "GRANT {{.Privileges}} ON {{.DatabaseName}}.* TO '{{.UserName}}'@'localhost'{{.RequireTLS}};GRANT {{.Privileges}} ON {{.DatabaseName}}.* TO '{{.UserName}}'@'%'{{.RequireTLS}};" || exit 1
+    # if the new password is already the first one accepted by the account,
+    # the rotation was already applied by a previous run
+    if [ "${previous}" != "${current}" ]; then
+        mysql -h {{.DatabaseHostname}} -u {{.DatabaseAdminUsername}} -P 3306 -e "ALTER USER '{{.UserName}}'@'localhost' IDENTIFIED VIA mysql_native_password USING PASSWORD('$DatabasePassword') OR mysql_native_password USING '${previous}';ALTER USER '{{.UserName}}'@'%' IDENTIFIED VIA mysql_native_password USING PASSWORD('$DatabasePassword') OR mysql_native_password USING '${previous}';" || exit 1
+    fi
+fi
 
 
 # search for the account.  not using SHOW CREATE USER to avoid displaying
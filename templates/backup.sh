@@ -0,0 +1,30 @@
+#!/bin/bash
+set -eu
+
+BACKUP_DIR={{.BackupMountPath}}/{{.BackupPath}}
+
+# start from a clean location, a previous attempt may have left
+# a partial backup behind
+rm -rf "${BACKUP_DIR}"
+mkdir -p "${BACKUP_DIR}"
+
+# the datadir of the galera pod is mounted in this job, so mariabackup
+# can copy the database files while the server is running. The server
+# is reached via its unix socket, which lives in the datadir as well
+mariabackup --backup --galera-info \
+    --datadir=/var/lib/mysql --socket=/var/lib/mysql/mysql.sock \
+    --user={{.DatabaseAdminUsername}} \
+    --target-dir="${BACKUP_DIR}"
+
+# prepare the backup right away, so that it is consistent and
+# can be restored by a simple copy
+mariabackup --prepare --target-dir="${BACKUP_DIR}"
+
+# galera position of the backup, in the form "uuid:seqno"
+position=$(head -1 "${BACKUP_DIR}/xtrabackup_galera_info" | cut -d' ' -f1)
+uuid=${position%%:*}
+seqno=${position##*:}
+size=$(du -sb "${BACKUP_DIR}" | cut -f1)
+
+# report the result of the backup to the operator
+echo "{\"uuid\":\"${uuid}\",\"seqno\":\"${seqno}\",\"size\":${size}}" > /dev/termination-log
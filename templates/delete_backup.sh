@@ -0,0 +1,5 @@
+#!/bin/bash
+set -eu
+
+# remove the artifact of a deleted backup from the backup storage
+rm -rf "{{.BackupMountPath}}/{{.BackupPath}}"
@@ -0,0 +1,27 @@
+#!/bin/bash
+
+# Copy the closed binary logs of this galera node to the archive
+# volume. The last file listed in the binlog index is the one
+# currently written by the server, it is archived once the server
+# rotates to a new file.
+ARCHIVE_DIR=/var/lib/mysql-binlog-archive/$(hostname)
+BINLOG_INDEX=/var/lib/mysql/mysql-bin.index
+ARCHIVE_INTERVAL=${ARCHIVE_INTERVAL:-60}
+
+mkdir -p "${ARCHIVE_DIR}"
+
+while true; do
+    if [ -f "${BINLOG_INDEX}" ]; then
+        for binlog in $(head -n -1 "${BINLOG_INDEX}"); do
+            name=$(basename "${binlog}")
+            if [ -f "/var/lib/mysql/${name}" ] && [ ! -f "${ARCHIVE_DIR}/${name}" ]; then
+                echo "Archiving binlog ${name}"
+                # copy under a temporary name so a partial copy is never
+                # mistaken for an archived file
+                cp "/var/lib/mysql/${name}" "${ARCHIVE_DIR}/.${name}.tmp" && \
+                    mv "${ARCHIVE_DIR}/.${name}.tmp" "${ARCHIVE_DIR}/${name}"
+            fi
+        done
+    fi
+    sleep "${ARCHIVE_INTERVAL}"
+done
@@ -0,0 +1,28 @@
+#!/bin/bash
+
+set -eu
+
+# The arbitrator joins the galera cluster as a member without data,
+# it only takes part in the quorum computation
+if [ "$(sysctl -n crypto.fips_enabled)" == "1" ]; then
+    echo FIPS enabled
+    SSL_CIPHER='ECDHE-RSA-AES256-GCM-SHA384'
+else
+    SSL_CIPHER='AES128-SHA256'
+fi
+
+PODNAME=$(hostname -f | cut -d. -f1,2)
+PODIPV6=$(grep "${PODNAME}" /etc/hosts | grep ':' | cut -d$'\t' -f1)
+if [[ "" = "${PODIPV6}" ]]; then
+    PODIP=$(grep "${PODNAME}" /etc/hosts | grep -v ':' | cut -d$'\t' -f1)
+else
+    PODIP="[::]"
+fi
+
+OPTIONS="gmcast.listen_addr=tcp://${PODIP}:4567"
+if [ "${GCOMM_TLS}" = "true" ]; then
+    OPTIONS="${OPTIONS};socket.ssl_key=/etc/pki/tls/private/galera.key;socket.ssl_cert=/etc/pki/tls/certs/galera.crt;socket.ssl_cipher=${SSL_CIPHER};socket.ssl_ca=/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem"
+fi
+
+set -x
+exec /usr/sbin/garbd --group="${CLUSTER_NAME}" --address="${GCOMM_URI}" --name="${PODNAME}" --options="${OPTIONS}"
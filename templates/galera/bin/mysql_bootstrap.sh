@@ -31,6 +31,8 @@ fi
 PODNAME=$(hostname -f | cut -d. -f1,2)
 PODIPV4=$(grep "${PODNAME}" /etc/hosts | grep -v ':' | cut -d$'\t' -f1)
 PODIPV6=$(grep "${PODNAME}" /etc/hosts | grep ':' | cut -d$'\t' -f1)
+# every node gets a distinct server_id, derived from the pod's ordinal
+SERVERID=$(( $(hostname -s | sed 's/.*-//') + 1 ))
 
 cd /var/lib/config-data/default
 for cfg in *.cnf.in; do
@@ -45,6 +47,17 @@ for cfg in *.cnf.in; do
         fi
 
         echo "Generating config file from template ${cfg}, will use ${IPSTACK} listen address of ${PODIP}"
-        sed -e "s/{ PODNAME }/${PODNAME}/" -e "s/{ PODIP }/${PODIP}/" -e "s/{ SSL_CIPHER }/${SSL_CIPHER}/" "/var/lib/config-data/default/${cfg}" > "/var/lib/config-data/generated/${cfg%.in}"
+        sed -e "s/{ PODNAME }/${PODNAME}/" -e "s/{ PODIP }/${PODIP}/" -e "s/{ SSL_CIPHER }/${SSL_CIPHER}/" -e "s/{ SERVERID }/${SERVERID}/" "/var/lib/config-data/default/${cfg}" > "/var/lib/config-data/generated/${cfg%.in}"
     fi
 done
+
+# The credentials of the SST user are kept out of the config map, the
+# donor of a state snapshot transfer uses them to run mariabackup.
+# They are only mounted with the mariabackup SST method, the operator
+# configures them on the running nodes when the method is changed
+if [ -f /var/lib/secrets/sstpassword ]; then
+    cat <<EOF >/var/lib/config-data/generated/galera_sst.cnf
+[mysqld]
+wsrep_sst_auth = galera_sst:$(cat /var/lib/secrets/sstpassword)
+EOF
+fi
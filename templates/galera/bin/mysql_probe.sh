@@ -1,9 +1,16 @@
 #!/bin/bash
 set -u
 
-# This secret is mounted by k8s and always up to date
+# This secret is mounted by k8s and always up to date. When the root
+# password changes, the secret is refreshed before the operator rotates
+# the password in the database, so fall back to the password this pod
+# was started with until the new one is in use
 read -s -u 3 3< /var/lib/secrets/dbpassword MYSQL_PWD || true
 export MYSQL_PWD
+if [ -n "${DB_ROOT_PASSWORD:-}" ] && [ "${MYSQL_PWD}" != "${DB_ROOT_PASSWORD}" ] && \
+    ! mysql -uroot -sN -e "select 1;" >/dev/null 2>&1; then
+    MYSQL_PWD="${DB_ROOT_PASSWORD}"
+fi
 
 PROBE_USER=root
 function mysql_status_check {
@@ -0,0 +1,36 @@
+#!/bin/bash
+set -u
+
+# Change the password of the root user from the password this pod
+# was started with (DB_ROOT_PASSWORD env var) to the password from
+# the mounted secret, which k8s updates when the secret changes.
+# The change is replicated to all the galera nodes.
+read -s -u 3 3< /var/lib/secrets/dbpassword NEW_PWD || true
+
+# nothing to do if the new password is already in use
+if MYSQL_PWD="${NEW_PWD}" mysql -uroot -sN -e "select 1;" >/dev/null 2>&1; then
+    echo "Root password already up to date"
+    exit 0
+fi
+
+# either the secret is not yet refreshed in this pod, or the pod was
+# restarted since the secret changed and no longer knows the password
+# in use. The operator then runs the rotation from another pod
+if [ "${NEW_PWD}" = "${DB_ROOT_PASSWORD}" ]; then
+    echo "Previous root password not known by this pod" >&2
+    exit 2
+fi
+
+export MYSQL_PWD="${DB_ROOT_PASSWORD}"
+# escape the password to use it in a SQL string
+sql_pwd=${NEW_PWD//\\/\\\\}
+sql_pwd=${sql_pwd//\'/\\\'}
+hosts=$(mysql -uroot -sN -e "select host from mysql.user where user='root';")
+if [ $? -ne 0 ]; then
+    echo "Could not connect with the current root password" >&2
+    exit 1
+fi
+for host in ${hosts}; do
+    echo "Changing password of root@${host}"
+    mysql -uroot -e "ALTER USER 'root'@'${host}' IDENTIFIED BY '${sql_pwd}';" || exit 1
+done
@@ -12,6 +12,11 @@ NAMESPACE=$(cat ${SERVICEACCOUNT}/namespace)
 TOKEN=$(cat ${SERVICEACCOUNT}/token)
 CACERT=${SERVICEACCOUNT}/ca.crt
 
+# This secret is mounted by k8s and always up to date, unlike the
+# DB_ROOT_PASSWORD env var which keeps its value from the pod's start
+read -s -u 3 3< /var/lib/secrets/dbpassword MYSQL_PWD || true
+export MYSQL_PWD
+
 function log() {
     echo "$(date +%F_%H_%M_%S) `basename $0` $*"
 }
@@ -41,7 +46,7 @@ if curl -s --cacert ${CACERT} --header "Content-Type:application/json" --header
     log "Galera resource is being deleted"
     nth=$(( ${PODNAME//*-/} + 1 ))
     while : ; do
-        size=$(mysql -uroot -p"${DB_ROOT_PASSWORD}" -sNEe "show status like 'wsrep_cluster_size';" | tail -1)
+        size=$(mysql -uroot -sNEe "show status like 'wsrep_cluster_size';" | tail -1)
         if [ ${size:-0} -gt $nth ]; then
             log "Waiting for cluster to scale down"
             sleep 2
@@ -60,8 +65,8 @@ fi
 log "Close all active connections to this local galera node"
 # filter out system and localhost connections, only consider clients with a port in the host field
 # from that point, clients will automatically reconnect to another node
-CLIENTS=$(mysql -uroot -p${DB_ROOT_PASSWORD} -nN -e "select id from information_schema.processlist where host like '%:%';")
-echo -n "$CLIENTS" | tr '\n' ',' | xargs -r mysqladmin -uroot -p${DB_ROOT_PASSWORD} kill
+CLIENTS=$(mysql -uroot -nN -e "select id from information_schema.processlist where host like '%:%';")
+echo -n "$CLIENTS" | tr '\n' ',' | xargs -r mysqladmin -uroot kill
 
 # At this point no clients are connected anymore.
 # We can finish this pre-stop hook and let k8s send the SIGTERM to the
@@ -0,0 +1,17 @@
+#!/bin/bash
+set -u
+
+# This secret is mounted by k8s and always up to date
+read -s -u 3 3< /var/lib/secrets/dbpassword MYSQL_PWD || true
+export MYSQL_PWD
+
+# Upgrade the system tables once all the galera nodes run the new
+# MariaDB version. mariadb-upgrade does not write to the binary log,
+# so with wsrep its changes are not replicated to the other nodes:
+# this must run on every node of the cluster.
+if command -v mariadb-upgrade >/dev/null 2>&1; then
+    UPGRADE=mariadb-upgrade
+else
+    UPGRADE=mysql_upgrade
+fi
+exec ${UPGRADE} -uroot
@@ -11,6 +11,11 @@ NAMESPACE=$(cat ${SERVICEACCOUNT}/namespace)
 TOKEN=$(cat ${SERVICEACCOUNT}/token)
 CACERT=${SERVICEACCOUNT}/ca.crt
 
+# This secret is mounted by k8s and always up to date, unlike the
+# DB_ROOT_PASSWORD env var which keeps its value from the pod's start
+read -s -u 3 3< /var/lib/secrets/dbpassword MYSQL_PWD || true
+export MYSQL_PWD
+
 # Retry config
 RETRIES=6
 WAIT=1
@@ -31,13 +36,13 @@ function log_error() {
 
 function mysql_get_status {
     local name=$1
-    mysql -nNE -uroot -p"${DB_ROOT_PASSWORD}" -e "show status like '${name}';" | tail -1
+    mysql -nNE -uroot -e "show status like '${name}';" | tail -1
     local rc=$?
     [ $rc = 0 ] || log_error "could not get value of mysql variable '${name}' (rc=$rc)"
 }
 
 function mysql_get_members {
-    mysql -nN -uroot -p"${DB_ROOT_PASSWORD}" -e "select node_name from mysql.wsrep_cluster_members;"
+    mysql -nN -uroot -e "select node_name from mysql.wsrep_cluster_members;"
     local rc=$?
     [ $rc = 0 ] || log_error "could not get cluster members from mysql' (rc=$rc)"
 }
@@ -106,6 +111,15 @@ function parse_output {
     [ $? == 0 ] || log_error "Could not parse json endpoint (rc=$?)"
 }
 
+# In A/A mode, the service has no active endpoint in its selector
+# and k8s balances the traffic across all the Synced (i.e. ready) pods
+function service_is_active_passive {
+    local svc="$1"
+    local ap
+    ap=$(echo "$svc" | parse_output '["spec"]["selector"].__contains__("statefulset.kubernetes.io/pod-name")')
+    [ "$ap" = "True" ]
+}
+
 # Generic retry logic for an action function
 function retry {
     local action=$1
@@ -152,6 +166,10 @@ function reconfigure_service_endpoint {
     CURRENT_SVC=$(api_server GET "$SERVICE")
     local rc=$?
     [ $rc == 0 ] || return $rc
+    if ! service_is_active_passive "$CURRENT_SVC"; then
+        log "Service ${SERVICE} is active/active. Nothing to be done."
+        return 0
+    fi
 
     CURRENT_ENDPOINT=$(echo "$CURRENT_SVC" | parse_output '["spec"]["selector"].get("statefulset.kubernetes.io/pod-name","")')
     [ $? == 0 ] || return 1
@@ -185,6 +203,10 @@ function failover_service_endpoint {
     CURRENT_SVC=$(api_server GET "$SERVICE")
     local rc=$?
     [ $rc == 0 ] || return $rc
+    if ! service_is_active_passive "$CURRENT_SVC"; then
+        log "Service ${SERVICE} is active/active. Nothing to be done."
+        return 0
+    fi
 
     CURRENT_ENDPOINT=$(echo "$CURRENT_SVC" | parse_output '["spec"]["selector"].get("statefulset.kubernetes.io/pod-name","")')
     [ $? == 0 ] || return 1
@@ -213,6 +235,10 @@ function remove_service_endpoint {
     CURRENT_SVC=$(api_server GET "$SERVICE")
     local rc=$?
     [ $rc == 0 ] || return $rc
+    if ! service_is_active_passive "$CURRENT_SVC"; then
+        log "Service ${SERVICE} is active/active. Nothing to be done."
+        return 0
+    fi
 
     CURRENT_ENDPOINT=$(echo "$CURRENT_SVC" | parse_output '["spec"]["selector"].get("statefulset.kubernetes.io/pod-name","")')
     [ $? == 0 ] || return 1
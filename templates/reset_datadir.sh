@@ -0,0 +1,7 @@
+#!/bin/bash
+set -eu
+
+# discard the database of this node. When it restarts, the
+# node will be initialized from scratch and will receive
+# the restored data from the bootstrap node via SST
+find /var/lib/mysql -mindepth 1 -delete
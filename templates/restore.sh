@@ -0,0 +1,77 @@
+#!/bin/bash
+set -eu -o pipefail
+
+BACKUP_DIR={{.BackupMountPath}}/{{.BackupPath}}
+{{if .Replay}}
+# the binlog archiver only copies the binary logs that the server rotated,
+# and the cluster was stopped while writing to its last one. Archive the
+# binary logs still missing from the datadir of the node whose archive is
+# replayed, before the datadir of the restored node gets wiped
+ARCHIVE_DIR={{.ArchiveMountPath}}/{{.ArchiveNode}}
+mkdir -p "${ARCHIVE_DIR}"
+if [ -f "{{.ArchiveNodeDataDir}}/mysql-bin.index" ]; then
+    for binlog in $(cat "{{.ArchiveNodeDataDir}}/mysql-bin.index"); do
+        name=$(basename "${binlog}")
+        if [ -f "{{.ArchiveNodeDataDir}}/${name}" ] && [ ! -f "${ARCHIVE_DIR}/${name}" ]; then
+            echo "Archiving binlog ${name}"
+            cp "{{.ArchiveNodeDataDir}}/${name}" "${ARCHIVE_DIR}/.${name}.tmp"
+            mv "${ARCHIVE_DIR}/.${name}.tmp" "${ARCHIVE_DIR}/${name}"
+        fi
+    done
+fi
+{{end}}
+# mariabackup only restores into an empty datadir, so discard
+# whatever is left from the previous database
+find /var/lib/mysql -mindepth 1 -delete
+mariabackup --copy-back --datadir=/var/lib/mysql --target-dir="${BACKUP_DIR}"
+{{if .Replay}}
+# replay the binary logs archived by the node the backup was taken
+# from, starting at the binlog position recorded in the backup
+if [ ! -f "${BACKUP_DIR}/xtrabackup_binlog_info" ]; then
+    echo "No binlog position in backup, was the binary log enabled when it was taken?" >&2
+    exit 1
+fi
+read -r binlog_file binlog_pos binlog_gtid < "${BACKUP_DIR}/xtrabackup_binlog_info"
+binlogs=$(cd "${ARCHIVE_DIR}" && ls mysql-bin.[0-9]* | sort | awk -v first="${binlog_file}" '$0 >= first')
+if [ -z "${binlogs}" ]; then
+    echo "No archived binlog found in ${ARCHIVE_DIR}" >&2
+    exit 1
+fi
+
+# start a local server without replication, only reachable from this
+# job. The grant tables are loaded, as the binary logs contain the
+# account changes of the database jobs. The replay connects as root
+# with the password of the galera secret (MYSQL_PWD) and stops the
+# server at the end, as it may have replayed a change of that password
+mysqld_safe --user=mysql --datadir=/var/lib/mysql --wsrep-on=OFF --wsrep-provider=none \
+    --skip-networking --skip-log-bin --socket=/tmp/replay.sock &
+until mysqladmin --socket=/tmp/replay.sock ping >/dev/null 2>&1; do sleep 1; done
+{{if .StopGTID}}
+(cd "${ARCHIVE_DIR}" && mysqlbinlog --start-position="${binlog_gtid}" --stop-position="{{.StopGTID}}" ${binlogs} && echo "SHUTDOWN;") | \
+    mysql -uroot --socket=/tmp/replay.sock
+{{else if .StopDatetime}}
+(cd "${ARCHIVE_DIR}" && mysqlbinlog --start-position="${binlog_pos}" --stop-datetime="{{.StopDatetime}}" ${binlogs} && echo "SHUTDOWN;") | \
+    mysql -uroot --socket=/tmp/replay.sock
+{{else}}
+(cd "${ARCHIVE_DIR}" && mysqlbinlog --start-position="${binlog_pos}" ${binlogs} && echo "SHUTDOWN;") | \
+    mysql -uroot --socket=/tmp/replay.sock
+{{end}}
+wait
+{{end}}
+{{if .ArchiveMountPath}}
+# the restored cluster starts a new binlog history, move the
+# binary logs archived until now out of the way
+mkdir -p "{{.ArchiveMountPath}}/{{.PreviousArchiveDir}}"
+find "{{.ArchiveMountPath}}" -mindepth 1 -maxdepth 1 -name "{{.ArchivePodPrefix}}-*" \
+    -exec mv {} "{{.ArchiveMountPath}}/{{.PreviousArchiveDir}}/" \;
+{{end}}
+# record the galera position of the backup and mark this node
+# as the one to bootstrap the cluster from. The operator will
+# pick it up when probing the pods at the next cluster start
+cat > /var/lib/mysql/grastate.dat <<EOS
+# GALERA saved state
+version: 2.1
+uuid:    {{.UUID}}
+seqno:   {{.Seqno}}
+safe_to_bootstrap: 1
+EOS
@@ -26,6 +26,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
 	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
 )
 
@@ -67,3 +68,13 @@ func GetGalera(name types.NamespacedName) *mariadbv1.Galera {
 	}, timeout, interval).Should(Succeed())
 	return instance
 }
+
+type ConditionGetterFunc func(name types.NamespacedName) condition.Conditions
+
+func (f ConditionGetterFunc) GetConditions(name types.NamespacedName) condition.Conditions {
+	return f(name)
+}
+
+func GaleraConditionGetter(name types.NamespacedName) condition.Conditions {
+	return GetGalera(name).Status.Conditions
+}
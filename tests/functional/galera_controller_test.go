@@ -0,0 +1,65 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package functional_test
+
+import (
+	. "github.com/onsi/ginkgo/v2" //revive:disable:dot-imports
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
+)
+
+var _ = Describe("Galera controller", func() {
+	var galeraName types.NamespacedName
+	var secretName types.NamespacedName
+
+	When("the root password secret of a running Galera changes", func() {
+		BeforeEach(func() {
+			secretName = types.NamespacedName{Name: "osp-secret", Namespace: namespace}
+			DeferCleanup(th.DeleteInstance, th.CreateSecret(secretName, map[string][]byte{
+				"DbRootPassword": []byte("12345678"),
+			}))
+			galera := CreateGaleraConfig(namespace, GetDefaultGaleraSpec())
+			galeraName.Name = galera.GetName()
+			galeraName.Namespace = galera.GetNamespace()
+			DeferCleanup(th.DeleteInstance, galera)
+
+			th.ExpectCondition(
+				galeraName,
+				ConditionGetterFunc(GaleraConditionGetter),
+				mariadbv1.GaleraRootPasswordReadyCondition,
+				corev1.ConditionTrue,
+			)
+		})
+
+		It("reconciles the Galera to start the rotation", func() {
+			th.UpdateSecret(secretName, "DbRootPassword", []byte("new-password"))
+
+			th.ExpectConditionWithDetails(
+				galeraName,
+				ConditionGetterFunc(GaleraConditionGetter),
+				mariadbv1.GaleraRootPasswordReadyCondition,
+				corev1.ConditionFalse,
+				condition.RequestedReason,
+				mariadbv1.GaleraRootPasswordRotationPendingMessage,
+			)
+		})
+	})
+})
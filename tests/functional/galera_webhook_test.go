@@ -62,4 +62,27 @@ var _ = Describe("Galera webhook", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	When("a Galera gets created with an operator-managed option in its customServiceConfig", func() {
+		It("gets blocked by the webhook and fail", func() {
+			spec := GetDefaultGaleraSpec()
+			spec["customServiceConfig"] = "[mysqld]\nwsrep_cluster_address = gcomm://\n"
+
+			raw := map[string]interface{}{
+				"apiVersion": "mariadb.openstack.org/v1beta1",
+				"kind":       "Galera",
+				"metadata": map[string]interface{}{
+					"name":      "galera-custom-config",
+					"namespace": namespace,
+				},
+				"spec": spec,
+			}
+
+			unstructuredObj := &unstructured.Unstructured{Object: raw}
+			_, err := controllerutil.CreateOrPatch(
+				th.Ctx, th.K8sClient, unstructuredObj, func() error { return nil })
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("wsrep_cluster_address is managed by the operator"))
+		})
+	})
 })